@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// accountedUtil is the utilization of a process as reported by accounting mode
+type accountedUtil struct {
+	smUtil  float64
+	memUtil float64
+}
+
+// accountingEnabled reports whether accounting mode is on for any GPU
+func accountingEnabled(smiOutput *SMIOutput) bool {
+	for _, gpu := range smiOutput.GPUs {
+		if gpu.AccountingMode == "Enabled" {
+			return true
+		}
+	}
+	return false
+}
+
+// getAccountedApps queries the per-process utilization collected by
+// accounting mode, keyed by GPU bus ID and PID. Utilization is averaged over
+// the lifetime of each process.
+func getAccountedApps(ctx context.Context) (map[string]map[uint32]accountedUtil, error) {
+	output, err := runNvidiaSmiCommand(ctx,
+		"--query-accounted-apps=gpu_bus_id,pid,gpu_utilization,mem_utilization",
+		"--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounted apps: %v", err)
+	}
+
+	apps := make(map[string]map[uint32]accountedUtil)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			continue
+		}
+		busID := strings.TrimSpace(fields[0])
+		pid, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 32)
+		if err != nil {
+			continue
+		}
+		// Values such as [N/A] are reported as zero
+		smUtil, _ := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+		memUtil, _ := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
+		smUtil, memUtil = roundPercent(smUtil), roundPercent(memUtil)
+
+		if apps[busID] == nil {
+			apps[busID] = make(map[uint32]accountedUtil)
+		}
+		// Accounting keeps terminated processes too; with PID reuse the
+		// latest entry belongs to the running process
+		apps[busID][uint32(pid)] = accountedUtil{smUtil: smUtil, memUtil: memUtil}
+	}
+	return apps, nil
+}
+
+// applyAccounting fills in the per-process utilization of the GPUs from
+// accounting mode. It reports whether accounting data is present; if not,
+// the utilization of every process is left as zero.
+func applyAccounting(ctx context.Context, smiOutput *SMIOutput, gpus []GPUInfo) bool {
+	// Accounting data of replayed output was not recorded
+	if smiReplaySource != nil || !accountingEnabled(smiOutput) {
+		return false
+	}
+
+	apps, err := getAccountedApps(ctx)
+	if err != nil {
+		log.Printf("Accounting mode is enabled but %v", err)
+		return false
+	}
+
+	for i := range gpus {
+		gpuApps := apps[gpus[i].ID]
+		for j := range gpus[i].Processes {
+			proc := &gpus[i].Processes[j]
+			if util, exists := gpuApps[proc.PID]; exists {
+				proc.SmUtil = util.smUtil
+				proc.MemUtil = util.memUtil
+			}
+		}
+	}
+	return true
+}
+
+// enableAccounting turns on accounting mode for all GPUs, which requires root
+func enableAccounting() {
+	if _, err := runNvidiaSmiCommand(context.Background(), "-am", "1"); err != nil {
+		log.Printf("Warning: failed to enable accounting mode: %v", err)
+		return
+	}
+	log.Printf("Accounting mode enabled")
+}
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the alerts section of the config
+const (
+	defaultAlertCooldownSeconds = 300
+	defaultAlertSeverity        = "warning"
+	defaultAlertOperator        = ">"
+)
+
+const (
+	// alertHistorySize is the number of resolved alerts kept for
+	// /api/alerts/history
+	alertHistorySize = 1000
+	// alertQueueSize is the number of notifications waiting to be sent
+	// before new ones are dropped
+	alertQueueSize = 100
+	webhookTimeout = 10 * time.Second
+)
+
+// States of an alert
+const (
+	AlertPending  = "pending"  // the condition holds, but not long enough yet or the alert is cooling down
+	AlertFiring   = "firing"   // the condition held for the rule's duration
+	AlertResolved = "resolved" // the condition no longer holds
+)
+
+// AlertsConfig configures the alert rules and where alerts are sent
+type AlertsConfig struct {
+	//doc: Alert rules; alerting is disabled when empty
+	Rules []AlertRule `json:"rules"`
+	//doc: URLs that receive a JSON POST when an alert fires or resolves
+	WebhookURLs []string `json:"webhook_urls"`
+	//doc: Seconds after an alert resolves before it can fire again for the same rule, node and GPU (default 300)
+	CooldownSeconds int `json:"cooldown_seconds"`
+	//doc: Recurring windows, e.g. planned maintenance, during which notifications are suppressed
+	Blackouts []BlackoutWindow `json:"blackouts"`
+	//doc: JSON file silences are saved to so that they survive restarts; they are only kept in memory when empty
+	SilencesFile string `json:"silences_file"`
+}
+
+// AlertRule fires an alert when a metric of a node or GPU compares to the
+// threshold for long enough, e.g. temperature > 85 for 120 seconds
+type AlertRule struct {
+	//doc: Unique name of the rule, e.g. gpu-hot
+	Name string `json:"name"`
+	//doc: Metric compared to the threshold. The node states node_offline (any status but online), node_unreachable (offline) and node_error (reachable but failing to collect GPU info) and the GPU flags power_limit_drift, fan_failure and throttled are 1 when the condition holds and 0 otherwise; reservation_violation is the number of processes of other users than the owner on a reserved GPU.
+	//doc:enum node_offline,node_unreachable,node_error,temperature,utilization,utilization_ema,memory_used_percent,power_usage_watts,fan_speed,throttling_pct,power_limit_drift,fan_failure,throttled,reservation_violation
+	Metric string `json:"metric"`
+	//doc: Comparison of the metric with the threshold (default ">")
+	//doc:enum >,>=,<,<=
+	Operator string `json:"operator"`
+	//doc: Threshold the metric is compared to (default 0)
+	Threshold float64 `json:"threshold"`
+	//doc: Seconds the condition must hold before the alert fires (default 0)
+	ForSeconds int `json:"for_seconds"`
+	//doc: Severity sent with the alert (default "warning")
+	Severity string `json:"severity"`
+	//doc: Only evaluate the rule for nodes with any of these tags; all nodes when empty
+	Tags []string `json:"tags"`
+}
+
+// Alert is the state of a rule for a node or one of its GPUs
+type Alert struct {
+	ID         string     `json:"id"`
+	Rule       string     `json:"rule"`
+	Node       string     `json:"node"`
+	GPUID      string     `json:"gpu_id,omitempty"` // empty for node metrics
+	Metric     string     `json:"metric"`
+	Value      float64    `json:"value"` // latest value
+	Operator   string     `json:"operator"`
+	Threshold  float64    `json:"threshold"`
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"` // AlertPending, AlertFiring or AlertResolved
+	Since      time.Time  `json:"since"` // when the condition started to hold
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// Notifications are suppressed by a silence or blackout window
+	Silenced bool `json:"silenced"`
+}
+
+// AlertNotification is the body POSTed to the webhooks
+type AlertNotification struct {
+	Status string `json:"status"` // AlertFiring or AlertResolved
+	Alert  Alert  `json:"alert"`
+}
+
+// AlertsResponse is returned by the /api/alerts endpoint
+type AlertsResponse struct {
+	Alerts []Alert `json:"alerts"`
+	// Whether a blackout window that applies to all nodes is active
+	BlackoutActive bool `json:"blackout_active"`
+}
+
+// nodeAlertStatuses are the metrics evaluated for each node, with the node
+// statuses they hold for; node_offline holds for any status but online
+var nodeAlertStatuses = map[string]string{
+	"node_offline":     "",
+	"node_unreachable": "offline",
+	"node_error":       "error",
+}
+
+// nodeAlertValue returns a node metric: 1 if the node's status is the
+// metric's, 0 otherwise
+func nodeAlertValue(metric, status string) float64 {
+	holds := status != "online"
+	if want := nodeAlertStatuses[metric]; want != "" {
+		holds = status == want
+	}
+	if holds {
+		return 1
+	}
+	return 0
+}
+
+// gpuAlertMetrics are the metrics evaluated for each GPU
+var gpuAlertMetrics = []string{"temperature", "utilization", "utilization_ema", "memory_used_percent", "power_usage_watts",
+	"fan_speed", "throttling_pct", "power_limit_drift", "fan_failure", "throttled", "reservation_violation"}
+
+// gpuAlertValue returns a GPU metric, or false if the GPU does not report it
+func gpuAlertValue(metric string, gpu GPUInfo) (float64, bool) {
+	flag := func(set bool) float64 {
+		if set {
+			return 1
+		}
+		return 0
+	}
+	switch metric {
+	case "temperature":
+		return float64(gpu.Temperature), true
+	case "utilization":
+		return gpu.Utilization, true
+	case "utilization_ema":
+		return gpu.UtilizationEMA, true
+	case "memory_used_percent":
+		if gpu.MemoryTotal == 0 {
+			return 0, false
+		}
+		return roundPercent(float64(gpu.MemoryUsed) / float64(gpu.MemoryTotal) * 100), true
+	case "power_usage_watts":
+		return float64(gpu.PowerUsage) / 1000, true
+	case "fan_speed":
+		if gpu.FanSpeed == nil {
+			return 0, false
+		}
+		return *gpu.FanSpeed, true
+	case "throttling_pct":
+		return gpu.ThrottlingPct, true
+	case "power_limit_drift":
+		return flag(gpu.PowerLimitDrift), true
+	case "fan_failure":
+		return flag(gpu.FanHealthStatus == FanHealthSuspectedFailure), true
+	case "throttled":
+		return flag(gpu.ThrottleReason != ""), true
+	case "reservation_violation":
+		return float64(len(foreignProcesses(gpu))), true
+	}
+	return 0, false
+}
+
+// withDefaults returns the rule with unset fields set to their defaults
+func (r AlertRule) withDefaults() AlertRule {
+	if r.Operator == "" {
+		r.Operator = defaultAlertOperator
+	}
+	if r.Severity == "" {
+		r.Severity = defaultAlertSeverity
+	}
+	return r
+}
+
+// holds reports whether value meets the rule's condition
+func (r AlertRule) holds(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
+
+// appliesTo reports whether the rule is evaluated for a node
+func (r AlertRule) appliesTo(node NodeConfig) bool {
+	return len(r.Tags) == 0 || slices.ContainsFunc(r.Tags, func(tag string) bool {
+		return slices.Contains(node.Tags, tag)
+	})
+}
+
+// validateAlertsConfig checks the alert rules and blackout windows
+func validateAlertsConfig(config AlertsConfig) error {
+	names := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("alerts: rule name is required")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alerts: duplicate rule %s", rule.Name)
+		}
+		names[rule.Name] = true
+		if _, isNodeMetric := nodeAlertStatuses[rule.Metric]; !isNodeMetric && !slices.Contains(gpuAlertMetrics, rule.Metric) {
+			return fmt.Errorf("alerts: rule %s: unknown metric %q", rule.Name, rule.Metric)
+		}
+		switch rule.Operator {
+		case "", ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("alerts: rule %s: unknown operator %q", rule.Name, rule.Operator)
+		}
+		if rule.ForSeconds < 0 {
+			return fmt.Errorf("alerts: rule %s: for_seconds must not be negative", rule.Name)
+		}
+	}
+	for _, url := range config.WebhookURLs {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return fmt.Errorf("alerts: invalid webhook URL %q", redactURLPassword(url))
+		}
+	}
+	for i, window := range config.Blackouts {
+		if _, err := parseBlackoutWindow(window); err != nil {
+			return fmt.Errorf("alerts: blackout %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// alertKey identifies the alert of a rule for a node or one of its GPUs
+type alertKey struct {
+	rule, node, gpu string
+}
+
+// id returns a stable ID of the alert, which stays the same across restarts
+// so that silences of the alert keep applying
+func (k alertKey) id() string {
+	sum := sha256.Sum256([]byte(k.rule + "\x00" + k.node + "\x00" + k.gpu))
+	return hex.EncodeToString(sum[:8])
+}
+
+// alertRecord is the evaluation state of an alert
+type alertRecord struct {
+	alert         Alert
+	pendingSince  time.Time // zero while the condition does not hold
+	firedAt       time.Time
+	resolvedAt    time.Time
+	cooldownUntil time.Time
+	notified      bool // the firing notification was sent
+}
+
+// alertEngine evaluates the alert rules after each poll cycle and sends
+// notifications to the webhooks. Notifications that are suppressed by a
+// silence or blackout are sent once the suppression ends if the alert is
+// still firing.
+type alertEngine struct {
+	rules     []AlertRule
+	cooldown  time.Duration
+	blackouts []blackoutWindow
+	silences  *silenceStore
+	webhooks  []string
+	client    *http.Client
+	queue     chan AlertNotification
+	audit     *auditLogger
+
+	mutex      sync.Mutex
+	alertState map[alertKey]*alertRecord
+	history    []Alert // ring buffer of resolved alerts
+	next       int
+}
+
+// newAlertEngine returns the alert engine configured by config, or nil if
+// there are no rules. The config must have been validated. Alerts that fire
+// or resolve are recorded to audit.
+func newAlertEngine(config AlertsConfig, client *http.Client, audit *auditLogger) (*alertEngine, error) {
+	if len(config.Rules) == 0 {
+		return nil, nil
+	}
+	silences, err := newSilenceStore(config.SilencesFile)
+	if err != nil {
+		return nil, err
+	}
+	e := &alertEngine{
+		cooldown:   time.Duration(config.CooldownSeconds) * time.Second,
+		silences:   silences,
+		webhooks:   config.WebhookURLs,
+		client:     client,
+		queue:      make(chan AlertNotification, alertQueueSize),
+		audit:      audit,
+		alertState: make(map[alertKey]*alertRecord),
+		history:    make([]Alert, 0, alertHistorySize),
+	}
+	if config.CooldownSeconds <= 0 {
+		e.cooldown = defaultAlertCooldownSeconds * time.Second
+	}
+	for _, rule := range config.Rules {
+		e.rules = append(e.rules, rule.withDefaults())
+	}
+	for _, window := range config.Blackouts {
+		parsed, _ := parseBlackoutWindow(window)
+		e.blackouts = append(e.blackouts, parsed)
+	}
+	go e.run()
+	return e, nil
+}
+
+// evaluate updates the alerts from the nodes' latest data
+func (e *alertEngine) evaluate(nodes []NodeStatus, now time.Time) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	seen := make(map[alertKey]bool)
+	for _, rule := range e.rules {
+		for _, node := range nodes {
+			if !rule.appliesTo(node.NodeConfig) {
+				continue
+			}
+			if _, isNodeMetric := nodeAlertStatuses[rule.Metric]; isNodeMetric {
+				e.update(rule, node.NodeConfig, "", nodeAlertValue(rule.Metric, node.Status), now, seen)
+				continue
+			}
+			if node.Status != "online" || node.Data == nil {
+				// The GPUs' alerts keep their state while the node is down
+				for key := range e.alertState {
+					if key.rule == rule.Name && key.node == node.Name {
+						seen[key] = true
+					}
+				}
+				continue
+			}
+			for _, gpu := range node.Data.GPUs {
+				if value, ok := gpuAlertValue(rule.Metric, gpu); ok {
+					e.update(rule, node.NodeConfig, gpu.ID, value, now, seen)
+				}
+			}
+		}
+	}
+
+	// Alerts of removed rules, nodes and GPUs resolve
+	for key, record := range e.alertState {
+		if !seen[key] {
+			e.clear(record, now)
+		}
+		if record.alert.State != AlertFiring && record.pendingSince.IsZero() && !now.Before(record.cooldownUntil) {
+			delete(e.alertState, key)
+		}
+	}
+}
+
+// update applies the latest value of a metric to its alert. Must be called
+// with the lock held.
+func (e *alertEngine) update(rule AlertRule, node NodeConfig, gpuID string, value float64, now time.Time, seen map[alertKey]bool) {
+	key := alertKey{rule.Name, node.Name, gpuID}
+	seen[key] = true
+	record := e.alertState[key]
+	holds := rule.holds(value)
+	if record == nil {
+		if !holds {
+			return
+		}
+		record = &alertRecord{alert: Alert{
+			ID:        key.id(),
+			Rule:      rule.Name,
+			Node:      node.Name,
+			GPUID:     gpuID,
+			Metric:    rule.Metric,
+			Operator:  rule.Operator,
+			Threshold: rule.Threshold,
+			Severity:  rule.Severity,
+		}}
+		e.alertState[key] = record
+	}
+	record.alert.Value = value
+	if !holds {
+		e.clear(record, now)
+		return
+	}
+
+	if record.pendingSince.IsZero() {
+		record.pendingSince = now
+	}
+	if record.alert.State != AlertFiring {
+		record.alert.State = AlertPending
+		record.alert.Since = record.pendingSince
+		forDuration := time.Duration(rule.ForSeconds) * time.Second
+		if now.Sub(record.pendingSince) < forDuration || now.Before(record.cooldownUntil) {
+			return
+		}
+		record.alert.State = AlertFiring
+		record.firedAt = now
+		record.notified = false
+		log.Printf("Alert %s firing for %s: %s is %g (%s %g)",
+			rule.Name, alertSubject(record.alert), rule.Metric, value, rule.Operator, rule.Threshold)
+		e.audit.record("alert_firing", record.snapshot())
+	}
+
+	record.alert.Silenced = e.suppressed(record.alert, node, now)
+	if !record.notified && !record.alert.Silenced {
+		record.notified = true
+		e.notify(AlertFiring, record.snapshot())
+	}
+}
+
+// clear handles a condition that no longer holds, resolving a firing alert.
+// Must be called with the lock held.
+func (e *alertEngine) clear(record *alertRecord, now time.Time) {
+	record.pendingSince = time.Time{}
+	if record.alert.State != AlertFiring {
+		record.alert.State = AlertResolved
+		return
+	}
+	record.alert.State = AlertResolved
+	record.resolvedAt = now
+	record.cooldownUntil = now.Add(e.cooldown)
+	log.Printf("Alert %s resolved for %s", record.alert.Rule, alertSubject(record.alert))
+
+	alert := record.snapshot()
+	e.audit.record("alert_resolved", alert)
+	if record.notified {
+		e.notify(AlertResolved, alert)
+	}
+	if len(e.history) < cap(e.history) {
+		e.history = append(e.history, alert)
+	} else {
+		e.history[e.next] = alert
+		e.next = (e.next + 1) % len(e.history)
+	}
+}
+
+// snapshot returns the alert with the times of the record
+func (r *alertRecord) snapshot() Alert {
+	alert := r.alert
+	if !r.firedAt.IsZero() {
+		firedAt := r.firedAt
+		alert.FiredAt = &firedAt
+	}
+	if alert.State == AlertResolved && !r.resolvedAt.IsZero() {
+		resolvedAt := r.resolvedAt
+		alert.ResolvedAt = &resolvedAt
+	}
+	return alert
+}
+
+func alertSubject(alert Alert) string {
+	if alert.GPUID == "" {
+		return "node " + alert.Node
+	}
+	return fmt.Sprintf("node %s GPU %s", alert.Node, alert.GPUID)
+}
+
+// suppressed reports whether the notifications of an alert are silenced or
+// fall into a blackout window
+func (e *alertEngine) suppressed(alert Alert, node NodeConfig, now time.Time) bool {
+	if e.silences.matches(alert, now) {
+		return true
+	}
+	for _, window := range e.blackouts {
+		if window.appliesTo(node) && window.active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// blackoutActive reports whether a blackout window for all nodes is active
+func (e *alertEngine) blackoutActive(now time.Time) bool {
+	for _, window := range e.blackouts {
+		if window.global() && window.active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// notify queues a notification without blocking
+func (e *alertEngine) notify(status string, alert Alert) {
+	if len(e.webhooks) == 0 {
+		return
+	}
+	select {
+	case e.queue <- AlertNotification{Status: status, Alert: alert}:
+	default:
+		log.Printf("Alert queue is full, dropping %s notification of %s for %s", status, alert.Rule, alertSubject(alert))
+	}
+}
+
+// run sends the queued notifications to the webhooks
+func (e *alertEngine) run() {
+	for notification := range e.queue {
+		body, err := json.Marshal(notification)
+		if err != nil {
+			log.Printf("Failed to encode alert notification: %v", err)
+			continue
+		}
+		for _, url := range e.webhooks {
+			if err := e.post(url, body); err != nil {
+				log.Printf("Failed to send alert %s to webhook %s: %v",
+					notification.Alert.Rule, redactURLPassword(url), err)
+			}
+		}
+	}
+}
+
+func (e *alertEngine) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := *e.client
+	client.Timeout = webhookTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP error: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// active returns the pending and firing alerts ordered by node, GPU and rule
+func (e *alertEngine) active() []Alert {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	alerts := []Alert{}
+	for _, record := range e.alertState {
+		if record.alert.State == AlertPending || record.alert.State == AlertFiring {
+			alerts = append(alerts, record.snapshot())
+		}
+	}
+	sortAlerts(alerts)
+	return alerts
+}
+
+// find returns the pending or firing alert with the given ID
+func (e *alertEngine) find(id string) (Alert, bool) {
+	for _, alert := range e.active() {
+		if alert.ID == id {
+			return alert, true
+		}
+	}
+	return Alert{}, false
+}
+
+// resolved returns the alerts resolved since the given time, newest first
+func (e *alertEngine) resolved(since time.Time) []Alert {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	alerts := []Alert{}
+	for i := len(e.history) - 1; i >= 0; i-- {
+		alert := e.history[(e.next+i)%len(e.history)]
+		if alert.ResolvedAt.Before(since) {
+			break
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Node != alerts[j].Node {
+			return alerts[i].Node < alerts[j].Node
+		}
+		if alerts[i].GPUID != alerts[j].GPUID {
+			return alerts[i].GPUID < alerts[j].GPUID
+		}
+		return alerts[i].Rule < alerts[j].Rule
+	})
+}
+
+// alertsHandler lists the pending and firing alerts
+func (a *Aggregator) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AlertsResponse{
+		Alerts:         a.alerts.active(),
+		BlackoutActive: a.alerts.blackoutActive(time.Now()),
+	})
+}
+
+// alertHistoryHandler lists the alerts resolved in the last hours (default 24)
+func (a *Aggregator) alertHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) {
+		return
+	}
+	hours := 24
+	if value := r.URL.Query().Get("hours"); value != "" {
+		var err error
+		if hours, err = strconv.Atoi(value); err != nil || hours <= 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid hours parameter: %q", value))
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.alerts.resolved(time.Now().Add(-time.Duration(hours) * time.Hour)))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestAlertEngine returns an engine with the rule whose notifications are
+// left in its queue instead of being sent
+func newTestAlertEngine(t *testing.T, rule AlertRule, cooldown time.Duration) *alertEngine {
+	t.Helper()
+	silences, err := newSilenceStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &alertEngine{
+		rules:      []AlertRule{rule.withDefaults()},
+		cooldown:   cooldown,
+		silences:   silences,
+		webhooks:   []string{"http://127.0.0.1:9/hook"},
+		queue:      make(chan AlertNotification, alertQueueSize),
+		alertState: make(map[alertKey]*alertRecord),
+		history:    make([]Alert, 0, alertHistorySize),
+	}
+}
+
+// gpuNode returns an online node whose GPUs have the given temperatures
+func gpuNode(temperatures ...uint32) NodeStatus {
+	node := NodeStatus{NodeConfig: NodeConfig{Name: "gpu-01"}, Status: "online", Data: &NodeInfo{}}
+	for i, temperature := range temperatures {
+		node.Data.GPUs = append(node.Data.GPUs, GPUInfo{ID: string(rune('a' + i)), Temperature: temperature})
+	}
+	return node
+}
+
+func TestAlertCooldown(t *testing.T) {
+	const (
+		interval = 5 * time.Second
+		polls    = 240 // 20 minutes
+	)
+	// fluctuating is around the threshold, alternating every poll
+	fluctuating := func(i int) uint32 {
+		if i%2 == 0 {
+			return 86
+		}
+		return 84
+	}
+
+	tests := []struct {
+		name        string
+		cooldown    time.Duration
+		forSeconds  int
+		temperature func(poll int) uint32
+		firings     int
+	}{
+		{"fluctuating", 5 * time.Minute, 0, fluctuating, 4}, // at 0s, 310s, 620s and 930s
+		{"fluctuating with a short cooldown", time.Second, 0, fluctuating, polls / 2},
+		{"fluctuating faster than the rule's duration", 5 * time.Minute, 10, fluctuating, 0},
+		{"steady above", 5 * time.Minute, 0, func(int) uint32 { return 90 }, 1},
+		{"steady below", 5 * time.Minute, 0, func(int) uint32 { return 70 }, 0},
+		{"back above during the cooldown", time.Minute, 0, func(i int) uint32 {
+			if i == 1 {
+				return 84
+			}
+			return 86
+		}, 2}, // again when the cooldown ends
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := AlertRule{Name: "gpu-hot", Metric: "temperature", Threshold: 85, ForSeconds: test.forSeconds}
+			e := newTestAlertEngine(t, rule, test.cooldown)
+			start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+			var fired []time.Time
+			for i := range polls {
+				now := start.Add(time.Duration(i) * interval)
+				e.evaluate([]NodeStatus{gpuNode(test.temperature(i))}, now)
+				for len(e.queue) > 0 {
+					if notification := <-e.queue; notification.Status == AlertFiring {
+						fired = append(fired, now)
+					}
+				}
+			}
+
+			if len(fired) != test.firings {
+				t.Errorf("fired %d times, want %d: %v", len(fired), test.firings, fired)
+			}
+			for i := 1; i < len(fired); i++ {
+				if gap := fired[i].Sub(fired[i-1]); gap < test.cooldown {
+					t.Errorf("fired again after %v, within the cooldown of %v", gap, test.cooldown)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertCooldownPerGPU(t *testing.T) {
+	e := newTestAlertEngine(t, AlertRule{Name: "gpu-hot", Metric: "temperature", Threshold: 85}, 5*time.Minute)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	polls := []struct {
+		temperatures []uint32 // of GPUs a and b
+		firing       []string // GPUs notified as firing
+	}{
+		{[]uint32{86, 70}, []string{"a"}},
+		{[]uint32{84, 70}, nil},
+		// a cools down, but b has not fired yet
+		{[]uint32{86, 86}, []string{"b"}},
+		{[]uint32{86, 86}, nil},
+	}
+	for i, poll := range polls {
+		e.evaluate([]NodeStatus{gpuNode(poll.temperatures...)}, start.Add(time.Duration(i)*5*time.Second))
+		var firing []string
+		for len(e.queue) > 0 {
+			if notification := <-e.queue; notification.Status == AlertFiring {
+				firing = append(firing, notification.Alert.GPUID)
+			}
+		}
+		if len(firing) != len(poll.firing) || (len(firing) > 0 && firing[0] != poll.firing[0]) {
+			t.Errorf("poll %d: firing for %v, want %v", i, firing, poll.firing)
+		}
+	}
+}
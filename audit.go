@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// auditQueueSize is the number of events buffered before new ones are dropped
+	auditQueueSize = 1024
+	// auditTailBytes is how much of the end of the log /api/audit reads
+	auditTailBytes = 4 << 20
+)
+
+// AuditConfig configures the append-only audit log of state changes
+type AuditConfig struct {
+	//doc: JSON Lines file the events are appended to; auditing is disabled when empty
+	File string `json:"file"`
+	//doc: Size in MB at which the file is rotated (default 100)
+	MaxSizeMB int `json:"max_size_mb"`
+	//doc: Rotated files kept (default 3)
+	MaxBackups int `json:"max_backups"`
+}
+
+// AuditEvent is a single line of the audit log
+type AuditEvent struct {
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// Payloads of the audit events
+type (
+	// StatusChangeEvent is recorded as "node_status" when a node's status changes
+	StatusChangeEvent struct {
+		Node  string `json:"node"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Error string `json:"error,omitempty"`
+	}
+
+	// NodeChangeEvent is recorded as "node_added" or "node_removed"
+	NodeChangeEvent struct {
+		Node       string      `json:"node"`
+		Config     *NodeConfig `json:"config,omitempty"`
+		RemoteAddr string      `json:"remote_addr,omitempty"`
+	}
+
+	// NodesReloadEvent is recorded as "nodes_reloaded" when the external
+	// nodes source or the config file is loaded. The changed nodes are only
+	// listed for the config file.
+	NodesReloadEvent struct {
+		Source  string   `json:"source"`
+		Nodes   int      `json:"nodes"`
+		Added   []string `json:"added,omitempty"`
+		Removed []string `json:"removed,omitempty"`
+		Changed []string `json:"changed,omitempty"`
+		Error   string   `json:"error,omitempty"`
+	}
+
+	// PowerLimitDriftEvent is recorded as "power_limit_drift" when a GPU's
+	// power limit starts or stops differing from the expected one
+	PowerLimitDriftEvent struct {
+		Node                 string `json:"node"`
+		GPU                  string `json:"gpu"`
+		ExpectedMilliwatts   uint64 `json:"expected_milliwatts"`
+		PowerLimitMilliwatts uint64 `json:"power_limit_milliwatts"`
+		Drift                bool   `json:"drift"`
+	}
+
+	// FanHealthEvent is recorded as "fan_health" when a GPU's fan is
+	// suspected to have failed or responds again
+	FanHealthEvent struct {
+		Node        string  `json:"node"`
+		GPU         string  `json:"gpu"`
+		Temperature uint32  `json:"temperature"`
+		FanSpeed    float64 `json:"fan_speed"`
+		Suspected   bool    `json:"suspected"`
+	}
+
+	// ThrottlingEvent is recorded as "throttling" when a busy GPU starts or
+	// stops being throttled
+	ThrottlingEvent struct {
+		Node          string  `json:"node"`
+		GPU           string  `json:"gpu"`
+		Reason        string  `json:"reason,omitempty"`
+		ThrottlingPct float64 `json:"throttling_pct"`
+		Utilization   float64 `json:"utilization"`
+		Throttled     bool    `json:"throttled"`
+	}
+
+	// PageRetirementEvent is recorded as "page_retirement" when a GPU starts
+	// or stops having memory pages pending retirement
+	PageRetirementEvent struct {
+		Node                   string `json:"node"`
+		GPU                    string `json:"gpu"`
+		AggregateUncorrectable uint64 `json:"aggregate_uncorrectable"`
+		RetiredPages           uint64 `json:"retired_pages"`
+		Pending                bool   `json:"pending"`
+	}
+)
+
+// AuditResponse is returned by /api/audit
+type AuditResponse struct {
+	Events        []json.RawMessage `json:"events"`
+	DroppedEvents uint64            `json:"dropped_events"`
+}
+
+// auditLogger writes audit events as JSON lines from a background goroutine
+// so that recording an event never blocks. Events that do not fit in the
+// queue are dropped and counted. A nil logger discards all events.
+type auditLogger struct {
+	config  AuditConfig
+	events  chan AuditEvent
+	dropped atomic.Uint64
+
+	file *os.File
+	size int64
+}
+
+func newAuditLogger(config AuditConfig) (*auditLogger, error) {
+	if config.MaxSizeMB <= 0 {
+		config.MaxSizeMB = 100
+	}
+	if config.MaxBackups <= 0 {
+		config.MaxBackups = 3
+	}
+	l := &auditLogger{
+		config: config,
+		events: make(chan AuditEvent, auditQueueSize),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	go l.run()
+	return l, nil
+}
+
+// record queues an event without blocking
+func (l *auditLogger) record(eventType string, payload interface{}) {
+	if l == nil {
+		return
+	}
+	select {
+	case l.events <- AuditEvent{Time: time.Now().UTC(), Type: eventType, Payload: payload}:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+func (l *auditLogger) open() error {
+	file, err := os.OpenFile(l.config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *auditLogger) run() {
+	for event := range l.events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to encode audit event %s: %v", event.Type, err)
+			continue
+		}
+		line = append(line, '\n')
+
+		if l.size+int64(len(line)) > int64(l.config.MaxSizeMB)<<20 {
+			if err := l.rotate(); err != nil {
+				log.Printf("Failed to rotate audit log: %v", err)
+			}
+		}
+		if l.file == nil {
+			l.dropped.Add(1)
+			continue
+		}
+		n, err := l.file.Write(line)
+		l.size += int64(n)
+		if err != nil {
+			log.Printf("Failed to write audit log: %v", err)
+		}
+	}
+}
+
+// rotate renames the log to <file>.1, shifting older backups up to
+// max_backups, and starts a new log
+func (l *auditLogger) rotate() error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	for i := l.config.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.config.File, i), fmt.Sprintf("%s.%d", l.config.File, i+1))
+	}
+	if err := os.Rename(l.config.File, l.config.File+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return l.open()
+}
+
+// tail returns the most recent events of the current log file matching the
+// filters, oldest first. Only the last auditTailBytes of the file are read.
+func (l *auditLogger) tail(since time.Time, eventType string, limit int) ([]json.RawMessage, error) {
+	file, err := os.Open(l.config.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	partial := false
+	if offset := info.Size() - auditTailBytes; offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		partial = true
+	}
+
+	events := []json.RawMessage{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), auditTailBytes)
+	for scanner.Scan() {
+		if partial {
+			// The first line is likely cut off by the seek
+			partial = false
+			continue
+		}
+		var header struct {
+			Time time.Time `json:"time"`
+			Type string    `json:"type"`
+		}
+		line := scanner.Bytes()
+		if err := json.Unmarshal(line, &header); err != nil {
+			continue
+		}
+		if (eventType != "" && header.Type != eventType) || (!since.IsZero() && !header.Time.After(since)) {
+			continue
+		}
+		events = append(events, json.RawMessage(append([]byte(nil), line...)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
+
+func (a *Aggregator) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if a.audit == nil {
+		writeJSONError(w, http.StatusNotFound, "Audit log is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid since parameter: %v", err))
+			return
+		}
+	}
+	limit := 100
+	if query.Has("limit") {
+		var err error
+		if limit, err = parseNonNegativeInt(query.Get("limit")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit parameter: %v", err))
+			return
+		}
+	}
+
+	events, err := a.audit.tail(since, query.Get("type"), limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read audit log: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditResponse{
+		Events:        events,
+		DroppedEvents: a.audit.dropped.Load(),
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireNodeToken rejects requests to the GPU info server that do not carry
+// the shared auth token. /health stays open for load balancers and liveness
+// probes.
+func requireNodeToken(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerTokenMatches checks the bearer token of a request against the given
+// tokens, of which empty ones are not configured. configured is false if none
+// of them is.
+func bearerTokenMatches(r *http.Request, tokens ...string) (configured, ok bool) {
+	provided, hasToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		configured = true
+		if hasToken && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return true, true
+		}
+	}
+	return configured, false
+}
+
+// nodeAuthToken returns the token the aggregator sends to a node: the node's
+// own auth_token, or aggregator.node_auth_token
+func (a *Aggregator) nodeAuthToken(node NodeConfig) string {
+	if node.AuthToken != "" {
+		return node.AuthToken
+	}
+	return a.config.Aggregator.NodeAuthToken
+}
+
+// tokenTransport adds a bearer token to every request to a node. The embedded
+// transport still provides CloseIdleConnections.
+type tokenTransport struct {
+	*http.Transport
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.Transport.RoundTrip(req)
+}
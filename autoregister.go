@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Defaults of the lifetime of nodes that registered themselves
+const (
+	defaultRegistrationStaleSeconds = 90
+	defaultRegistrationTTLSeconds   = 3600
+)
+
+// RegistrationResponse is returned by /api/register
+type RegistrationResponse struct {
+	Name string `json:"name"`
+	// Interval the node should send heartbeats at, a third of the time after
+	// which it is considered stale
+	HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds"`
+}
+
+// registrationStale returns how long a registered node may go without a
+// heartbeat before it is marked offline and no longer polled
+func (a *Aggregator) registrationStale() time.Duration {
+	if a.config.Aggregator.RegistrationStaleSeconds <= 0 {
+		return defaultRegistrationStaleSeconds * time.Second
+	}
+	return time.Duration(a.config.Aggregator.RegistrationStaleSeconds) * time.Second
+}
+
+// registrationTTL returns how long a registered node may go without a
+// heartbeat before it is removed, or zero if it is kept
+func (a *Aggregator) registrationTTL() time.Duration {
+	switch ttl := a.config.Aggregator.RegistrationTTLSeconds; {
+	case ttl < 0:
+		return 0
+	case ttl == 0:
+		return defaultRegistrationTTLSeconds * time.Second
+	default:
+		return time.Duration(ttl) * time.Second
+	}
+}
+
+// registerHandler adds a node that registers itself, or records a heartbeat
+// of a node that registered before. Registered nodes are polled like static
+// nodes until their heartbeats stop, and are not saved to the config file.
+func (a *Aggregator) registerHandler(w http.ResponseWriter, r *http.Request) {
+	configured, ok := bearerTokenMatches(r, a.config.Aggregator.RegistrationToken, a.config.Aggregator.AdminToken)
+	if !configured {
+		writeJSONError(w, http.StatusForbidden, "Forbidden: no registration_token or admin_token configured")
+		return
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var node NodeConfig
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNodeRequestSize)).Decode(&node); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	// Nodes behind NAT or with several addresses may leave the host to the
+	// aggregator
+	if node.Host == "" && node.Mode != NodeModePush {
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			node.Host = host
+		}
+	}
+	if err := validateNodeConfig(node); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid node: %v", err))
+		return
+	}
+
+	now := time.Now()
+	a.mutex.Lock()
+	index := slices.IndexFunc(a.registeredNodes, func(n NodeConfig) bool { return n.Name == node.Name })
+	if _, exists := a.nodes[node.Name]; exists && index < 0 {
+		a.mutex.Unlock()
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf("Node %s already exists and is not registered", node.Name))
+		return
+	}
+	changed := index < 0 || !reflect.DeepEqual(a.registeredNodes[index], node)
+	if changed {
+		// Copy so that snapshots of the previous list are not modified
+		registered := slices.Clone(a.registeredNodes)
+		if index < 0 {
+			registered = append(registered, node)
+		} else {
+			registered[index] = node
+		}
+		a.registeredNodes = registered
+		a.rebuildNodeList()
+	}
+	a.heartbeats[node.Name] = now
+	a.mutex.Unlock()
+
+	if changed {
+		event := "node_registered"
+		if index < 0 {
+			log.Printf("Node %s registered (%s:%d) from %s", node.Name, node.Host, node.Port, r.RemoteAddr)
+		} else {
+			log.Printf("Node %s re-registered (%s:%d) from %s", node.Name, node.Host, node.Port, r.RemoteAddr)
+			event = "node_reregistered"
+		}
+		node.PushToken = ""
+		node.AuthToken = ""
+		a.audit.record(event, NodeChangeEvent{Node: node.Name, Config: &node, RemoteAddr: r.RemoteAddr})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if index < 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(RegistrationResponse{
+		Name:                     node.Name,
+		HeartbeatIntervalSeconds: max(int(a.registrationStale()/time.Second/3), 1),
+	})
+}
+
+// heartbeatAge returns how long ago a registered node sent its last
+// heartbeat, and false if the node did not register itself
+func (a *Aggregator) heartbeatAge(nodeName string) (time.Duration, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	last, registered := a.heartbeats[nodeName]
+	return time.Since(last), registered
+}
+
+// checkRegisteredNode marks a registered node offline once its heartbeats
+// have stopped, and reports whether it is still alive and should be polled
+func (a *Aggregator) checkRegisteredNode(node NodeConfig) bool {
+	age, registered := a.heartbeatAge(node.Name)
+	if !registered || age < a.registrationStale() {
+		return true
+	}
+	if status, exists := a.node(node.Name); exists {
+		// Replace the error of the last poll, e.g. a refused connection of
+		// a node that was shut down, but only once
+		status.mutex.RLock()
+		marked := status.Status == "offline" && status.NodeError != nil && status.NodeError.Code == ErrTimeout
+		status.mutex.RUnlock()
+		if !marked {
+			a.updateNodeError(node.Name, "offline", ErrTimeout, "Stale: no heartbeat for "+(age.Round(time.Second)).String())
+		}
+	}
+	return false
+}
+
+// deadNodeTTL returns how long a registered push node may go without
+// pushing before it is removed, or zero if it is kept
+func (a *Aggregator) deadNodeTTL() time.Duration {
+	return time.Duration(max(a.config.Aggregator.DeadNodeTTLSeconds, 0)) * time.Second
+}
+
+// expiry returns why a registered node is expired at now, or "" if it is
+// not: its heartbeats stopped for longer than registration_ttl_seconds, or it
+// is a push node that has not pushed for longer than dead_node_ttl_seconds.
+// Must be called with the lock held.
+func (a *Aggregator) expiry(node NodeConfig, now time.Time) string {
+	heartbeat := a.heartbeats[node.Name]
+	if ttl := a.registrationTTL(); ttl > 0 && now.Sub(heartbeat) >= ttl {
+		return fmt.Sprintf("no heartbeat for %v", ttl)
+	}
+	ttl := a.deadNodeTTL()
+	if ttl == 0 || node.Mode != NodeModePush {
+		return ""
+	}
+	// Nodes that never pushed count from their registration
+	last := heartbeat
+	if status, exists := a.nodes[node.Name]; exists {
+		status.mutex.RLock()
+		if !status.LastSuccess.IsZero() {
+			last = status.LastSuccess
+		}
+		status.mutex.RUnlock()
+	}
+	if now.Sub(last) >= ttl {
+		return fmt.Sprintf("no data pushed for %v", ttl)
+	}
+	return ""
+}
+
+// expireRegisteredNodes removes the registered nodes that are expired at now.
+// Nodes in the config file are never removed.
+func (a *Aggregator) expireRegisteredNodes(now time.Time) {
+	if a.registrationTTL() == 0 && a.deadNodeTTL() == 0 {
+		return
+	}
+
+	expired := make(map[string]string)
+	a.mutex.Lock()
+	registered := make([]NodeConfig, 0, len(a.registeredNodes))
+	for _, node := range a.registeredNodes {
+		reason := a.expiry(node, now)
+		if reason == "" {
+			registered = append(registered, node)
+			continue
+		}
+		expired[node.Name] = reason
+		delete(a.heartbeats, node.Name)
+	}
+	if len(expired) > 0 {
+		a.registeredNodes = registered
+		a.rebuildNodeList()
+	}
+	a.mutex.Unlock()
+
+	for name, reason := range expired {
+		log.Printf("Node %s expired: %s", name, reason)
+		a.invalidateMetadata(name)
+		a.history.removeNode(name)
+	}
+}
+
+// unregisterNode removes a registered node, and reports whether it was
+// registered. Must be called with the lock held.
+func (a *Aggregator) unregisterNode(nodeName string) bool {
+	index := slices.IndexFunc(a.registeredNodes, func(n NodeConfig) bool { return n.Name == nodeName })
+	if index < 0 {
+		return false
+	}
+	a.registeredNodes = slices.Delete(slices.Clone(a.registeredNodes), index, index+1)
+	delete(a.heartbeats, nodeName)
+	a.rebuildNodeList()
+	return true
+}
+
+// runRegistrar registers this node with an aggregator and keeps sending
+// heartbeats, at the interval the aggregator asks for unless one is given
+func runRegistrar(url, token string, node NodeConfig, interval time.Duration) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	body, err := json.Marshal(node)
+	if err != nil {
+		log.Printf("Failed to encode registration: %v", err)
+		return
+	}
+
+	registered := false
+	for {
+		next := interval
+		resp, err := registerNode(client, url, token, body)
+		if err != nil {
+			log.Printf("Failed to register with %s: %v", url, err)
+			registered = false
+		} else {
+			if !registered {
+				log.Printf("Registered with %s as %s", url, resp.Name)
+				registered = true
+			}
+			if next <= 0 && resp.HeartbeatIntervalSeconds > 0 {
+				next = time.Duration(resp.HeartbeatIntervalSeconds) * time.Second
+			}
+		}
+		if next <= 0 {
+			next = defaultRegistrationStaleSeconds * time.Second / 3
+		}
+		time.Sleep(next)
+	}
+}
+
+func registerNode(client *http.Client, url, token string, body []byte) (*RegistrationResponse, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("HTTP error: %d: %s", resp.StatusCode, errorMessage(data))
+	}
+	var response RegistrationResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return &response, nil
+}
+
+// registrationConfig returns the node config a server registers itself with
+func registrationConfig(config ServerConfig, port, fingerprint string) (NodeConfig, error) {
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return NodeConfig{}, fmt.Errorf("invalid port %q", port)
+	}
+	node := NodeConfig{
+		Name:           config.RegisterName,
+		Host:           config.RegisterHost,
+		Port:           portNumber,
+		AuthToken:      config.AuthToken,
+		TLS:            config.TLSAuto || config.TLSCertFile != "",
+		TLSFingerprint: fingerprint,
+	}
+	if node.Name == "" {
+		node.Name = getHostname()
+	}
+	return node, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// register registers a node with the admin token
+func register(t *testing.T, a *Aggregator, node NodeConfig) {
+	t.Helper()
+	body, _ := json.Marshal(node)
+	r := httptest.NewRequest("POST", "/api/register", strings.NewReader(string(body)))
+	r.Header.Set("Authorization", "Bearer "+a.config.Aggregator.AdminToken)
+	recorder := httptest.NewRecorder()
+	a.registerHandler(recorder, r)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("registering %s: status = %d: %s", node.Name, recorder.Code, recorder.Body)
+	}
+}
+
+// push pushes data of a node with the admin token
+func push(t *testing.T, a *Aggregator, name string) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/nodes/"+name+"/push", strings.NewReader(`{"gpus": [{"id": "00000000:01:00.0"}]}`))
+	r.SetPathValue("name", name)
+	r.Header.Set("Authorization", "Bearer "+a.config.Aggregator.AdminToken)
+	recorder := httptest.NewRecorder()
+	a.nodePushHandler(recorder, r)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("pushing %s: status = %d: %s", name, recorder.Code, recorder.Body)
+	}
+}
+
+func TestDeadPushNodeTTL(t *testing.T) {
+	const ttl = time.Minute
+	config := &AggregatorConfig{Nodes: []NodeConfig{{Name: "static", Mode: NodeModePush}}}
+	config.Aggregator.AdminToken = "admin"
+	config.Aggregator.DeadNodeTTLSeconds = int(ttl / time.Second)
+	if err := prepareAggregatorConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	a := newAggregator(config, "", false)
+
+	register(t, a, NodeConfig{Name: "pushing", Mode: NodeModePush})
+	register(t, a, NodeConfig{Name: "silent", Mode: NodeModePush})
+	push(t, a, "static")
+	push(t, a, "pushing")
+	pushed := time.Now()
+	events := a.hub.subscribe()
+	defer a.hub.unsubscribe(events)
+
+	// Advance the clock to just before the TTL runs out
+	a.expireRegisteredNodes(pushed.Add(ttl - time.Second))
+	for _, name := range []string{"static", "pushing", "silent"} {
+		if _, exists := a.node(name); !exists {
+			t.Errorf("%s was removed before its TTL", name)
+		}
+	}
+
+	// The nodes stop pushing. The silent node never pushed, so its TTL counts
+	// from its registration.
+	a.expireRegisteredNodes(pushed.Add(ttl + time.Second))
+	for _, name := range []string{"pushing", "silent"} {
+		if _, exists := a.node(name); exists {
+			t.Errorf("%s was not removed after its TTL", name)
+		}
+	}
+	if _, exists := a.node("static"); !exists {
+		t.Error("static was removed, but nodes in the config file are kept")
+	}
+	if names := a.nodeConfigs(); len(names) != 1 || names[0].Name != "static" {
+		t.Errorf("node list = %v, want only static", names)
+	}
+
+	// Dashboards are told to drop the removed nodes
+	var removed []string
+	for len(events) > 0 {
+		message := <-events
+		if message.event == "node_removed" {
+			removed = append(removed, message.node)
+		}
+	}
+	slices.Sort(removed)
+	if !slices.Equal(removed, []string{"pushing", "silent"}) {
+		t.Errorf("node_removed events for %v, want [pushing silent]", removed)
+	}
+}
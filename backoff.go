@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Defaults of the flap suppression and backoff of failing nodes
+const (
+	defaultFailureThreshold  = 3
+	defaultRecoveryThreshold = 2
+	defaultMaxBackoffSeconds = 60
+)
+
+// failureThreshold returns the number of consecutive failed polls after
+// which an online node is marked offline or error
+func (a *Aggregator) failureThreshold() int {
+	if a.config.Aggregator.FailureThreshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return a.config.Aggregator.FailureThreshold
+}
+
+// recoveryThreshold returns the number of consecutive successful polls after
+// which a failed node is marked online again
+func (a *Aggregator) recoveryThreshold() int {
+	if a.config.Aggregator.RecoveryThreshold <= 0 {
+		return defaultRecoveryThreshold
+	}
+	return a.config.Aggregator.RecoveryThreshold
+}
+
+// pollBackoff returns the time to wait between polls of a node that failed
+// the given number of polls in a row, doubling from interval with every
+// failure after the failure threshold, or zero if the node is not backed off
+func (a *Aggregator) pollBackoff(failures int, interval time.Duration) time.Duration {
+	maxBackoff := time.Duration(a.config.Aggregator.MaxBackoffSeconds) * time.Second
+	switch {
+	case a.config.Aggregator.MaxBackoffSeconds < 0:
+		return 0
+	case a.config.Aggregator.MaxBackoffSeconds == 0:
+		maxBackoff = defaultMaxBackoffSeconds * time.Second
+	}
+	excess := failures - a.failureThreshold() + 1
+	if excess <= 0 {
+		return 0
+	}
+	// Doubling more often would overflow long before reaching any sane cap
+	backoff := interval << min(excess, 16)
+	return min(backoff, max(maxBackoff, interval))
+}
+
+// pollFailed records a failed poll of a node. An online node keeps its
+// status and last data until failure_threshold polls in a row have failed, so
+// that a single lost request does not mark it offline.
+func (a *Aggregator) pollFailed(nodeName, statusValue string, code MonitorErrorCode, errorMsg string) {
+	status, exists := a.node(nodeName)
+	if !exists {
+		return
+	}
+	status.mutex.Lock()
+	status.ConsecutiveSuccesses = 0
+	status.ConsecutiveFailures++
+	tolerated := status.Status == "online" && status.ConsecutiveFailures < a.failureThreshold()
+	if tolerated {
+		status.errorCounts[code]++
+		log.Printf("Node %s: poll failed (%d of %d before %s): %s",
+			nodeName, status.ConsecutiveFailures, a.failureThreshold(), statusValue, errorMsg)
+	}
+	status.mutex.Unlock()
+
+	if !tolerated {
+		a.updateNodeError(nodeName, statusValue, code, errorMsg)
+	}
+}
+
+// pollSucceeded records a successful poll of a node. A node that is offline
+// or in error is only marked online again after recovery_threshold polls in a
+// row have succeeded; the data of earlier polls is discarded.
+func (a *Aggregator) pollSucceeded(nodeName string, nodeInfo *NodeInfo, requestStart, received time.Time) {
+	status, exists := a.node(nodeName)
+	if !exists {
+		return
+	}
+	status.mutex.Lock()
+	status.ConsecutiveFailures = 0
+	status.ConsecutiveSuccesses++
+	status.BackoffUntil = nil
+	pending := (status.Status == "offline" || status.Status == "error") && status.ConsecutiveSuccesses < a.recoveryThreshold()
+	status.mutex.Unlock()
+
+	if !pending {
+		a.recordNodeInfo(nodeName, nodeInfo, requestStart, received)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath turns a base path such as "gpu/" or "/gpu/" into the
+// form "/gpu". The root path is returned as "".
+func normalizeBasePath(basePath string) (string, error) {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(basePath, "?#\"<> ") {
+		return "", fmt.Errorf("invalid base path %q", basePath)
+	}
+	return "/" + basePath, nil
+}
+
+// withBasePath serves handler under basePath: the prefix is stripped before
+// routing, the bare prefix is redirected to the dashboard and requests
+// outside the prefix are not found
+func withBasePath(basePath string, handler http.Handler) http.Handler {
+	if basePath == "" {
+		return handler
+	}
+	stripped := http.StripPrefix(basePath, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		// Only strip whole path segments, so that /gpux is not served as /x
+		if !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			writeJSONError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// indexHandler serves the embedded dashboard with a <base> tag pointing at
+// the base path, so that its relative API requests go through the prefix
+func indexHandler(basePath string) (http.Handler, error) {
+	page, err := indexHTML.ReadFile("index.html")
+	if err != nil {
+		return nil, err
+	}
+	baseTag := fmt.Sprintf("<head>\n    <base href=\"%s/\">", html.EscapeString(basePath))
+	page = bytes.Replace(page, []byte("<head>"), []byte(baseTag), 1)
+
+	files := http.FileServer(http.FS(indexHTML))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			files.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}), nil
+}
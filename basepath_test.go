@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		want     string
+		valid    bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{"gpu", "/gpu", true},
+		{"/gpu/", "/gpu", true},
+		{"ops/gpu", "/ops/gpu", true},
+		{"/gpu?x=1", "", false},
+		{"/g pu", "", false},
+		{`/"><script>`, "", false},
+	}
+	for _, test := range tests {
+		got, err := normalizeBasePath(test.basePath)
+		if (err == nil) != test.valid || got != test.want {
+			t.Errorf("normalizeBasePath(%q) = %q, %v; want %q, valid %v", test.basePath, got, err, test.want, test.valid)
+		}
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("nodes"))
+	})
+	mux.HandleFunc("GET /api/nodes/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("node " + r.PathValue("name")))
+	})
+	index, err := indexHandler("/gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/", index)
+	handler := withBasePath("/gpu", mux)
+
+	tests := []struct {
+		path     string
+		code     int
+		body     string // expected in the body
+		location string
+	}{
+		{"/gpu/api/nodes", http.StatusOK, "nodes", ""},
+		{"/gpu/api/nodes/gpu-01", http.StatusOK, "node gpu-01", ""},
+		{"/gpu/api/nodes?limit=1", http.StatusOK, "nodes", ""},
+		{"/gpu/", http.StatusOK, `<base href="/gpu/">`, ""},
+		{"/gpu/index.html", http.StatusOK, `<base href="/gpu/">`, ""},
+		{"/gpu", http.StatusMovedPermanently, "", "/gpu/"},
+		// Routes are only served under the prefix
+		{"/api/nodes", http.StatusNotFound, "", ""},
+		{"/", http.StatusNotFound, "", ""},
+		{"/gpux/api/nodes", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+		if recorder.Code != test.code {
+			t.Errorf("GET %s: status = %d, want %d", test.path, recorder.Code, test.code)
+			continue
+		}
+		if !strings.Contains(recorder.Body.String(), test.body) {
+			t.Errorf("GET %s: body does not contain %q", test.path, test.body)
+		}
+		if location := recorder.Header().Get("Location"); location != test.location {
+			t.Errorf("GET %s: Location = %q, want %q", test.path, location, test.location)
+		}
+	}
+}
+
+func TestWithoutBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("nodes"))
+	})
+	if handler := withBasePath("", mux); handler != http.Handler(mux) {
+		t.Error("the handler is wrapped without a base path")
+	}
+
+	index, err := indexHandler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	index.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(recorder.Body.String(), `<base href="/">`) {
+		t.Error(`dashboard is not served with <base href="/">`)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// runCheckNodes polls every configured node once, prints a reachability
+// table and exits non-zero if any node is not online. It is meant as a quick
+// connectivity check, e.g. in a deploy pipeline.
+func runCheckNodes(configFile string) {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := prepareAggregatorConfig(config); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	aggregator := newAggregator(config, configFile, false)
+	if config.ExternalNodesSource != "" {
+		aggregator.reloadExternalNodes()
+	}
+	aggregator.updateNodeStatuses()
+
+	nodes := aggregator.snapshotNodes()
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tADDRESS\tSTATUS\tLATENCY\tGPUS\tERROR")
+	for _, node := range nodes {
+		gpus, errText := "-", ""
+		if node.Status == "online" && node.Data != nil {
+			gpus = fmt.Sprint(len(node.Data.GPUs))
+		} else {
+			failed++
+		}
+		if node.NodeError != nil {
+			errText = node.NodeError.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s:%d\t%s\t%dms\t%s\t%s\n",
+			node.Name, node.Host, node.Port, node.Status, node.LastFetchDurationMs, gpus, errText)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Printf("%d of %d nodes not online\n", failed, len(nodes))
+		os.Exit(1)
+	}
+	fmt.Printf("All %d nodes online\n", len(nodes))
+}
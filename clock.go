@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// defaultMaxClockSkew is the clock skew between a node and the aggregator
+// above which a warning is raised
+const defaultMaxClockSkew = 5 * time.Second
+
+// clockSkew estimates how far a node's clock is ahead of the aggregator's
+// (negative if behind). The node timestamps its sample while answering, so it
+// is compared with the middle of the request to cancel out network latency.
+func clockSkew(nodeTime, requestStart, received time.Time) time.Duration {
+	midpoint := requestStart.Add(received.Sub(requestStart) / 2)
+	return nodeTime.Sub(midpoint)
+}
+
+// updateClockSkew records the clock skew of a node's latest sample and logs
+// when it starts or stops exceeding the threshold. Must be called with the
+// node's lock held.
+func (s *NodeStatus) updateClockSkew(skew, threshold time.Duration) {
+	s.ClockSkewSeconds = math.Round(skew.Seconds()*10) / 10
+
+	warning := ""
+	if skew > threshold || skew < -threshold {
+		direction := "ahead of"
+		if skew < 0 {
+			direction = "behind"
+		}
+		warning = fmt.Sprintf("node clock is %.1fs %s the aggregator", math.Abs(skew.Seconds()), direction)
+	}
+
+	if warning != "" && s.ClockSkewWarning == "" {
+		log.Printf("Warning: node %s: %s", s.Name, warning)
+	} else if warning == "" && s.ClockSkewWarning != "" {
+		log.Printf("Node %s: clock skew back within %v", s.Name, threshold)
+	}
+	s.ClockSkewWarning = warning
+}
@@ -0,0 +1,349 @@
+// Command gen-schema generates the JSON Schema and the Markdown reference of
+// the aggregator's config file from the //doc: comments of the config
+// structs. It is run by go generate in the repository root:
+//
+//	go run ./cmd/gen-schema
+//
+// Every exported field of the structs reachable from the root struct must
+// have a //doc: comment. A trailing "(default X)" in the comment, where X is
+// a JSON value, becomes the field's default, and a //doc:enum line lists the
+// values a string field accepts.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// field is a config field, or the root of the config
+type field struct {
+	Name    string // JSON name
+	Path    string // dotted JSON path, e.g. "aggregator.port" or "nodes[].name"
+	Type    string // JSON Schema type
+	Minimum *int   // 0 for unsigned integers
+	Doc     string
+	Default interface{}
+	Enum    []string
+	Items   *field   // element of arrays
+	Fields  []*field // members of objects
+}
+
+var defaultPattern = regexp.MustCompile(`\s*\(default (.+)\)$`)
+
+// schemaParser resolves the types of the package's config structs
+type schemaParser struct {
+	fset  *token.FileSet
+	types map[string]*ast.TypeSpec
+}
+
+func newSchemaParser(dir string) (*schemaParser, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	p := &schemaParser{fset: token.NewFileSet(), types: make(map[string]*ast.TypeSpec)}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(p.fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				p.types[typeSpec.Name.Name] = typeSpec
+			}
+		}
+	}
+	return p, nil
+}
+
+// parseRoot parses the named struct type as the root of the config
+func (p *schemaParser) parseRoot(name string) (*field, error) {
+	root := &field{}
+	if err := p.parseType(root, &ast.Ident{Name: name}); err != nil {
+		return nil, err
+	}
+	if root.Type != "object" {
+		return nil, fmt.Errorf("%s is not a struct", name)
+	}
+	return root, nil
+}
+
+// parseType sets the schema type of f from the Go type expr
+func (p *schemaParser) parseType(f *field, expr ast.Expr) error {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			f.Type = "string"
+		case "bool":
+			f.Type = "boolean"
+		case "int", "int8", "int16", "int32", "int64":
+			f.Type = "integer"
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			f.Type = "integer"
+			minimum := 0
+			f.Minimum = &minimum
+		case "float32", "float64":
+			f.Type = "number"
+		default:
+			spec, ok := p.types[t.Name]
+			if !ok {
+				return fmt.Errorf("%s: unknown type %s", f.Path, t.Name)
+			}
+			return p.parseType(f, spec.Type)
+		}
+	case *ast.StarExpr:
+		return p.parseType(f, t.X)
+	case *ast.ArrayType:
+		f.Type = "array"
+		f.Items = &field{Path: f.Path + "[]"}
+		return p.parseType(f.Items, t.Elt)
+	case *ast.StructType:
+		f.Type = "object"
+		return p.parseFields(f, t)
+	default:
+		return fmt.Errorf("%s: unsupported type %T", f.Path, expr)
+	}
+	return nil
+}
+
+// parseFields adds the JSON fields of a struct to f
+func (p *schemaParser) parseFields(f *field, s *ast.StructType) error {
+	for _, astField := range s.Fields.List {
+		if len(astField.Names) == 0 {
+			return fmt.Errorf("%s: embedded fields are not supported", p.fset.Position(astField.Pos()))
+		}
+		name := astField.Names[0].Name
+		if !ast.IsExported(name) {
+			continue
+		}
+		jsonName := name
+		if astField.Tag != nil {
+			tag := reflect.StructTag(strings.Trim(astField.Tag.Value, "`"))
+			if value, ok := tag.Lookup("json"); ok {
+				value, _, _ = strings.Cut(value, ",")
+				if value == "-" {
+					continue
+				}
+				if value != "" {
+					jsonName = value
+				}
+			}
+		}
+
+		child := &field{Name: jsonName, Path: jsonName}
+		if f.Path != "" {
+			child.Path = f.Path + "." + jsonName
+		}
+		if err := parseDoc(child, astField.Doc); err != nil {
+			return fmt.Errorf("%s: %v", p.fset.Position(astField.Pos()), err)
+		}
+		if err := p.parseType(child, astField.Type); err != nil {
+			return err
+		}
+		f.Fields = append(f.Fields, child)
+	}
+	return nil
+}
+
+// parseDoc sets the description, default and allowed values of f from the
+// //doc: lines of its comment
+func parseDoc(f *field, comments *ast.CommentGroup) error {
+	var doc []string
+	if comments != nil {
+		for _, comment := range comments.List {
+			if values, ok := strings.CutPrefix(comment.Text, "//doc:enum "); ok {
+				f.Enum = strings.Split(strings.TrimSpace(values), ",")
+			} else if text, ok := strings.CutPrefix(comment.Text, "//doc:"); ok {
+				doc = append(doc, strings.TrimSpace(text))
+			}
+		}
+	}
+	if len(doc) == 0 {
+		return fmt.Errorf("field %s has no //doc: comment", f.Path)
+	}
+	f.Doc = strings.Join(doc, " ")
+
+	if match := defaultPattern.FindStringSubmatch(f.Doc); match != nil {
+		if err := json.Unmarshal([]byte(match[1]), &f.Default); err != nil {
+			return fmt.Errorf("field %s: invalid default %s: %v", f.Path, match[1], err)
+		}
+		f.Doc = strings.TrimSuffix(f.Doc, match[0])
+	}
+	return nil
+}
+
+// schema is a JSON Schema of a config field
+type schema struct {
+	Schema               string      `json:"$schema,omitempty"`
+	Title                string      `json:"title,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Type                 string      `json:"type"`
+	Minimum              *int        `json:"minimum,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Default              interface{} `json:"default,omitempty"`
+	Items                *schema     `json:"items,omitempty"`
+	Properties           properties  `json:"properties,omitempty"`
+	AdditionalProperties *bool       `json:"additionalProperties,omitempty"`
+}
+
+// property is a named member of an object schema
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// properties encodes as a JSON object in the order of the struct fields
+type properties []property
+
+func (ps properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range ps {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(p.Name)
+		value, err := json.Marshal(p.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// enumValues returns the allowed values of a field. The empty string is
+// always allowed since unset fields select the default.
+func enumValues(enum []string) []string {
+	if enum == nil {
+		return nil
+	}
+	return append([]string{""}, enum...)
+}
+
+func (f *field) schema() *schema {
+	s := &schema{Description: f.Doc, Type: f.Type, Minimum: f.Minimum, Default: f.Default}
+	switch f.Type {
+	case "array":
+		s.Items = f.Items.schema()
+		// The allowed values apply to the elements of lists
+		s.Items.Enum = f.Enum
+	case "object":
+		closed := false
+		s.AdditionalProperties = &closed
+		for _, child := range f.Fields {
+			s.Properties = append(s.Properties, property{child.Name, child.schema()})
+		}
+	default:
+		s.Enum = enumValues(f.Enum)
+	}
+	return s
+}
+
+// flatten returns the fields below f in document order
+func (f *field) flatten() []*field {
+	var fields []*field
+	for _, child := range f.Fields {
+		fields = append(fields, child)
+		if child.Type == "array" {
+			fields = append(fields, child.Items.flatten()...)
+		}
+		fields = append(fields, child.flatten()...)
+	}
+	return fields
+}
+
+// TypeName returns the type of the field as shown in the reference
+func (f *field) TypeName() string {
+	if f.Type == "array" {
+		return "array of " + f.Items.TypeName()
+	}
+	return f.Type
+}
+
+// DefaultText returns the default of the field as JSON, or "" if it has none
+func (f *field) DefaultText() string {
+	if f.Default == nil {
+		return ""
+	}
+	text, _ := json.Marshal(f.Default)
+	return string(text)
+}
+
+var markdownTemplate = template.Must(template.New("CONFIG.md").Funcs(template.FuncMap{
+	"cell": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	"code": func(values []string) string {
+		return "`" + strings.Join(values, "`, `") + "`"
+	},
+}).Parse(`# Configuration reference
+
+<!-- Generated by cmd/gen-schema from the //doc: comments of the config structs; do not edit. -->
+
+The aggregator reads its configuration from the JSON file given by ` + "`-config`" + `.
+Editors that support JSON Schema can validate it against ` + "`config.schema.json`" + `.
+Fields that are not set use their default.
+
+| Field | Type | Default | Description |
+| --- | --- | --- | --- |
+{{range .}}| ` + "`{{.Path}}`" + ` | {{.TypeName}} | {{with .DefaultText}}` + "`{{cell .}}`" + `{{end}} | {{cell .Doc}}{{with .Enum}}. One of {{code .}}{{end}} |
+{{end}}`))
+
+func main() {
+	dir := flag.String("dir", ".", "Directory of the package with the config structs")
+	root := flag.String("root", "AggregatorConfig", "Config struct at the root of the config file")
+	schemaFile := flag.String("schema", "config.schema.json", "Path of the generated JSON Schema")
+	markdownFile := flag.String("markdown", "CONFIG.md", "Path of the generated Markdown reference")
+	flag.Parse()
+
+	p, err := newSchemaParser(*dir)
+	if err != nil {
+		log.Fatalf("Failed to parse package: %v", err)
+	}
+	config, err := p.parseRoot(*root)
+	if err != nil {
+		log.Fatalf("Failed to parse config: %v", err)
+	}
+
+	s := config.schema()
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.Title = "GPU Monitor aggregator configuration"
+	encoded, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode schema: %v", err)
+	}
+	if err := os.WriteFile(*schemaFile, append(encoded, '\n'), 0644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+
+	var markdown bytes.Buffer
+	if err := markdownTemplate.Execute(&markdown, config.flatten()); err != nil {
+		log.Fatalf("Failed to render reference: %v", err)
+	}
+	if err := os.WriteFile(*markdownFile, markdown.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write reference: %v", err)
+	}
+}
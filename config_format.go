@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of config files, detected by their extension
+const (
+	configFormatJSON = "json"
+	configFormatYAML = "yaml"
+	configFormatTOML = "toml"
+)
+
+// configFormat returns the format of a config file from its extension;
+// unknown extensions are read as JSON
+func configFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return configFormatYAML
+	case ".toml":
+		return configFormatTOML
+	default:
+		return configFormatJSON
+	}
+}
+
+// readConfigFile reads a JSON, YAML or TOML config file into v, a pointer.
+// YAML and TOML are converted to JSON first, so that all formats use the
+// JSON field names. Unknown fields are logged, since they are usually typos.
+func readConfigFile(filename string, v any) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if data, err = configToJSON(filename, data); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %v", filename, describeJSONError(data, err))
+	}
+
+	// Decode again into a scratch value to find fields that were ignored
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(reflect.New(reflect.TypeOf(v).Elem()).Interface()); err != nil {
+		log.Printf("Warning: %s: %s", filename, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// configToJSON converts a config file in the format of its extension to JSON
+func configToJSON(filename string, data []byte) ([]byte, error) {
+	var value any
+	switch configFormat(filename) {
+	case configFormatYAML:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	case configFormatTOML:
+		if _, err := toml.Decode(string(data), &value); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	default:
+		return data, nil
+	}
+	data, err := json.Marshal(jsonCompatible(value))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return data, nil
+}
+
+// jsonCompatible converts the maps with non-string keys that YAML decodes,
+// e.g. for numeric keys, to maps with string keys
+func jsonCompatible(value any) any {
+	switch value := value.(type) {
+	case map[any]any:
+		converted := make(map[string]any, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return converted
+	case map[string]any:
+		for key, item := range value {
+			value[key] = jsonCompatible(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = jsonCompatible(item)
+		}
+	}
+	return value
+}
+
+// describeJSONError adds the line and column to JSON syntax and type errors,
+// which only report a byte offset
+func describeJSONError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			err = fmt.Errorf("%s: expected %v, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+	default:
+		return err
+	}
+	// YAML and TOML were converted to a single line of JSON, where positions
+	// mean nothing to the user
+	if !bytes.Contains(data, []byte("\n")) {
+		return err
+	}
+	before := data[:min(int(offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %d, column %d: %v", line, column, err)
+}
+
+// rewriteConfigFile replaces a top-level field of a config file, keeping the
+// file's format and its other settings. Comments in the file are lost.
+func rewriteConfigFile(filename, field string, value any) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if data, err = configToJSON(filename, data); err != nil {
+		return err
+	}
+	var config map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+	if config == nil {
+		config = make(map[string]any)
+	}
+
+	// Convert value to the same generic form as the rest of the file
+	data, err = json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+	config[field] = generic
+
+	if data, err = encodeConfig(filename, config); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated config
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".config-*"+filepath.Ext(filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(filename); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// encodeConfig encodes a config, decoded into generic values with
+// json.Number numbers, in the format of the file's extension
+func encodeConfig(filename string, config map[string]any) ([]byte, error) {
+	switch configFormat(filename) {
+	case configFormatYAML:
+		// YAML would quote json.Number like a string
+		return yaml.Marshal(plainNumbers(config))
+	case configFormatTOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(plainNumbers(config)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+}
+
+// plainNumbers converts json.Number values to int64 or float64
+func plainNumbers(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]any:
+		for key, item := range value {
+			value[key] = plainNumbers(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = plainNumbers(item)
+		}
+	}
+	return value
+}
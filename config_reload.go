@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// defaultConfigWatchSeconds is the default interval of checks of the config
+// file for changes
+const defaultConfigWatchSeconds = 5
+
+// reloadConfig reads the config file again and applies its node list: new
+// nodes are added, removed ones dropped and changed ones updated, while
+// unchanged nodes keep their status. Other settings need a restart. If the
+// file is invalid, the current nodes are kept.
+func (a *Aggregator) reloadConfig() {
+	config, err := loadConfig(a.configFile)
+	if err == nil {
+		err = prepareAggregatorConfig(config)
+	}
+	if err != nil {
+		log.Printf("Failed to reload config from %s: %v", a.configFile, err)
+		a.audit.record("nodes_reloaded", NodesReloadEvent{Source: a.configFile, Error: err.Error()})
+		return
+	}
+
+	a.mutex.Lock()
+	if reflect.DeepEqual(a.config.Nodes, config.Nodes) {
+		// e.g. the file was written by -persist
+		a.mutex.Unlock()
+		return
+	}
+	previous := make(map[string]NodeConfig, len(a.config.Nodes))
+	for _, node := range a.config.Nodes {
+		previous[node.Name] = node
+	}
+	event := NodesReloadEvent{Source: a.configFile, Nodes: len(config.Nodes)}
+	for _, node := range config.Nodes {
+		if old, exists := previous[node.Name]; !exists {
+			event.Added = append(event.Added, node.Name)
+		} else if !reflect.DeepEqual(old, node) {
+			event.Changed = append(event.Changed, node.Name)
+		}
+		delete(previous, node.Name)
+	}
+	for name := range previous {
+		event.Removed = append(event.Removed, name)
+	}
+	a.config.Nodes = config.Nodes
+	a.rebuildNodeList()
+	a.mutex.Unlock()
+
+	for _, name := range event.Removed {
+		a.invalidateMetadata(name)
+		a.history.removeNode(name)
+	}
+	for _, name := range event.Changed {
+		a.invalidateMetadata(name)
+	}
+	log.Printf("Reloaded %d nodes from %s: %d added, %d removed, %d changed",
+		len(config.Nodes), a.configFile, len(event.Added), len(event.Removed), len(event.Changed))
+	a.audit.record("nodes_reloaded", event)
+}
+
+// watchConfig reloads the config file on SIGHUP and, unless disabled, when
+// its modification time changes
+func (a *Aggregator) watchConfig() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if a.config.ConfigWatchSeconds > 0 {
+		ticker := time.NewTicker(time.Duration(a.config.ConfigWatchSeconds) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	modified := configModTime(a.configFile)
+	for {
+		select {
+		case <-hangup:
+			log.Printf("Received SIGHUP, reloading config")
+		case <-tick:
+			// Editors may replace the file, so compare times rather than
+			// watching the file itself
+			current := configModTime(a.configFile)
+			if current.Equal(modified) {
+				continue
+			}
+			modified = current
+		}
+		a.reloadConfig()
+	}
+}
+
+// configModTime returns the modification time of the config file, or the
+// zero time if it cannot be read
+func configModTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
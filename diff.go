@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// DiffThresholds configures the minimum change of a GPU metric between two
+// poll cycles for it to be reported in a diff. Zero values use the defaults.
+type DiffThresholds struct {
+	//doc: Change of GPU utilization in percent (default 10)
+	Utilization float64 `json:"utilization"`
+	//doc: Change of GPU memory usage in MiB (default 512)
+	MemoryMiB float64 `json:"memory_mib"`
+	//doc: Change of GPU temperature in degrees C (default 5)
+	Temperature float64 `json:"temperature"`
+	//doc: Change of GPU power draw in W (default 20)
+	PowerWatts float64 `json:"power_watts"`
+}
+
+// withDefaults fills in the default thresholds for unset values
+func (t DiffThresholds) withDefaults() DiffThresholds {
+	if t.Utilization == 0 {
+		t.Utilization = 10
+	}
+	if t.MemoryMiB == 0 {
+		t.MemoryMiB = 512
+	}
+	if t.Temperature == 0 {
+		t.Temperature = 5
+	}
+	if t.PowerWatts == 0 {
+		t.PowerWatts = 20
+	}
+	return t
+}
+
+// NodeDiff represents what changed on a node between two poll cycles
+type NodeDiff struct {
+	NodeName         string            `json:"node_name"`
+	From             time.Time         `json:"from"`
+	To               time.Time         `json:"to"`
+	StatusFrom       string            `json:"status_from"`
+	StatusTo         string            `json:"status_to"`
+	StatusChanged    bool              `json:"status_changed"`
+	GPUsAdded        []string          `json:"gpus_added,omitempty"`
+	GPUsRemoved      []string          `json:"gpus_removed,omitempty"`
+	ProcessesStarted []ProcessChange   `json:"processes_started,omitempty"`
+	ProcessesEnded   []ProcessChange   `json:"processes_ended,omitempty"`
+	MetricChanges    []GPUMetricChange `json:"metric_changes,omitempty"`
+}
+
+// ProcessChange represents a process that started or ended on a GPU
+type ProcessChange struct {
+	GPUID string `json:"gpu_id"`
+	ProcessInfo
+}
+
+// GPUMetricChange represents a GPU metric that changed above its threshold
+type GPUMetricChange struct {
+	GPUID  string  `json:"gpu_id"`
+	Metric string  `json:"metric"`
+	From   float64 `json:"from"`
+	To     float64 `json:"to"`
+	Delta  float64 `json:"delta"`
+}
+
+// diff computes the changes between the previous and the current sample of a
+// node. Must be called with the node's lock held.
+func (s *NodeStatus) diff(thresholds DiffThresholds) NodeDiff {
+	d := NodeDiff{
+		NodeName:      s.Name,
+		From:          s.prevUpdate,
+		To:            s.LastUpdate,
+		StatusFrom:    s.prevStatus,
+		StatusTo:      s.Status,
+		StatusChanged: s.prevStatus != "" && s.prevStatus != s.Status,
+	}
+
+	// A node going offline or coming back makes every GPU appear or vanish,
+	// which the status transition already reports
+	if s.prevData == nil || s.Data == nil {
+		return d
+	}
+	prevGPUs, currGPUs := s.prevData.GPUs, s.Data.GPUs
+
+	prevByID := make(map[string]GPUInfo, len(prevGPUs))
+	for _, gpu := range prevGPUs {
+		prevByID[gpu.ID] = gpu
+	}
+
+	for _, curr := range currGPUs {
+		prev, exists := prevByID[curr.ID]
+		if !exists {
+			d.GPUsAdded = append(d.GPUsAdded, curr.ID)
+			continue
+		}
+		delete(prevByID, curr.ID)
+
+		d.MetricChanges = appendMetricChange(d.MetricChanges, curr.ID, "utilization",
+			prev.Utilization, curr.Utilization, thresholds.Utilization)
+		d.MetricChanges = appendMetricChange(d.MetricChanges, curr.ID, "memory_used_mib",
+			float64(prev.MemoryUsed)/(1024*1024), float64(curr.MemoryUsed)/(1024*1024), thresholds.MemoryMiB)
+		d.MetricChanges = appendMetricChange(d.MetricChanges, curr.ID, "temperature",
+			float64(prev.Temperature), float64(curr.Temperature), thresholds.Temperature)
+		d.MetricChanges = appendMetricChange(d.MetricChanges, curr.ID, "power_watts",
+			float64(prev.PowerUsage)/1000, float64(curr.PowerUsage)/1000, thresholds.PowerWatts)
+
+		// Processes are matched by PID
+		prevPIDs := make(map[uint32]bool, len(prev.Processes))
+		for _, proc := range prev.Processes {
+			prevPIDs[proc.PID] = true
+		}
+		currPIDs := make(map[uint32]bool, len(curr.Processes))
+		for _, proc := range curr.Processes {
+			currPIDs[proc.PID] = true
+			if !prevPIDs[proc.PID] {
+				d.ProcessesStarted = append(d.ProcessesStarted, ProcessChange{GPUID: curr.ID, ProcessInfo: proc})
+			}
+		}
+		for _, proc := range prev.Processes {
+			if !currPIDs[proc.PID] {
+				d.ProcessesEnded = append(d.ProcessesEnded, ProcessChange{GPUID: curr.ID, ProcessInfo: proc})
+			}
+		}
+	}
+
+	// Whatever is left was not reported in the current sample
+	for _, gpu := range prevGPUs {
+		if _, exists := prevByID[gpu.ID]; exists {
+			d.GPUsRemoved = append(d.GPUsRemoved, gpu.ID)
+		}
+	}
+
+	return d
+}
+
+func appendMetricChange(changes []GPUMetricChange, gpuID, metric string, from, to, threshold float64) []GPUMetricChange {
+	if math.Abs(to-from) < threshold {
+		return changes
+	}
+	return append(changes, GPUMetricChange{
+		GPUID:  gpuID,
+		Metric: metric,
+		From:   from,
+		To:     to,
+		Delta:  to - from,
+	})
+}
+
+func (a *Aggregator) nodeDiffHandler(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+	thresholds := a.config.Diff.withDefaults()
+
+	node, exists := a.node(nodeName)
+	var d NodeDiff
+	if exists {
+		node.mutex.RLock()
+		d = node.diff(thresholds)
+		node.mutex.RUnlock()
+	}
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+func (a *Aggregator) diffHandler(w http.ResponseWriter, r *http.Request) {
+	thresholds := a.config.Diff.withDefaults()
+
+	a.mutex.RLock()
+	// Return diffs in the order nodes appear in config
+	diffs := make([]NodeDiff, 0, len(a.nodeList))
+	for _, nodeConfig := range a.nodeList {
+		if node, exists := a.nodes[nodeConfig.Name]; exists {
+			node.mutex.RLock()
+			diffs = append(diffs, node.diff(thresholds))
+			node.mutex.RUnlock()
+		}
+	}
+	a.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffs)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ECCErrors represents the ECC error counters of a GPU in the nvidia-smi
+// XML output
+type ECCErrors struct {
+	Volatile  ECCCounts `xml:"volatile"`  // since the driver was loaded
+	Aggregate ECCCounts `xml:"aggregate"` // over the GPU's lifetime
+}
+
+// ECCCounts represents the correctable and uncorrectable ECC errors of a
+// GPU. Recent drivers split them by SRAM and DRAM, older ones report
+// single-bit and double-bit totals. Counters of GPUs without ECC are "N/A".
+type ECCCounts struct {
+	SRAMCorrectable   string `xml:"sram_correctable"`
+	SRAMUncorrectable string `xml:"sram_uncorrectable"`
+	DRAMCorrectable   string `xml:"dram_correctable"`
+	DRAMUncorrectable string `xml:"dram_uncorrectable"`
+
+	// Some drivers split sram_uncorrectable by parity and SEC-DED errors
+	SRAMUncorrectableParity string `xml:"sram_uncorrectable_parity"`
+	SRAMUncorrectableSECDED string `xml:"sram_uncorrectable_secded"`
+
+	SingleBitTotal string `xml:"single_bit>total"`
+	DoubleBitTotal string `xml:"double_bit>total"`
+}
+
+// RetiredPages represents the memory pages a GPU retired because of ECC
+// errors. Pages pending retirement are retired when the driver is reloaded.
+type RetiredPages struct {
+	SingleBit         string `xml:"multiple_single_bit_retirement>retired_count"`
+	DoubleBit         string `xml:"double_bit_retirement>retired_count"`
+	PendingRetirement string `xml:"pending_retirement"` // "Yes" or "No"
+}
+
+// ECCInfo represents the ECC error counts and retired pages of a GPU
+type ECCInfo struct {
+	VolatileCorrectable    uint64 `json:"volatile_correctable"` // since the driver was loaded
+	VolatileUncorrectable  uint64 `json:"volatile_uncorrectable"`
+	AggregateCorrectable   uint64 `json:"aggregate_correctable"` // over the GPU's lifetime
+	AggregateUncorrectable uint64 `json:"aggregate_uncorrectable"`
+	RetiredPagesSingleBit  uint64 `json:"retired_pages_single_bit"` // retired after multiple single-bit errors
+	RetiredPagesDoubleBit  uint64 `json:"retired_pages_double_bit"` // retired after a double-bit error
+	PendingRetirement      bool   `json:"pending_retirement"`       // until the driver is reloaded
+}
+
+// eccCount sums ECC counters, and reports whether any of them is a number
+func eccCount(values ...string) (uint64, bool) {
+	var total uint64
+	reported := false
+	for _, value := range values {
+		count, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		total += count
+		reported = true
+	}
+	return total, reported
+}
+
+// correctable returns the correctable errors, whichever way the driver
+// reports them
+func (c ECCCounts) correctable() (uint64, bool) {
+	return eccCount(c.SRAMCorrectable, c.DRAMCorrectable, c.SingleBitTotal)
+}
+
+// uncorrectable returns the uncorrectable errors, whichever way the driver
+// reports them
+func (c ECCCounts) uncorrectable() (uint64, bool) {
+	if strings.TrimSpace(c.SRAMUncorrectable) == "" {
+		return eccCount(c.SRAMUncorrectableParity, c.SRAMUncorrectableSECDED, c.DRAMUncorrectable, c.DoubleBitTotal)
+	}
+	return eccCount(c.SRAMUncorrectable, c.DRAMUncorrectable, c.DoubleBitTotal)
+}
+
+// parseECC returns the ECC error counts and retired pages of a GPU, or nil
+// if it reports neither, e.g. because it does not support ECC
+func parseECC(errors ECCErrors, pages RetiredPages) *ECCInfo {
+	info := &ECCInfo{PendingRetirement: strings.TrimSpace(pages.PendingRetirement) == "Yes"}
+	reported := info.PendingRetirement
+	for _, counter := range []struct {
+		value *uint64
+		count func() (uint64, bool)
+	}{
+		{&info.VolatileCorrectable, errors.Volatile.correctable},
+		{&info.VolatileUncorrectable, errors.Volatile.uncorrectable},
+		{&info.AggregateCorrectable, errors.Aggregate.correctable},
+		{&info.AggregateUncorrectable, errors.Aggregate.uncorrectable},
+		{&info.RetiredPagesSingleBit, func() (uint64, bool) { return eccCount(pages.SingleBit) }},
+		{&info.RetiredPagesDoubleBit, func() (uint64, bool) { return eccCount(pages.DoubleBit) }},
+	} {
+		var ok bool
+		*counter.value, ok = counter.count()
+		reported = reported || ok
+	}
+	if !reported {
+		return nil
+	}
+	return info
+}
+
+// checkPageRetirement flags the GPUs with pages pending retirement in the
+// node status, and logs and audits when a GPU starts or stops having them.
+// Must be called with the node's lock held.
+func (a *Aggregator) checkPageRetirement(status *NodeStatus, gpus []GPUInfo) {
+	var pending []string
+	previous := make(map[string]bool, len(status.PendingRetirementGPUs))
+	for _, id := range status.PendingRetirementGPUs {
+		previous[id] = true
+	}
+	for i := range gpus {
+		gpu := &gpus[i]
+		if gpu.ECC == nil {
+			continue
+		}
+		if gpu.ECC.PendingRetirement {
+			pending = append(pending, gpu.ID)
+		}
+		if gpu.ECC.PendingRetirement == previous[gpu.ID] {
+			continue
+		}
+		if gpu.ECC.PendingRetirement {
+			log.Printf("Warning: node %s GPU %s: memory pages pending retirement after %d uncorrectable ECC errors, reload the driver or reset the GPU to retire them",
+				status.Name, gpu.ID, gpu.ECC.AggregateUncorrectable)
+		} else {
+			log.Printf("Node %s GPU %s: no pages pending retirement", status.Name, gpu.ID)
+		}
+		a.audit.record("page_retirement", PageRetirementEvent{
+			Node:                   status.Name,
+			GPU:                    gpu.ID,
+			AggregateUncorrectable: gpu.ECC.AggregateUncorrectable,
+			RetiredPages:           gpu.ECC.RetiredPagesSingleBit + gpu.ECC.RetiredPagesDoubleBit,
+			Pending:                gpu.ECC.PendingRetirement,
+		})
+	}
+	status.PendingRetirementGPUs = pending
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultEventBufferSize is the number of process events kept when
+// aggregator.event_buffer_size is not set
+const defaultEventBufferSize = 1000
+
+// Types of process lifecycle events
+const (
+	ProcessStarted = "ProcessStarted"
+	ProcessExited  = "ProcessExited"
+)
+
+// ProcessEvent records a process appearing on or disappearing from a GPU
+// between two polls of a node
+type ProcessEvent struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"` // ProcessStarted or ProcessExited
+	Node  string    `json:"node"`
+	GPUID string    `json:"gpu_id"`
+	PID   uint32    `json:"pid"`
+	Name  string    `json:"name"`
+	Used  uint64    `json:"used"` // memory in bytes when last seen
+}
+
+// eventLog is a fixed-size ring buffer of the most recent process events
+type eventLog struct {
+	mutex  sync.Mutex
+	events []ProcessEvent
+	next   int // index the next event is written to once the buffer is full
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{events: make([]ProcessEvent, 0, size)}
+}
+
+// add appends events, overwriting the oldest ones when the buffer is full
+func (l *eventLog) add(events ...ProcessEvent) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, event := range events {
+		if len(l.events) < cap(l.events) {
+			l.events = append(l.events, event)
+			continue
+		}
+		l.events[l.next] = event
+		l.next = (l.next + 1) % len(l.events)
+	}
+}
+
+// list returns the most recent events matching the filters, oldest first. A
+// limit of 0 means no limit.
+func (l *eventLog) list(node, eventType string, limit int) []ProcessEvent {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	events := []ProcessEvent{}
+	for i := range l.events {
+		event := l.events[(l.next+i)%len(l.events)]
+		if (node != "" && event.Node != node) || (eventType != "" && event.Type != eventType) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// processEvents converts the process changes of a node diff into events
+func processEvents(d NodeDiff) []ProcessEvent {
+	events := make([]ProcessEvent, 0, len(d.ProcessesStarted)+len(d.ProcessesEnded))
+	for _, change := range d.ProcessesStarted {
+		events = append(events, newProcessEvent(ProcessStarted, d, change))
+	}
+	for _, change := range d.ProcessesEnded {
+		events = append(events, newProcessEvent(ProcessExited, d, change))
+	}
+	return events
+}
+
+func newProcessEvent(eventType string, d NodeDiff, change ProcessChange) ProcessEvent {
+	return ProcessEvent{
+		Time:  d.To,
+		Type:  eventType,
+		Node:  d.NodeName,
+		GPUID: change.GPUID,
+		PID:   change.PID,
+		Name:  change.Name,
+		Used:  change.Used,
+	}
+}
+
+// eventsHandler returns the recent process events, or streams events to
+// clients that accept server-sent events
+func (a *Aggregator) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if wantsEventStream(r) {
+		a.eventStreamHandler(w, r)
+		return
+	}
+	query := r.URL.Query()
+	eventType := query.Get("type")
+	if eventType != "" && eventType != ProcessStarted && eventType != ProcessExited {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid type parameter: %q", eventType))
+		return
+	}
+	limit := 100
+	if query.Has("limit") {
+		var err error
+		if limit, err = parseNonNegativeInt(query.Get("limit")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit parameter: %v", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.events.list(query.Get("node"), eventType, limit))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// isGPUSelected reports whether a GPU is reported: it must match one of the
+// GPUs patterns if any are set, and none of the ExcludeGPUIDs patterns.
+// Patterns may name the GPU's index, PCI bus ID or UUID.
+func isGPUSelected(index int, gpu GPU) bool {
+	matches := func(pattern string) bool {
+		return matchPattern(pattern, strconv.Itoa(index)) ||
+			matchPattern(pattern, gpu.ID) ||
+			matchPattern(pattern, gpu.UUID)
+	}
+
+	selected := len(serverConfig.GPUs) == 0
+	for _, pattern := range serverConfig.GPUs {
+		if matches(pattern) {
+			selected = true
+			break
+		}
+	}
+	if !selected {
+		return false
+	}
+	for _, pattern := range serverConfig.ExcludeGPUIDs {
+		if matches(pattern) {
+			return false
+		}
+	}
+	return true
+}
+
+// isProcessExcluded reports whether a process matches one of the
+// ExcludeProcessNames patterns. nvidia-smi reports either the full path or
+// the command line, so patterns are matched against both the name and the
+// base name of its executable.
+func isProcessExcluded(name string) bool {
+	executable := name
+	if fields := strings.Fields(name); len(fields) > 0 {
+		executable = fields[0]
+	}
+	base := path.Base(executable)
+	for _, pattern := range serverConfig.ExcludeProcessNames {
+		if matchPattern(pattern, name) || matchPattern(pattern, executable) || matchPattern(pattern, base) {
+			return true
+		}
+	}
+	return false
+}
+
+// regexPatternPrefix marks a pattern as a regular expression instead of a glob
+const regexPatternPrefix = "re:"
+
+// matchPattern matches a value against a glob pattern such as "Xorg",
+// "python*" or "GPU-1234*", or against a regular expression given as
+// "re:<expr>". Malformed patterns only match literally.
+func matchPattern(pattern, value string) bool {
+	if pattern == value {
+		return true
+	}
+	if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+		re, err := regexp.Compile(expr)
+		return err == nil && re.MatchString(value)
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// validatePatterns checks that all GPU and process patterns are well-formed
+func validatePatterns(config ServerConfig) error {
+	for _, patterns := range [][]string{config.GPUs, config.ExcludeGPUIDs, config.ExcludeProcessNames} {
+		for _, pattern := range patterns {
+			var err error
+			if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+				_, err = regexp.Compile(expr)
+			} else {
+				_, err = path.Match(pattern, "")
+			}
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// filterGPUs removes the GPUs that are not selected from the parsed GPU info.
+// gpus must be in the same order as smiOutput.GPUs.
+func filterGPUs(smiOutput *SMIOutput, gpus []GPUInfo) []GPUInfo {
+	if len(serverConfig.GPUs) == 0 && len(serverConfig.ExcludeGPUIDs) == 0 {
+		return gpus
+	}
+
+	filtered := make([]GPUInfo, 0, len(gpus))
+	for i, gpu := range gpus {
+		if isGPUSelected(i, smiOutput.GPUs[i]) {
+			filtered = append(filtered, gpu)
+		}
+	}
+	return filtered
+}
+
+// isGPUActive reports whether a GPU is doing any work
+func isGPUActive(gpu GPUInfo) bool {
+	return gpu.Utilization > 0 || gpu.MemoryUsed > 0
+}
+
+// filterGPUsByActivity returns the GPUs selected by a filter: "active" for
+// GPUs with utilization or memory in use, "idle" for the rest, or "" for all
+func filterGPUsByActivity(gpus []GPUInfo, filter string) ([]GPUInfo, error) {
+	if filter == "" {
+		return gpus, nil
+	}
+	if filter != "active" && filter != "idle" {
+		return nil, fmt.Errorf("unknown filter %q, use 'active' or 'idle'", filter)
+	}
+
+	filtered := make([]GPUInfo, 0, len(gpus))
+	for _, gpu := range gpus {
+		if isGPUActive(gpu) == (filter == "active") {
+			filtered = append(filtered, gpu)
+		}
+	}
+	return filtered, nil
+}
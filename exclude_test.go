@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// setServerConfig replaces the server config for the duration of a test
+func setServerConfig(t *testing.T, config ServerConfig) {
+	t.Helper()
+	saved := serverConfig
+	t.Cleanup(func() { serverConfig = saved })
+	serverConfig = config
+}
+
+func TestIsGPUSelected(t *testing.T) {
+	gpu := GPU{ID: "00000000:47:00.0", UUID: "GPU-00000002-1c2d-4e5f-8a9b-0c1d2e3f4a5b"}
+
+	tests := []struct {
+		name    string
+		gpus    []string
+		exclude []string
+		want    bool
+	}{
+		{"no patterns", nil, nil, true},
+		{"excluded by index", nil, []string{"2"}, false},
+		{"other index", nil, []string{"0", "1"}, true},
+		{"excluded by bus ID", nil, []string{"00000000:47:00.0"}, false},
+		{"excluded by UUID glob", nil, []string{"GPU-00000002*"}, false},
+		{"excluded by regex", nil, []string{"re:^GPU-0+2-"}, false},
+		{"glob matches whole value", nil, []string{"GPU-0000000"}, true},
+		{"selected", []string{"2"}, nil, true},
+		{"not selected", []string{"0"}, nil, false},
+		{"exclusion wins over selection", []string{"GPU-*"}, []string{"2"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setServerConfig(t, ServerConfig{GPUs: test.gpus, ExcludeGPUIDs: test.exclude})
+			if got := isGPUSelected(2, gpu); got != test.want {
+				t.Errorf("isGPUSelected = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsProcessExcluded(t *testing.T) {
+	patterns := []string{"Xorg", "gnome-*", "re:^/opt/.*/viz$"}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Xorg", true},
+		{"/usr/lib/xorg/Xorg", true},
+		{"/usr/lib/xorg/Xorg -core :0", true},
+		{"Xorgx", false},
+		{"gnome-shell", true},
+		{"/usr/bin/gnome-shell", true},
+		{"/opt/tools/viz", true},
+		{"/usr/bin/viz", false},
+		{"python train.py", false},
+		{"", false},
+	}
+	setServerConfig(t, ServerConfig{ExcludeProcessNames: patterns})
+	for _, test := range tests {
+		if got := isProcessExcluded(test.name); got != test.want {
+			t.Errorf("isProcessExcluded(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExclusionWithNvidiaSmi(t *testing.T) {
+	useMockNvidiaSmi(t, "normal_4gpu.xml")
+	setServerConfig(t, ServerConfig{
+		ExcludeGPUIDs:       []string{"1", "GPU-00000002*"},
+		ExcludeProcessNames: []string{"python*"},
+	})
+
+	gpus, err := getGPUInfoFromNvidiaSmi(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpus) != 2 || gpus[0].UUID != "GPU-00000000-1c2d-4e5f-8a9b-0c1d2e3f4a5b" || gpus[1].UUID != "GPU-00000003-1c2d-4e5f-8a9b-0c1d2e3f4a5b" {
+		t.Fatalf("got %d GPUs %v, want GPUs 0 and 3", len(gpus), gpus)
+	}
+	for _, gpu := range gpus {
+		for _, proc := range gpu.Processes {
+			t.Errorf("GPU %d: excluded process %q is reported", gpu.Index, proc.Name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNvidiaSmiTimeout(t *testing.T) {
+	// A hanging nvidia-smi with a child that would outlive it
+	dir := t.TempDir()
+	alive := filepath.Join(dir, "alive")
+	script := filepath.Join(dir, "nvidia-smi")
+	err := os.WriteFile(script, []byte("#!/bin/sh\n(sleep 1; touch "+alive+") &\nsleep 30\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := serverConfig
+	t.Cleanup(func() { serverConfig = saved })
+	serverConfig.NvidiaSmiPath = script
+	serverConfig.SMITimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	_, err = getGPUInfoFromNvidiaSmi(context.Background())
+	elapsed := time.Since(start)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Fatalf("error = %v, want a timeout", err)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("took %v to time out", elapsed)
+	}
+
+	// The whole process group is killed, so the child never gets to run on
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(alive); err == nil {
+		t.Error("the child of nvidia-smi was not killed")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PushExportConfig configures periodic pushing of metrics to a remote system
+// for environments where the aggregator cannot be scraped
+type PushExportConfig struct {
+	//doc: Remote system to push to; pushing is disabled when empty
+	//doc:enum influxdb_v2,prometheus_pushgateway
+	Type string `json:"type"`
+	//doc: Base URL of the remote system
+	URL string `json:"url"`
+	//doc: Token sent as an InfluxDB API token or as the Pushgateway's bearer token
+	Token string `json:"token"`
+	//doc: InfluxDB organization
+	Org string `json:"org"`
+	//doc: InfluxDB bucket, required for influxdb_v2
+	Bucket string `json:"bucket"`
+	//doc: Pushgateway job name (default "gpu_monitor")
+	Job string `json:"job"`
+	//doc: Seconds between pushes (default 60)
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// maxPushBackoff caps the delay between attempts while the remote is failing
+const maxPushBackoff = 10 * time.Minute
+
+// validatePushExport checks the push export configuration
+func validatePushExport(config PushExportConfig) error {
+	switch config.Type {
+	case "":
+		return nil
+	case "influxdb_v2":
+		if config.Bucket == "" {
+			return fmt.Errorf("push_export: bucket is required for influxdb_v2")
+		}
+	case "prometheus_pushgateway":
+	default:
+		return fmt.Errorf("push_export: unknown type %q", config.Type)
+	}
+	if config.URL == "" {
+		return fmt.Errorf("push_export: url is required")
+	}
+	return nil
+}
+
+// startPushExporter pushes metrics on the configured interval, backing off
+// exponentially while the remote endpoint is unavailable
+func startPushExporter(a *Aggregator) {
+	config := a.config.PushExport
+	interval := time.Duration(config.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 60 * time.Second
+	}
+
+	wait := interval
+	for {
+		time.Sleep(wait)
+
+		if err := pushMetrics(a.client, config, a.snapshotNodes()); err != nil {
+			wait = min(wait*2, max(maxPushBackoff, interval))
+			log.Printf("Failed to push metrics to %s, retrying in %v: %v", config.Type, wait, err)
+			continue
+		}
+		wait = interval
+	}
+}
+
+// pushMetrics sends one batch of metrics to the configured endpoint
+func pushMetrics(client *http.Client, config PushExportConfig, nodes []NodeStatus) error {
+	var body bytes.Buffer
+	var req *http.Request
+	var err error
+
+	switch config.Type {
+	case "influxdb_v2":
+		writeInfluxLineProtocol(&body, nodes)
+		query := url.Values{"bucket": {config.Bucket}, "precision": {"ns"}}
+		if config.Org != "" {
+			query.Set("org", config.Org)
+		}
+		endpoint := strings.TrimSuffix(config.URL, "/") + "/api/v2/write?" + query.Encode()
+		req, err = http.NewRequest("POST", endpoint, &body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if config.Token != "" {
+			req.Header.Set("Authorization", "Token "+config.Token)
+		}
+	case "prometheus_pushgateway":
+		writePrometheusText(&body, gpuMetricFamilies(nodes))
+		job := config.Job
+		if job == "" {
+			job = "gpu_monitor"
+		}
+		endpoint := strings.TrimSuffix(config.URL, "/") + "/metrics/job/" + url.PathEscape(job)
+		req, err = http.NewRequest("PUT", endpoint, &body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+		if config.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+config.Token)
+		}
+	default:
+		return fmt.Errorf("unknown push export type: %s", config.Type)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP error: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// writeInfluxLineProtocol writes the same metrics as the Prometheus
+// serialization, one point per sample, timestamped with the time the
+// aggregator received the sample so that node clock skew does not matter.
+// Nodes that are not online are written with the current time and without
+// their last GPU data, so that their status point does not land on, and
+// overwrite, the time of their last successful poll.
+func writeInfluxLineProtocol(w io.Writer, nodes []NodeStatus) {
+	nodes = slices.Clone(nodes)
+	timestamps := make(map[string]time.Time, len(nodes))
+	for i, node := range nodes {
+		if node.Status != "online" {
+			nodes[i].Data = nil
+			continue
+		}
+		timestamps[node.Name] = node.LastSuccess
+	}
+
+	for _, family := range gpuMetricFamilies(nodes) {
+		for _, sample := range family.Samples {
+			var timestamp time.Time
+			tags := make([]string, 0, len(sample.Labels))
+			for _, label := range sample.Labels {
+				if label.Name == "node" {
+					timestamp = timestamps[label.Value]
+				}
+				// Empty tag values are not allowed in line protocol
+				if label.Value != "" {
+					tags = append(tags, label.Name+"="+influxTagEscaper.Replace(label.Value))
+				}
+			}
+			if timestamp.IsZero() {
+				timestamp = time.Now()
+			}
+
+			fmt.Fprintf(w, "%s,%s value=%s %d\n", family.sampleName(sample), strings.Join(tags, ","), formatFloat(sample.Value), timestamp.UnixNano())
+		}
+	}
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultExporterPort is the port commonly used by NVIDIA GPU exporters
+const defaultExporterPort = "9835"
+
+// gpuExporter serves the local GPUs as Prometheus metrics, with the same
+// names and labels as the aggregator's /metrics. nvidia-smi is run on every
+// scrape. It serves /metrics in both the exporter and the server mode.
+type gpuExporter struct {
+	mutex  sync.Mutex
+	status NodeStatus
+}
+
+func newGPUExporter() *gpuExporter {
+	name := getHostname()
+	return &gpuExporter{status: NodeStatus{NodeConfig: NodeConfig{Name: name, Alias: name}}}
+}
+
+func (e *gpuExporter) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	nodeInfo, err := getNodeInfoFromNvidiaSmi(r.Context(), true)
+
+	e.mutex.Lock()
+	e.status.LastUpdate = time.Now().UTC()
+	if err != nil {
+		log.Printf("Failed to get GPU info: %v", err)
+		e.status.Status = "error"
+		e.status.Data = nil
+	} else {
+		e.status.Status = "online"
+		e.status.LastSuccess = e.status.LastUpdate
+		e.status.Data = nodeInfo
+		e.status.updateUtilizationEMA(nodeInfo.GPUs, defaultUtilizationEMAAlpha)
+	}
+	status := e.status
+	e.mutex.Unlock()
+
+	writeMetrics(w, r, gpuMetricFamilies([]NodeStatus{status}))
+}
+
+// runExporter runs a standalone Prometheus exporter of the local GPUs
+func runExporter(port string, config ServerConfig) {
+	if port == "" {
+		port = defaultExporterPort
+	}
+	initServer(config)
+
+	exporter := newGPUExporter()
+	http.HandleFunc("/metrics", exporter.metricsHandler)
+
+	fmt.Printf("GPU exporter starting on port %s\n", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// isURL reports whether an external nodes source is a URL rather than a file
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// loadExternalNodes reads a node list in the format of the "nodes" config
+// section from a file or a URL
+func (a *Aggregator) loadExternalNodes(source string) ([]NodeConfig, error) {
+	var data []byte
+	if isURL(source) {
+		resp, err := a.client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var nodes []NodeConfig
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to parse node list: %v", err)
+	}
+	return nodes, nil
+}
+
+// setExternalNodes replaces the external nodes with the given list
+func (a *Aggregator) setExternalNodes(external []NodeConfig) {
+	a.mutex.Lock()
+	a.externalNodes = external
+	a.rebuildNodeList()
+	a.mutex.Unlock()
+}
+
+// rebuildNodeList merges the static, external and registered nodes into the
+// node list. External and registered nodes whose name is already taken by an
+// earlier node are rejected with a warning. Statuses of nodes that are
+// still present are kept, and the clients of changed nodes are rebuilt. Must
+// be called with the lock held.
+func (a *Aggregator) rebuildNodeList() {
+	nodeList := make([]NodeConfig, 0, len(a.config.Nodes)+len(a.externalNodes))
+	names := make(map[string]bool, cap(nodeList))
+	for _, node := range a.config.Nodes {
+		nodeList = append(nodeList, node)
+		names[node.Name] = true
+	}
+	for _, node := range a.externalNodes {
+		if node.Name == "" {
+			log.Printf("Warning: ignoring external node without a name (host %q)", node.Host)
+			continue
+		}
+		if names[node.Name] {
+			log.Printf("Warning: ignoring external node %s: name conflicts with an existing node", node.Name)
+			continue
+		}
+		nodeList = append(nodeList, node)
+		names[node.Name] = true
+	}
+	for _, node := range a.registeredNodes {
+		if names[node.Name] {
+			log.Printf("Warning: ignoring registered node %s: name conflicts with an existing node", node.Name)
+			continue
+		}
+		nodeList = append(nodeList, node)
+		names[node.Name] = true
+	}
+
+	for name := range a.nodes {
+		if !names[name] {
+			delete(a.nodes, name)
+			a.removeClient(name)
+			log.Printf("Node %s removed", name)
+			a.audit.record("node_removed", NodeChangeEvent{Node: name})
+			a.hub.publish("node_removed", name, NodeChangeEvent{Node: name})
+		}
+	}
+	for _, node := range nodeList {
+		if status, exists := a.nodes[node.Name]; exists {
+			status.mutex.Lock()
+			changed := !reflect.DeepEqual(status.NodeConfig, node)
+			status.NodeConfig = node
+			if changed {
+				// Poll a node that may have been fixed right away
+				status.ConsecutiveFailures = 0
+				status.BackoffUntil = nil
+				status.lastPollStart = time.Time{}
+			}
+			status.mutex.Unlock()
+			if changed {
+				// Clients are built with the node's TLS and token settings
+				a.removeClient(node.Name)
+			}
+		} else {
+			a.nodes[node.Name] = &nodeEntry{NodeStatus: NodeStatus{
+				NodeConfig: node,
+				Status:     "unknown",
+			}}
+		}
+	}
+	a.nodeList = nodeList
+}
+
+// reloadExternalNodes loads the external nodes source. On failure the current
+// node list is kept.
+func (a *Aggregator) reloadExternalNodes() {
+	source := a.config.ExternalNodesSource
+	nodes, err := a.loadExternalNodes(source)
+	if err != nil {
+		log.Printf("Failed to load external nodes from %s: %v", source, err)
+		a.audit.record("nodes_reloaded", NodesReloadEvent{Source: source, Error: err.Error()})
+		return
+	}
+	a.setExternalNodes(nodes)
+	a.audit.record("nodes_reloaded", NodesReloadEvent{Source: source, Nodes: len(nodes)})
+	log.Printf("Loaded %d external nodes from %s", len(nodes), source)
+}
+
+// watchExternalNodes reloads the external nodes source on SIGHUP and, for
+// URLs, every external_nodes_poll_minutes
+func (a *Aggregator) watchExternalNodes() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if isURL(a.config.ExternalNodesSource) {
+		ticker := time.NewTicker(time.Duration(a.config.ExternalNodesPollMinutes) * time.Minute)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hangup:
+			log.Printf("Received SIGHUP, reloading external nodes")
+		case <-tick:
+		}
+		a.reloadExternalNodes()
+	}
+}
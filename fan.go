@@ -0,0 +1,114 @@
+package main
+
+import "log"
+
+// fanHealthSamples is the number of recent polls the fan health check looks at
+const fanHealthSamples = 10
+
+// Thresholds of the fan health check: the temperature rising faster than
+// this while the fan speed rises slower suggests a failed fan
+const (
+	fanCheckTemperatureSlope = 0.5 // degrees C per sample
+	fanCheckFanSpeedSlope    = 1.0 // percent per sample
+)
+
+// FanHealthStatus is the result of the fan health check of a GPU
+type FanHealthStatus string
+
+const (
+	FanHealthUnknown          FanHealthStatus = ""
+	FanHealthOK               FanHealthStatus = "ok"
+	FanHealthSuspectedFailure FanHealthStatus = "suspected_failure"
+)
+
+// GPUSample is the temperature and fan speed of a GPU at one poll
+type GPUSample struct {
+	Temperature float64 // degrees C
+	FanSpeed    float64 // percent
+}
+
+// FanHealthCheck reports a suspected fan failure when the temperature keeps
+// rising over the samples, oldest first, while the fan does not speed up.
+// The result is unknown until enough samples are available.
+func FanHealthCheck(history []GPUSample) FanHealthStatus {
+	if len(history) < fanHealthSamples {
+		return FanHealthUnknown
+	}
+	history = history[len(history)-fanHealthSamples:]
+
+	temperatures := make([]float64, len(history))
+	fanSpeeds := make([]float64, len(history))
+	for i, sample := range history {
+		temperatures[i] = sample.Temperature
+		fanSpeeds[i] = sample.FanSpeed
+	}
+	// A fan already at full speed cannot speed up any further
+	if fanSpeeds[len(fanSpeeds)-1] >= 100 {
+		return FanHealthOK
+	}
+	if slope(temperatures) > fanCheckTemperatureSlope && slope(fanSpeeds) < fanCheckFanSpeedSlope {
+		return FanHealthSuspectedFailure
+	}
+	return FanHealthOK
+}
+
+// slope returns the least-squares slope of values over their index
+func slope(values []float64) float64 {
+	n := float64(len(values))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+// checkFanHealth records the latest sample of each GPU with a fan, sets its
+// fan health status and logs and audits when a suspected failure starts or
+// clears. Passively cooled GPUs report no fan speed and are not checked. Must
+// be called with the node's lock held.
+func (a *Aggregator) checkFanHealth(status *NodeStatus, gpus []GPUInfo) {
+	history := make(map[string][]GPUSample, len(gpus))
+	for i := range gpus {
+		gpu := &gpus[i]
+		if gpu.FanSpeed == nil {
+			continue
+		}
+		samples := append(status.fanHistory[gpu.ID], GPUSample{
+			Temperature: float64(gpu.Temperature),
+			FanSpeed:    *gpu.FanSpeed,
+		})
+		if len(samples) > fanHealthSamples {
+			samples = samples[len(samples)-fanHealthSamples:]
+		}
+		history[gpu.ID] = samples
+
+		previous := FanHealthCheck(status.fanHistory[gpu.ID])
+		gpu.FanHealthStatus = FanHealthCheck(samples)
+		if (gpu.FanHealthStatus == FanHealthSuspectedFailure) != (previous == FanHealthSuspectedFailure) {
+			suspected := gpu.FanHealthStatus == FanHealthSuspectedFailure
+			if suspected {
+				log.Printf("Warning: node %s GPU %s: temperature rising to %d°C while the fan stays at %.0f%%, suspected fan failure",
+					status.Name, gpu.ID, gpu.Temperature, *gpu.FanSpeed)
+			} else {
+				log.Printf("Node %s GPU %s: fan responding again", status.Name, gpu.ID)
+			}
+			a.audit.record("fan_health", FanHealthEvent{
+				Node:        status.Name,
+				GPU:         gpu.ID,
+				Temperature: gpu.Temperature,
+				FanSpeed:    *gpu.FanSpeed,
+				Suspected:   suspected,
+			})
+		}
+	}
+	// GPUs that disappeared start over when they come back
+	status.fanHistory = history
+}
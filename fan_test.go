@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+// fanSamples returns count samples starting at the given temperature and
+// fan speed, which change by the given amounts per sample
+func fanSamples(count int, temperature, temperatureStep, fanSpeed, fanSpeedStep float64) []GPUSample {
+	samples := make([]GPUSample, count)
+	for i := range samples {
+		samples[i] = GPUSample{
+			Temperature: temperature + float64(i)*temperatureStep,
+			FanSpeed:    fanSpeed + float64(i)*fanSpeedStep,
+		}
+	}
+	return samples
+}
+
+func TestFanHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []GPUSample
+		want    FanHealthStatus
+	}{
+		{"rising temperature, flat fan", fanSamples(10, 60, 1, 40, 0), FanHealthSuspectedFailure},
+		{"rising temperature, slowly rising fan", fanSamples(10, 60, 1, 40, 0.5), FanHealthSuspectedFailure},
+		{"rising temperature, rising fan", fanSamples(10, 60, 1, 40, 2), FanHealthOK},
+		{"slowly rising temperature, flat fan", fanSamples(10, 60, 0.4, 40, 0), FanHealthOK},
+		{"steady", fanSamples(10, 70, 0, 55, 0), FanHealthOK},
+		{"cooling down", fanSamples(10, 80, -1, 60, 0), FanHealthOK},
+		{"fewer than 10 samples", fanSamples(9, 60, 1, 40, 0), FanHealthUnknown},
+		{"no samples", nil, FanHealthUnknown},
+		{"fan at 100%", fanSamples(10, 80, 1, 100, 0), FanHealthOK},
+		// Only the last 10 samples count, the fan stopped rising
+		{"fan rose earlier", append(fanSamples(10, 50, 0, 30, 5), fanSamples(10, 60, 1, 75, 0)...), FanHealthSuspectedFailure},
+	}
+	for _, test := range tests {
+		if got := FanHealthCheck(test.samples); got != test.want {
+			t.Errorf("%s: status = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSlope(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{[]float64{1, 2, 3, 4}, 1},
+		{[]float64{10, 8, 6}, -2},
+		{[]float64{5, 5, 5}, 0},
+		{[]float64{0, 2, 0, 2}, 0.4},
+		{[]float64{7}, 0},
+		{nil, 0},
+	}
+	for _, test := range tests {
+		if got := slope(test.values); got != test.want {
+			t.Errorf("slope(%v) = %v, want %v", test.values, got, test.want)
+		}
+	}
+}
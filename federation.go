@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// PeerConfig represents a peer aggregator whose nodes are federated
+type PeerConfig struct {
+	//doc: Name of the peer, used as the prefix of its node names
+	Name string `json:"name"`
+	//doc: Base URL of the peer aggregator, e.g. http://aggregator-dc1:8080
+	URL string `json:"url"`
+}
+
+// FederatedNode represents a node reported by a peer aggregator. Its name is
+// namespaced as "<peer>/<node>" to keep node names unique across peers.
+type FederatedNode struct {
+	Peer string `json:"peer"`
+	NodeStatus
+}
+
+// PeerStatus represents the outcome of fetching a peer's nodes
+type PeerStatus struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Status    string `json:"status"` // "ok", "error"
+	NodeCount int    `json:"node_count"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FederatedResponse is returned by the /api/federated endpoint
+type FederatedResponse struct {
+	Nodes []FederatedNode `json:"nodes"`
+	Peers []PeerStatus    `json:"peers"`
+}
+
+// fetchPeerNodes fetches the node list of a peer aggregator
+func (a *Aggregator) fetchPeerNodes(peer PeerConfig) ([]NodeStatus, error) {
+	url := strings.TrimSuffix(peer.URL, "/") + "/api/nodes"
+	resp, err := a.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	var nodes []NodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nodes, nil
+}
+
+func (a *Aggregator) federatedHandler(w http.ResponseWriter, r *http.Request) {
+	peers := a.config.Federation.Peers
+	results := make([][]NodeStatus, len(peers))
+	statuses := make([]PeerStatus, len(peers))
+
+	// Fetch all peers in parallel; a failing peer is reported, not fatal
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer PeerConfig) {
+			defer wg.Done()
+			statuses[i] = PeerStatus{Name: peer.Name, URL: peer.URL, Status: "ok"}
+			nodes, err := a.fetchPeerNodes(peer)
+			if err != nil {
+				statuses[i].Status = "error"
+				statuses[i].Error = err.Error()
+				return
+			}
+			results[i] = nodes
+			statuses[i].NodeCount = len(nodes)
+		}(i, peer)
+	}
+	wg.Wait()
+
+	// Merge the results in the order peers appear in config
+	response := FederatedResponse{
+		Nodes: make([]FederatedNode, 0),
+		Peers: statuses,
+	}
+	for i, peer := range peers {
+		for _, node := range results[i] {
+			node.Name = peer.Name + "/" + node.Name
+			response.Nodes = append(response.Nodes, FederatedNode{
+				Peer:       peer.Name,
+				NodeStatus: node,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// FlatGPURow is one GPU with the fields of its node denormalized onto it, for
+// tools that cannot handle nested data. Nodes without GPU data produce one
+// row with the GPU fields null.
+type FlatGPURow struct {
+	NodeName  string   `json:"node_name"`
+	Alias     string   `json:"alias"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	ErrorCode string   `json:"error_code,omitempty"`
+	GPUID     *string  `json:"gpu_id"`
+	Name      *string  `json:"name"`
+	Util      *float64 `json:"util"`      // percent
+	MemUsed   *uint64  `json:"mem_used"`  // bytes
+	MemTotal  *uint64  `json:"mem_total"` // bytes
+	Temp      *uint32  `json:"temp"`      // degrees Celsius
+	Power     *float64 `json:"power"`     // watts
+}
+
+// flattenNodes converts node statuses to one row per GPU, dropping processes
+func flattenNodes(nodes []NodeStatus) []FlatGPURow {
+	rows := make([]FlatGPURow, 0, len(nodes))
+	for _, node := range nodes {
+		nodeRow := FlatGPURow{
+			NodeName: node.Name,
+			Alias:    node.Alias,
+			Status:   node.Status,
+		}
+		if node.NodeError != nil {
+			nodeRow.Error = node.NodeError.Message
+			nodeRow.ErrorCode = node.NodeError.Code.String()
+		}
+		if node.Status != "online" || node.Data == nil || len(node.Data.GPUs) == 0 {
+			rows = append(rows, nodeRow)
+			continue
+		}
+		for _, gpu := range node.Data.GPUs {
+			row := nodeRow
+			power := float64(gpu.PowerUsage) / 1000
+			row.GPUID = &gpu.ID
+			row.Name = &gpu.Name
+			row.Util = &gpu.Utilization
+			row.MemUsed = &gpu.MemoryUsed
+			row.MemTotal = &gpu.MemoryTotal
+			row.Temp = &gpu.Temperature
+			row.Power = &power
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (a *Aggregator) flatNodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flattenNodes(a.snapshotNodes()))
+}
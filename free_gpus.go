@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FreeGPU is a GPU returned by the /api/free-gpus endpoint
+type FreeGPU struct {
+	Node        string  `json:"node"`
+	Alias       string  `json:"alias"`
+	Index       int     `json:"index"`
+	ID          string  `json:"id"`
+	UUID        string  `json:"uuid"`
+	Name        string  `json:"name"`
+	MemoryFree  uint64  `json:"memory_free"`  // bytes
+	MemoryTotal uint64  `json:"memory_total"` // bytes
+	Utilization float64 `json:"utilization"`
+}
+
+// byteSizeUnits are the units accepted by parseByteSize; K, M, G and T are
+// binary whether or not they are written with an "i"
+var byteSizeUnits = []struct {
+	suffix string
+	bytes  uint64
+}{
+	{"TIB", 1 << 40}, {"TB", 1 << 40}, {"T", 1 << 40},
+	{"GIB", 1 << 30}, {"GB", 1 << 30}, {"G", 1 << 30},
+	{"MIB", 1 << 20}, {"MB", 1 << 20}, {"M", 1 << 20},
+	{"KIB", 1 << 10}, {"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a memory size like "24GiB", "24G" or "512MiB". Plain
+// numbers are MiB, the unit nvidia-smi reports memory in.
+func parseByteSize(value string) (uint64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := uint64(1 << 20)
+	for _, unit := range byteSizeUnits {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, multiplier = strings.TrimSpace(number), unit.bytes
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return uint64(number * float64(multiplier)), nil
+}
+
+// freeGPUsHandler returns the GPUs of online nodes that run no processes, are
+// not reserved and have at least min_memory free, optionally only those whose
+// model contains model. With count, only nodes with at least that many such
+// GPUs are returned, for jobs that need several GPUs on one machine.
+func (a *Aggregator) freeGPUsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var minMemory uint64
+	if value := query.Get("min_memory"); value != "" {
+		var err error
+		if minMemory, err = parseByteSize(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid min_memory parameter: %v", err))
+			return
+		}
+	}
+	count := 1
+	if value := query.Get("count"); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil || count < 1 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid count parameter: must be a positive integer")
+			return
+		}
+	}
+	model := strings.ToLower(query.Get("model"))
+
+	free := []FreeGPU{}
+	for _, node := range a.snapshotNodes() {
+		if node.Status != "online" || node.Data == nil {
+			continue
+		}
+		var nodeFree []FreeGPU
+		for _, gpu := range node.Data.GPUs {
+			memoryFree := gpu.MemoryTotal - min(gpu.MemoryUsed, gpu.MemoryTotal)
+			// Processes are only listed when requested; the count is always set
+			if gpu.ProcessCount > 0 || len(gpu.Processes) > 0 || gpu.Reservation != nil || memoryFree < minMemory {
+				continue
+			}
+			if model != "" && !strings.Contains(strings.ToLower(gpu.Name), model) {
+				continue
+			}
+			nodeFree = append(nodeFree, FreeGPU{
+				Node:        node.Name,
+				Alias:       node.Alias,
+				Index:       gpu.Index,
+				ID:          gpu.ID,
+				UUID:        gpu.UUID,
+				Name:        gpu.Name,
+				MemoryFree:  memoryFree,
+				MemoryTotal: gpu.MemoryTotal,
+				Utilization: gpu.Utilization,
+			})
+		}
+		if len(nodeFree) >= count {
+			free = append(free, nodeFree...)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(free)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// frontendColumns are the GPU card fields the dashboard can show, in display
+// order
+var frontendColumns = []string{"utilization", "memory", "temperature", "power", "clocks", "pcie", "ecc", "processes"}
+
+// frontendSortKeys are the supported node orders of the dashboard. The empty
+// key keeps the configuration order.
+var frontendSortKeys = []string{"", "name", "status"}
+
+// FrontendConfig customizes the dashboard served by the aggregator
+type FrontendConfig struct {
+	//doc: Page title
+	Title string `json:"title"`
+	//doc: URL of the logo shown before the title
+	LogoURL string `json:"logo_url"`
+	//doc: Milliseconds between refreshes of the dashboard (default 5000)
+	RefreshIntervalMs int `json:"refresh_interval_ms"`
+	//doc: Fields shown on the GPU cards; all when empty
+	//doc:enum utilization,memory,temperature,power,clocks,pcie,ecc,processes
+	VisibleColumns []string `json:"visible_columns"`
+	//doc: Order of the nodes; the configuration order when empty
+	//doc:enum name,status
+	DefaultSort string `json:"default_sort"`
+}
+
+// validateFrontendConfig checks the column names and sort key of the frontend
+// section
+func validateFrontendConfig(config FrontendConfig) error {
+	for _, column := range config.VisibleColumns {
+		if !slices.Contains(frontendColumns, column) {
+			return fmt.Errorf("unknown frontend column %q (valid: %v)", column, frontendColumns)
+		}
+	}
+	if !slices.Contains(frontendSortKeys, config.DefaultSort) {
+		return fmt.Errorf("unknown frontend default_sort %q (valid: name, status)", config.DefaultSort)
+	}
+	return nil
+}
+
+// withDefaults returns the frontend settings with unset fields filled in
+func (config FrontendConfig) withDefaults() FrontendConfig {
+	if config.Title == "" {
+		config.Title = "Distributed NVIDIA GPU Monitor"
+	}
+	if config.RefreshIntervalMs <= 0 {
+		config.RefreshIntervalMs = 5000
+	}
+	if len(config.VisibleColumns) == 0 {
+		config.VisibleColumns = frontendColumns
+	}
+	return config
+}
+
+func (a *Aggregator) frontendConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.config.Frontend.withDefaults())
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gpuListCacheTTL is how long the output of nvidia-smi -L is reused. The set
+// of installed GPUs rarely changes, and a GPU that falls off the bus should
+// keep being reported as missing rather than silently leave the expected set.
+const gpuListCacheTTL = time.Hour
+
+// listedGPU is a GPU reported by nvidia-smi -L
+type listedGPU struct {
+	Index      int
+	UUID       string
+	MIGDevices []listedMIGDevice
+}
+
+// listedMIGDevice is a MIG device reported by nvidia-smi -L under its GPU
+type listedMIGDevice struct {
+	Index   int
+	Profile string
+	UUID    string
+}
+
+var (
+	gpuListCache     []listedGPU
+	gpuListFetchedAt time.Time
+	gpuListMutex     sync.Mutex
+)
+
+// gpuListLine matches lines like
+// "GPU 0: NVIDIA GeForce RTX 3090 (UUID: GPU-5f1e...)"
+var gpuListLine = regexp.MustCompile(`^GPU (\d+): .*\(UUID: ([^)]+)\)`)
+
+// migListLine matches the indented lines of MIG devices like
+// "MIG 1g.10gb     Device  0: (UUID: MIG-c6d4...)"
+var migListLine = regexp.MustCompile(`^MIG (\S+)\s+Device\s+(\d+): \(UUID: ([^)]+)\)`)
+
+// getGPUList returns the GPUs listed by nvidia-smi -L, cached for gpuListCacheTTL
+func getGPUList(ctx context.Context) ([]listedGPU, error) {
+	gpuListMutex.Lock()
+	defer gpuListMutex.Unlock()
+
+	if gpuListCache != nil && time.Since(gpuListFetchedAt) < gpuListCacheTTL {
+		return gpuListCache, nil
+	}
+
+	output, err := runNvidiaSmiCommand(ctx, "-L")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run nvidia-smi -L: %v", err)
+	}
+
+	gpus := []listedGPU{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if match := migListLine.FindStringSubmatch(line); match != nil && len(gpus) > 0 {
+			index, _ := strconv.Atoi(match[2])
+			gpu := &gpus[len(gpus)-1]
+			gpu.MIGDevices = append(gpu.MIGDevices, listedMIGDevice{Index: index, Profile: match[1], UUID: match[3]})
+			continue
+		}
+		match := gpuListLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		gpus = append(gpus, listedGPU{Index: index, UUID: match[2]})
+	}
+
+	gpuListCache = gpus
+	gpuListFetchedAt = time.Now()
+	return gpus, nil
+}
+
+// gpuListMinAge is how old the cached output of nvidia-smi -L must be before
+// it may be invalidated, so that a lookup that keeps failing does not run
+// nvidia-smi -L on every request
+const gpuListMinAge = time.Minute
+
+// invalidateGPUList makes the next getGPUList run nvidia-smi -L again, unless
+// it ran less than gpuListMinAge ago
+func invalidateGPUList() {
+	gpuListMutex.Lock()
+	defer gpuListMutex.Unlock()
+	if time.Since(gpuListFetchedAt) >= gpuListMinAge {
+		gpuListFetchedAt = time.Time{}
+	}
+}
+
+// findMissingGPUs returns the UUIDs of the GPUs listed by nvidia-smi -L that
+// are absent from the XML output, skipping GPUs that are not selected for
+// reporting
+func findMissingGPUs(listed []listedGPU, smiOutput *SMIOutput) []string {
+	reported := make(map[string]bool, len(smiOutput.GPUs))
+	for _, gpu := range smiOutput.GPUs {
+		reported[gpu.UUID] = true
+	}
+
+	missing := []string{}
+	for _, gpu := range listed {
+		if reported[gpu.UUID] || !isGPUSelected(gpu.Index, GPU{UUID: gpu.UUID}) {
+			continue
+		}
+		missing = append(missing, gpu.UUID)
+	}
+	return missing
+}
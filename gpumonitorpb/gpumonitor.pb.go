@@ -0,0 +1,1316 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: gpumonitor.proto
+
+// The gRPC transport between node servers and the aggregator. The messages
+// mirror the JSON payload of /gpu-info field for field, with the same names;
+// fields added there must be added here too.
+
+package gpumonitorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NodeInfoRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only report "active" or "idle" GPUs; all if empty
+	Filter        string `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeInfoRequest) Reset() {
+	*x = NodeInfoRequest{}
+	mi := &file_gpumonitor_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeInfoRequest) ProtoMessage() {}
+
+func (x *NodeInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeInfoRequest.ProtoReflect.Descriptor instead.
+func (*NodeInfoRequest) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NodeInfoRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+type WatchNodeInfoRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only report "active" or "idle" GPUs; all if empty
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Milliseconds between updates; the server enforces a minimum
+	IntervalMs    uint32 `protobuf:"varint,2,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchNodeInfoRequest) Reset() {
+	*x = WatchNodeInfoRequest{}
+	mi := &file_gpumonitor_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchNodeInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchNodeInfoRequest) ProtoMessage() {}
+
+func (x *WatchNodeInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchNodeInfoRequest.ProtoReflect.Descriptor instead.
+func (*WatchNodeInfoRequest) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WatchNodeInfoRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *WatchNodeInfoRequest) GetIntervalMs() uint32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+type NodeInfo struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion     int32                  `protobuf:"varint,1,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	NodeName          string                 `protobuf:"bytes,2,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	Timestamp         *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Gpus              []*GPUInfo             `protobuf:"bytes,4,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	System            *SystemInfo            `protobuf:"bytes,5,opt,name=system,proto3" json:"system,omitempty"`
+	AccountingEnabled bool                   `protobuf:"varint,6,opt,name=accounting_enabled,json=accountingEnabled,proto3" json:"accounting_enabled,omitempty"`
+	ExpectedGpuCount  int32                  `protobuf:"varint,7,opt,name=expected_gpu_count,json=expectedGpuCount,proto3" json:"expected_gpu_count,omitempty"`
+	MissingUuids      []string               `protobuf:"bytes,8,rep,name=missing_uuids,json=missingUuids,proto3" json:"missing_uuids,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NodeInfo) Reset() {
+	*x = NodeInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeInfo) ProtoMessage() {}
+
+func (x *NodeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeInfo.ProtoReflect.Descriptor instead.
+func (*NodeInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *NodeInfo) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *NodeInfo) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetGpus() []*GPUInfo {
+	if x != nil {
+		return x.Gpus
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetSystem() *SystemInfo {
+	if x != nil {
+		return x.System
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetAccountingEnabled() bool {
+	if x != nil {
+		return x.AccountingEnabled
+	}
+	return false
+}
+
+func (x *NodeInfo) GetExpectedGpuCount() int32 {
+	if x != nil {
+		return x.ExpectedGpuCount
+	}
+	return 0
+}
+
+func (x *NodeInfo) GetMissingUuids() []string {
+	if x != nil {
+		return x.MissingUuids
+	}
+	return nil
+}
+
+type GPUInfo struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Id                   string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Index                int32                  `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Uuid                 string                 `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Name                 string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Utilization          float64                `protobuf:"fixed64,5,opt,name=utilization,proto3" json:"utilization,omitempty"`
+	UtilizationEma       float64                `protobuf:"fixed64,6,opt,name=utilization_ema,json=utilizationEma,proto3" json:"utilization_ema,omitempty"`
+	MemoryControllerUtil float64                `protobuf:"fixed64,7,opt,name=memory_controller_util,json=memoryControllerUtil,proto3" json:"memory_controller_util,omitempty"`
+	EncoderUtil          float64                `protobuf:"fixed64,8,opt,name=encoder_util,json=encoderUtil,proto3" json:"encoder_util,omitempty"`
+	DecoderUtil          float64                `protobuf:"fixed64,9,opt,name=decoder_util,json=decoderUtil,proto3" json:"decoder_util,omitempty"`
+	MemoryUsed           uint64                 `protobuf:"varint,10,opt,name=memory_used,json=memoryUsed,proto3" json:"memory_used,omitempty"`
+	MemoryTotal          uint64                 `protobuf:"varint,11,opt,name=memory_total,json=memoryTotal,proto3" json:"memory_total,omitempty"`
+	Bar1MemoryUsed       uint64                 `protobuf:"varint,12,opt,name=bar1_memory_used,json=bar1MemoryUsed,proto3" json:"bar1_memory_used,omitempty"`
+	Bar1MemoryTotal      uint64                 `protobuf:"varint,13,opt,name=bar1_memory_total,json=bar1MemoryTotal,proto3" json:"bar1_memory_total,omitempty"`
+	Temperature          uint32                 `protobuf:"varint,14,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	FanSpeed             *float64               `protobuf:"fixed64,15,opt,name=fan_speed,json=fanSpeed,proto3,oneof" json:"fan_speed,omitempty"`
+	FanHealthStatus      string                 `protobuf:"bytes,16,opt,name=fan_health_status,json=fanHealthStatus,proto3" json:"fan_health_status,omitempty"`
+	PowerUsage           uint64                 `protobuf:"varint,17,opt,name=power_usage,json=powerUsage,proto3" json:"power_usage,omitempty"`
+	PowerLimit           uint64                 `protobuf:"varint,18,opt,name=power_limit,json=powerLimit,proto3" json:"power_limit,omitempty"`
+	PowerLimitDrift      bool                   `protobuf:"varint,19,opt,name=power_limit_drift,json=powerLimitDrift,proto3" json:"power_limit_drift,omitempty"`
+	SmClock              uint32                 `protobuf:"varint,20,opt,name=sm_clock,json=smClock,proto3" json:"sm_clock,omitempty"`
+	MemClock             uint32                 `protobuf:"varint,21,opt,name=mem_clock,json=memClock,proto3" json:"mem_clock,omitempty"`
+	MaxSmClock           uint32                 `protobuf:"varint,22,opt,name=max_sm_clock,json=maxSmClock,proto3" json:"max_sm_clock,omitempty"`
+	MaxMemClock          uint32                 `protobuf:"varint,23,opt,name=max_mem_clock,json=maxMemClock,proto3" json:"max_mem_clock,omitempty"`
+	ThrottlingPct        float64                `protobuf:"fixed64,24,opt,name=throttling_pct,json=throttlingPct,proto3" json:"throttling_pct,omitempty"`
+	ThrottleReason       string                 `protobuf:"bytes,25,opt,name=throttle_reason,json=throttleReason,proto3" json:"throttle_reason,omitempty"`
+	ThrottleReasons      []string               `protobuf:"bytes,26,rep,name=throttle_reasons,json=throttleReasons,proto3" json:"throttle_reasons,omitempty"`
+	Throttled            bool                   `protobuf:"varint,27,opt,name=throttled,proto3" json:"throttled,omitempty"`
+	PcieTxThroughput     uint64                 `protobuf:"varint,28,opt,name=pcie_tx_throughput,json=pcieTxThroughput,proto3" json:"pcie_tx_throughput,omitempty"`
+	PcieRxThroughput     uint64                 `protobuf:"varint,29,opt,name=pcie_rx_throughput,json=pcieRxThroughput,proto3" json:"pcie_rx_throughput,omitempty"`
+	Ecc                  *ECCInfo               `protobuf:"bytes,30,opt,name=ecc,proto3" json:"ecc,omitempty"`
+	Processes            []*ProcessInfo         `protobuf:"bytes,31,rep,name=processes,proto3" json:"processes,omitempty"`
+	ProcessCount         int32                  `protobuf:"varint,32,opt,name=process_count,json=processCount,proto3" json:"process_count,omitempty"`
+	Nvlinks              []*NVLinkInfo          `protobuf:"bytes,33,rep,name=nvlinks,proto3" json:"nvlinks,omitempty"`
+	NvlinkActiveCount    int32                  `protobuf:"varint,34,opt,name=nvlink_active_count,json=nvlinkActiveCount,proto3" json:"nvlink_active_count,omitempty"`
+	NvlinkExpectedCount  int32                  `protobuf:"varint,35,opt,name=nvlink_expected_count,json=nvlinkExpectedCount,proto3" json:"nvlink_expected_count,omitempty"`
+	MigDevices           []*MIGDeviceInfo       `protobuf:"bytes,36,rep,name=mig_devices,json=migDevices,proto3" json:"mig_devices,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *GPUInfo) Reset() {
+	*x = GPUInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GPUInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GPUInfo) ProtoMessage() {}
+
+func (x *GPUInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GPUInfo.ProtoReflect.Descriptor instead.
+func (*GPUInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GPUInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GPUInfo) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *GPUInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GPUInfo) GetUtilization() float64 {
+	if x != nil {
+		return x.Utilization
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetUtilizationEma() float64 {
+	if x != nil {
+		return x.UtilizationEma
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMemoryControllerUtil() float64 {
+	if x != nil {
+		return x.MemoryControllerUtil
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetEncoderUtil() float64 {
+	if x != nil {
+		return x.EncoderUtil
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetDecoderUtil() float64 {
+	if x != nil {
+		return x.DecoderUtil
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMemoryUsed() uint64 {
+	if x != nil {
+		return x.MemoryUsed
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMemoryTotal() uint64 {
+	if x != nil {
+		return x.MemoryTotal
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetBar1MemoryUsed() uint64 {
+	if x != nil {
+		return x.Bar1MemoryUsed
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetBar1MemoryTotal() uint64 {
+	if x != nil {
+		return x.Bar1MemoryTotal
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetTemperature() uint32 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetFanSpeed() float64 {
+	if x != nil && x.FanSpeed != nil {
+		return *x.FanSpeed
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetFanHealthStatus() string {
+	if x != nil {
+		return x.FanHealthStatus
+	}
+	return ""
+}
+
+func (x *GPUInfo) GetPowerUsage() uint64 {
+	if x != nil {
+		return x.PowerUsage
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetPowerLimit() uint64 {
+	if x != nil {
+		return x.PowerLimit
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetPowerLimitDrift() bool {
+	if x != nil {
+		return x.PowerLimitDrift
+	}
+	return false
+}
+
+func (x *GPUInfo) GetSmClock() uint32 {
+	if x != nil {
+		return x.SmClock
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMemClock() uint32 {
+	if x != nil {
+		return x.MemClock
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMaxSmClock() uint32 {
+	if x != nil {
+		return x.MaxSmClock
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMaxMemClock() uint32 {
+	if x != nil {
+		return x.MaxMemClock
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetThrottlingPct() float64 {
+	if x != nil {
+		return x.ThrottlingPct
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetThrottleReason() string {
+	if x != nil {
+		return x.ThrottleReason
+	}
+	return ""
+}
+
+func (x *GPUInfo) GetThrottleReasons() []string {
+	if x != nil {
+		return x.ThrottleReasons
+	}
+	return nil
+}
+
+func (x *GPUInfo) GetThrottled() bool {
+	if x != nil {
+		return x.Throttled
+	}
+	return false
+}
+
+func (x *GPUInfo) GetPcieTxThroughput() uint64 {
+	if x != nil {
+		return x.PcieTxThroughput
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetPcieRxThroughput() uint64 {
+	if x != nil {
+		return x.PcieRxThroughput
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetEcc() *ECCInfo {
+	if x != nil {
+		return x.Ecc
+	}
+	return nil
+}
+
+func (x *GPUInfo) GetProcesses() []*ProcessInfo {
+	if x != nil {
+		return x.Processes
+	}
+	return nil
+}
+
+func (x *GPUInfo) GetProcessCount() int32 {
+	if x != nil {
+		return x.ProcessCount
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetNvlinks() []*NVLinkInfo {
+	if x != nil {
+		return x.Nvlinks
+	}
+	return nil
+}
+
+func (x *GPUInfo) GetNvlinkActiveCount() int32 {
+	if x != nil {
+		return x.NvlinkActiveCount
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetNvlinkExpectedCount() int32 {
+	if x != nil {
+		return x.NvlinkExpectedCount
+	}
+	return 0
+}
+
+func (x *GPUInfo) GetMigDevices() []*MIGDeviceInfo {
+	if x != nil {
+		return x.MigDevices
+	}
+	return nil
+}
+
+type ECCInfo struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	VolatileCorrectable    uint64                 `protobuf:"varint,1,opt,name=volatile_correctable,json=volatileCorrectable,proto3" json:"volatile_correctable,omitempty"`
+	VolatileUncorrectable  uint64                 `protobuf:"varint,2,opt,name=volatile_uncorrectable,json=volatileUncorrectable,proto3" json:"volatile_uncorrectable,omitempty"`
+	AggregateCorrectable   uint64                 `protobuf:"varint,3,opt,name=aggregate_correctable,json=aggregateCorrectable,proto3" json:"aggregate_correctable,omitempty"`
+	AggregateUncorrectable uint64                 `protobuf:"varint,4,opt,name=aggregate_uncorrectable,json=aggregateUncorrectable,proto3" json:"aggregate_uncorrectable,omitempty"`
+	RetiredPagesSingleBit  uint64                 `protobuf:"varint,5,opt,name=retired_pages_single_bit,json=retiredPagesSingleBit,proto3" json:"retired_pages_single_bit,omitempty"`
+	RetiredPagesDoubleBit  uint64                 `protobuf:"varint,6,opt,name=retired_pages_double_bit,json=retiredPagesDoubleBit,proto3" json:"retired_pages_double_bit,omitempty"`
+	PendingRetirement      bool                   `protobuf:"varint,7,opt,name=pending_retirement,json=pendingRetirement,proto3" json:"pending_retirement,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *ECCInfo) Reset() {
+	*x = ECCInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ECCInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ECCInfo) ProtoMessage() {}
+
+func (x *ECCInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ECCInfo.ProtoReflect.Descriptor instead.
+func (*ECCInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ECCInfo) GetVolatileCorrectable() uint64 {
+	if x != nil {
+		return x.VolatileCorrectable
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetVolatileUncorrectable() uint64 {
+	if x != nil {
+		return x.VolatileUncorrectable
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetAggregateCorrectable() uint64 {
+	if x != nil {
+		return x.AggregateCorrectable
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetAggregateUncorrectable() uint64 {
+	if x != nil {
+		return x.AggregateUncorrectable
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetRetiredPagesSingleBit() uint64 {
+	if x != nil {
+		return x.RetiredPagesSingleBit
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetRetiredPagesDoubleBit() uint64 {
+	if x != nil {
+		return x.RetiredPagesDoubleBit
+	}
+	return 0
+}
+
+func (x *ECCInfo) GetPendingRetirement() bool {
+	if x != nil {
+		return x.PendingRetirement
+	}
+	return false
+}
+
+type ProcessInfo struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Pid                  uint32                 `protobuf:"varint,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	Name                 string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Used                 uint64                 `protobuf:"varint,3,opt,name=used,proto3" json:"used,omitempty"`
+	User                 string                 `protobuf:"bytes,4,opt,name=user,proto3" json:"user,omitempty"`
+	ContainerId          string                 `protobuf:"bytes,5,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	ContainerName        string                 `protobuf:"bytes,6,opt,name=container_name,json=containerName,proto3" json:"container_name,omitempty"`
+	ContainerImage       string                 `protobuf:"bytes,7,opt,name=container_image,json=containerImage,proto3" json:"container_image,omitempty"`
+	JobId                string                 `protobuf:"bytes,8,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	JobUser              string                 `protobuf:"bytes,9,opt,name=job_user,json=jobUser,proto3" json:"job_user,omitempty"`
+	PowerShareMilliwatts uint64                 `protobuf:"varint,10,opt,name=power_share_milliwatts,json=powerShareMilliwatts,proto3" json:"power_share_milliwatts,omitempty"`
+	SmUtil               float64                `protobuf:"fixed64,11,opt,name=sm_util,json=smUtil,proto3" json:"sm_util,omitempty"`
+	MemUtil              float64                `protobuf:"fixed64,12,opt,name=mem_util,json=memUtil,proto3" json:"mem_util,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *ProcessInfo) Reset() {
+	*x = ProcessInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessInfo) ProtoMessage() {}
+
+func (x *ProcessInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessInfo.ProtoReflect.Descriptor instead.
+func (*ProcessInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProcessInfo) GetPid() uint32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetUsed() uint64 {
+	if x != nil {
+		return x.Used
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetContainerName() string {
+	if x != nil {
+		return x.ContainerName
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetContainerImage() string {
+	if x != nil {
+		return x.ContainerImage
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetJobUser() string {
+	if x != nil {
+		return x.JobUser
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetPowerShareMilliwatts() uint64 {
+	if x != nil {
+		return x.PowerShareMilliwatts
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetSmUtil() float64 {
+	if x != nil {
+		return x.SmUtil
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetMemUtil() float64 {
+	if x != nil {
+		return x.MemUtil
+	}
+	return 0
+}
+
+type NVLinkInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Index          int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	State          string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	CrcFlitErrors  uint64                 `protobuf:"varint,3,opt,name=crc_flit_errors,json=crcFlitErrors,proto3" json:"crc_flit_errors,omitempty"`
+	CrcDataErrors  uint64                 `protobuf:"varint,4,opt,name=crc_data_errors,json=crcDataErrors,proto3" json:"crc_data_errors,omitempty"`
+	ReplayErrors   uint64                 `protobuf:"varint,5,opt,name=replay_errors,json=replayErrors,proto3" json:"replay_errors,omitempty"`
+	RecoveryErrors uint64                 `protobuf:"varint,6,opt,name=recovery_errors,json=recoveryErrors,proto3" json:"recovery_errors,omitempty"`
+	TxBytes        uint64                 `protobuf:"varint,7,opt,name=tx_bytes,json=txBytes,proto3" json:"tx_bytes,omitempty"`
+	RxBytes        uint64                 `protobuf:"varint,8,opt,name=rx_bytes,json=rxBytes,proto3" json:"rx_bytes,omitempty"`
+	TxThroughput   uint64                 `protobuf:"varint,9,opt,name=tx_throughput,json=txThroughput,proto3" json:"tx_throughput,omitempty"`
+	RxThroughput   uint64                 `protobuf:"varint,10,opt,name=rx_throughput,json=rxThroughput,proto3" json:"rx_throughput,omitempty"`
+	RemotePciBusId string                 `protobuf:"bytes,11,opt,name=remote_pci_bus_id,json=remotePciBusId,proto3" json:"remote_pci_bus_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *NVLinkInfo) Reset() {
+	*x = NVLinkInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NVLinkInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NVLinkInfo) ProtoMessage() {}
+
+func (x *NVLinkInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NVLinkInfo.ProtoReflect.Descriptor instead.
+func (*NVLinkInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NVLinkInfo) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *NVLinkInfo) GetCrcFlitErrors() uint64 {
+	if x != nil {
+		return x.CrcFlitErrors
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetCrcDataErrors() uint64 {
+	if x != nil {
+		return x.CrcDataErrors
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetReplayErrors() uint64 {
+	if x != nil {
+		return x.ReplayErrors
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetRecoveryErrors() uint64 {
+	if x != nil {
+		return x.RecoveryErrors
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetTxBytes() uint64 {
+	if x != nil {
+		return x.TxBytes
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetRxBytes() uint64 {
+	if x != nil {
+		return x.RxBytes
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetTxThroughput() uint64 {
+	if x != nil {
+		return x.TxThroughput
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetRxThroughput() uint64 {
+	if x != nil {
+		return x.RxThroughput
+	}
+	return 0
+}
+
+func (x *NVLinkInfo) GetRemotePciBusId() string {
+	if x != nil {
+		return x.RemotePciBusId
+	}
+	return ""
+}
+
+type MIGDeviceInfo struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Index               int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Uuid                string                 `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Profile             string                 `protobuf:"bytes,3,opt,name=profile,proto3" json:"profile,omitempty"`
+	GpuInstanceId       int32                  `protobuf:"varint,4,opt,name=gpu_instance_id,json=gpuInstanceId,proto3" json:"gpu_instance_id,omitempty"`
+	ComputeInstanceId   int32                  `protobuf:"varint,5,opt,name=compute_instance_id,json=computeInstanceId,proto3" json:"compute_instance_id,omitempty"`
+	MultiprocessorCount int32                  `protobuf:"varint,6,opt,name=multiprocessor_count,json=multiprocessorCount,proto3" json:"multiprocessor_count,omitempty"`
+	MemoryUsed          uint64                 `protobuf:"varint,7,opt,name=memory_used,json=memoryUsed,proto3" json:"memory_used,omitempty"`
+	MemoryTotal         uint64                 `protobuf:"varint,8,opt,name=memory_total,json=memoryTotal,proto3" json:"memory_total,omitempty"`
+	ProcessCount        int32                  `protobuf:"varint,9,opt,name=process_count,json=processCount,proto3" json:"process_count,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *MIGDeviceInfo) Reset() {
+	*x = MIGDeviceInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MIGDeviceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MIGDeviceInfo) ProtoMessage() {}
+
+func (x *MIGDeviceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MIGDeviceInfo.ProtoReflect.Descriptor instead.
+func (*MIGDeviceInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MIGDeviceInfo) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *MIGDeviceInfo) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *MIGDeviceInfo) GetGpuInstanceId() int32 {
+	if x != nil {
+		return x.GpuInstanceId
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetComputeInstanceId() int32 {
+	if x != nil {
+		return x.ComputeInstanceId
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetMultiprocessorCount() int32 {
+	if x != nil {
+		return x.MultiprocessorCount
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetMemoryUsed() uint64 {
+	if x != nil {
+		return x.MemoryUsed
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetMemoryTotal() uint64 {
+	if x != nil {
+		return x.MemoryTotal
+	}
+	return 0
+}
+
+func (x *MIGDeviceInfo) GetProcessCount() int32 {
+	if x != nil {
+		return x.ProcessCount
+	}
+	return 0
+}
+
+type SystemInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CpuCount       int32                  `protobuf:"varint,1,opt,name=cpu_count,json=cpuCount,proto3" json:"cpu_count,omitempty"`
+	CpuUtilization float64                `protobuf:"fixed64,2,opt,name=cpu_utilization,json=cpuUtilization,proto3" json:"cpu_utilization,omitempty"`
+	LoadAvg_1      float64                `protobuf:"fixed64,3,opt,name=load_avg_1,json=loadAvg1,proto3" json:"load_avg_1,omitempty"`
+	LoadAvg_5      float64                `protobuf:"fixed64,4,opt,name=load_avg_5,json=loadAvg5,proto3" json:"load_avg_5,omitempty"`
+	LoadAvg_15     float64                `protobuf:"fixed64,5,opt,name=load_avg_15,json=loadAvg15,proto3" json:"load_avg_15,omitempty"`
+	MemoryTotal    uint64                 `protobuf:"varint,6,opt,name=memory_total,json=memoryTotal,proto3" json:"memory_total,omitempty"`
+	MemoryUsed     uint64                 `protobuf:"varint,7,opt,name=memory_used,json=memoryUsed,proto3" json:"memory_used,omitempty"`
+	DiskTotal      uint64                 `protobuf:"varint,8,opt,name=disk_total,json=diskTotal,proto3" json:"disk_total,omitempty"`
+	DiskUsed       uint64                 `protobuf:"varint,9,opt,name=disk_used,json=diskUsed,proto3" json:"disk_used,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SystemInfo) Reset() {
+	*x = SystemInfo{}
+	mi := &file_gpumonitor_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SystemInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SystemInfo) ProtoMessage() {}
+
+func (x *SystemInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gpumonitor_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SystemInfo.ProtoReflect.Descriptor instead.
+func (*SystemInfo) Descriptor() ([]byte, []int) {
+	return file_gpumonitor_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SystemInfo) GetCpuCount() int32 {
+	if x != nil {
+		return x.CpuCount
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetCpuUtilization() float64 {
+	if x != nil {
+		return x.CpuUtilization
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetLoadAvg_1() float64 {
+	if x != nil {
+		return x.LoadAvg_1
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetLoadAvg_5() float64 {
+	if x != nil {
+		return x.LoadAvg_5
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetLoadAvg_15() float64 {
+	if x != nil {
+		return x.LoadAvg_15
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetMemoryTotal() uint64 {
+	if x != nil {
+		return x.MemoryTotal
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetMemoryUsed() uint64 {
+	if x != nil {
+		return x.MemoryUsed
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetDiskTotal() uint64 {
+	if x != nil {
+		return x.DiskTotal
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetDiskUsed() uint64 {
+	if x != nil {
+		return x.DiskUsed
+	}
+	return 0
+}
+
+var File_gpumonitor_proto protoreflect.FileDescriptor
+
+const file_gpumonitor_proto_rawDesc = "" +
+	"\n" +
+	"\x10gpumonitor.proto\x12\rgpumonitor.v1\x1a\x1fgoogle/protobuf/timestamp.proto\")\n" +
+	"\x0fNodeInfoRequest\x12\x16\n" +
+	"\x06filter\x18\x01 \x01(\tR\x06filter\"O\n" +
+	"\x14WatchNodeInfoRequest\x12\x16\n" +
+	"\x06filter\x18\x01 \x01(\tR\x06filter\x12\x1f\n" +
+	"\vinterval_ms\x18\x02 \x01(\rR\n" +
+	"intervalMs\"\xe9\x02\n" +
+	"\bNodeInfo\x12%\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\rschemaVersion\x12\x1b\n" +
+	"\tnode_name\x18\x02 \x01(\tR\bnodeName\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12*\n" +
+	"\x04gpus\x18\x04 \x03(\v2\x16.gpumonitor.v1.GPUInfoR\x04gpus\x121\n" +
+	"\x06system\x18\x05 \x01(\v2\x19.gpumonitor.v1.SystemInfoR\x06system\x12-\n" +
+	"\x12accounting_enabled\x18\x06 \x01(\bR\x11accountingEnabled\x12,\n" +
+	"\x12expected_gpu_count\x18\a \x01(\x05R\x10expectedGpuCount\x12#\n" +
+	"\rmissing_uuids\x18\b \x03(\tR\fmissingUuids\"\xf8\n" +
+	"\n" +
+	"\aGPUInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04uuid\x18\x03 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12 \n" +
+	"\vutilization\x18\x05 \x01(\x01R\vutilization\x12'\n" +
+	"\x0futilization_ema\x18\x06 \x01(\x01R\x0eutilizationEma\x124\n" +
+	"\x16memory_controller_util\x18\a \x01(\x01R\x14memoryControllerUtil\x12!\n" +
+	"\fencoder_util\x18\b \x01(\x01R\vencoderUtil\x12!\n" +
+	"\fdecoder_util\x18\t \x01(\x01R\vdecoderUtil\x12\x1f\n" +
+	"\vmemory_used\x18\n" +
+	" \x01(\x04R\n" +
+	"memoryUsed\x12!\n" +
+	"\fmemory_total\x18\v \x01(\x04R\vmemoryTotal\x12(\n" +
+	"\x10bar1_memory_used\x18\f \x01(\x04R\x0ebar1MemoryUsed\x12*\n" +
+	"\x11bar1_memory_total\x18\r \x01(\x04R\x0fbar1MemoryTotal\x12 \n" +
+	"\vtemperature\x18\x0e \x01(\rR\vtemperature\x12 \n" +
+	"\tfan_speed\x18\x0f \x01(\x01H\x00R\bfanSpeed\x88\x01\x01\x12*\n" +
+	"\x11fan_health_status\x18\x10 \x01(\tR\x0ffanHealthStatus\x12\x1f\n" +
+	"\vpower_usage\x18\x11 \x01(\x04R\n" +
+	"powerUsage\x12\x1f\n" +
+	"\vpower_limit\x18\x12 \x01(\x04R\n" +
+	"powerLimit\x12*\n" +
+	"\x11power_limit_drift\x18\x13 \x01(\bR\x0fpowerLimitDrift\x12\x19\n" +
+	"\bsm_clock\x18\x14 \x01(\rR\asmClock\x12\x1b\n" +
+	"\tmem_clock\x18\x15 \x01(\rR\bmemClock\x12 \n" +
+	"\fmax_sm_clock\x18\x16 \x01(\rR\n" +
+	"maxSmClock\x12\"\n" +
+	"\rmax_mem_clock\x18\x17 \x01(\rR\vmaxMemClock\x12%\n" +
+	"\x0ethrottling_pct\x18\x18 \x01(\x01R\rthrottlingPct\x12'\n" +
+	"\x0fthrottle_reason\x18\x19 \x01(\tR\x0ethrottleReason\x12)\n" +
+	"\x10throttle_reasons\x18\x1a \x03(\tR\x0fthrottleReasons\x12\x1c\n" +
+	"\tthrottled\x18\x1b \x01(\bR\tthrottled\x12,\n" +
+	"\x12pcie_tx_throughput\x18\x1c \x01(\x04R\x10pcieTxThroughput\x12,\n" +
+	"\x12pcie_rx_throughput\x18\x1d \x01(\x04R\x10pcieRxThroughput\x12(\n" +
+	"\x03ecc\x18\x1e \x01(\v2\x16.gpumonitor.v1.ECCInfoR\x03ecc\x128\n" +
+	"\tprocesses\x18\x1f \x03(\v2\x1a.gpumonitor.v1.ProcessInfoR\tprocesses\x12#\n" +
+	"\rprocess_count\x18  \x01(\x05R\fprocessCount\x123\n" +
+	"\anvlinks\x18! \x03(\v2\x19.gpumonitor.v1.NVLinkInfoR\anvlinks\x12.\n" +
+	"\x13nvlink_active_count\x18\" \x01(\x05R\x11nvlinkActiveCount\x122\n" +
+	"\x15nvlink_expected_count\x18# \x01(\x05R\x13nvlinkExpectedCount\x12=\n" +
+	"\vmig_devices\x18$ \x03(\v2\x1c.gpumonitor.v1.MIGDeviceInfoR\n" +
+	"migDevicesB\f\n" +
+	"\n" +
+	"_fan_speed\"\x82\x03\n" +
+	"\aECCInfo\x121\n" +
+	"\x14volatile_correctable\x18\x01 \x01(\x04R\x13volatileCorrectable\x125\n" +
+	"\x16volatile_uncorrectable\x18\x02 \x01(\x04R\x15volatileUncorrectable\x123\n" +
+	"\x15aggregate_correctable\x18\x03 \x01(\x04R\x14aggregateCorrectable\x127\n" +
+	"\x17aggregate_uncorrectable\x18\x04 \x01(\x04R\x16aggregateUncorrectable\x127\n" +
+	"\x18retired_pages_single_bit\x18\x05 \x01(\x04R\x15retiredPagesSingleBit\x127\n" +
+	"\x18retired_pages_double_bit\x18\x06 \x01(\x04R\x15retiredPagesDoubleBit\x12-\n" +
+	"\x12pending_retirement\x18\a \x01(\bR\x11pendingRetirement\"\xea\x02\n" +
+	"\vProcessInfo\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\rR\x03pid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04used\x18\x03 \x01(\x04R\x04used\x12\x12\n" +
+	"\x04user\x18\x04 \x01(\tR\x04user\x12!\n" +
+	"\fcontainer_id\x18\x05 \x01(\tR\vcontainerId\x12%\n" +
+	"\x0econtainer_name\x18\x06 \x01(\tR\rcontainerName\x12'\n" +
+	"\x0fcontainer_image\x18\a \x01(\tR\x0econtainerImage\x12\x15\n" +
+	"\x06job_id\x18\b \x01(\tR\x05jobId\x12\x19\n" +
+	"\bjob_user\x18\t \x01(\tR\ajobUser\x124\n" +
+	"\x16power_share_milliwatts\x18\n" +
+	" \x01(\x04R\x14powerShareMilliwatts\x12\x17\n" +
+	"\asm_util\x18\v \x01(\x01R\x06smUtil\x12\x19\n" +
+	"\bmem_util\x18\f \x01(\x01R\amemUtil\"\x81\x03\n" +
+	"\n" +
+	"NVLinkInfo\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12&\n" +
+	"\x0fcrc_flit_errors\x18\x03 \x01(\x04R\rcrcFlitErrors\x12&\n" +
+	"\x0fcrc_data_errors\x18\x04 \x01(\x04R\rcrcDataErrors\x12#\n" +
+	"\rreplay_errors\x18\x05 \x01(\x04R\freplayErrors\x12'\n" +
+	"\x0frecovery_errors\x18\x06 \x01(\x04R\x0erecoveryErrors\x12\x19\n" +
+	"\btx_bytes\x18\a \x01(\x04R\atxBytes\x12\x19\n" +
+	"\brx_bytes\x18\b \x01(\x04R\arxBytes\x12#\n" +
+	"\rtx_throughput\x18\t \x01(\x04R\ftxThroughput\x12#\n" +
+	"\rrx_throughput\x18\n" +
+	" \x01(\x04R\frxThroughput\x12)\n" +
+	"\x11remote_pci_bus_id\x18\v \x01(\tR\x0eremotePciBusId\"\xc7\x02\n" +
+	"\rMIGDeviceInfo\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04uuid\x18\x02 \x01(\tR\x04uuid\x12\x18\n" +
+	"\aprofile\x18\x03 \x01(\tR\aprofile\x12&\n" +
+	"\x0fgpu_instance_id\x18\x04 \x01(\x05R\rgpuInstanceId\x12.\n" +
+	"\x13compute_instance_id\x18\x05 \x01(\x05R\x11computeInstanceId\x121\n" +
+	"\x14multiprocessor_count\x18\x06 \x01(\x05R\x13multiprocessorCount\x12\x1f\n" +
+	"\vmemory_used\x18\a \x01(\x04R\n" +
+	"memoryUsed\x12!\n" +
+	"\fmemory_total\x18\b \x01(\x04R\vmemoryTotal\x12#\n" +
+	"\rprocess_count\x18\t \x01(\x05R\fprocessCount\"\xae\x02\n" +
+	"\n" +
+	"SystemInfo\x12\x1b\n" +
+	"\tcpu_count\x18\x01 \x01(\x05R\bcpuCount\x12'\n" +
+	"\x0fcpu_utilization\x18\x02 \x01(\x01R\x0ecpuUtilization\x12\x1c\n" +
+	"\n" +
+	"load_avg_1\x18\x03 \x01(\x01R\bloadAvg1\x12\x1c\n" +
+	"\n" +
+	"load_avg_5\x18\x04 \x01(\x01R\bloadAvg5\x12\x1e\n" +
+	"\vload_avg_15\x18\x05 \x01(\x01R\tloadAvg15\x12!\n" +
+	"\fmemory_total\x18\x06 \x01(\x04R\vmemoryTotal\x12\x1f\n" +
+	"\vmemory_used\x18\a \x01(\x04R\n" +
+	"memoryUsed\x12\x1d\n" +
+	"\n" +
+	"disk_total\x18\b \x01(\x04R\tdiskTotal\x12\x1b\n" +
+	"\tdisk_used\x18\t \x01(\x04R\bdiskUsed2\xa5\x01\n" +
+	"\n" +
+	"GPUMonitor\x12F\n" +
+	"\vGetNodeInfo\x12\x1e.gpumonitor.v1.NodeInfoRequest\x1a\x17.gpumonitor.v1.NodeInfo\x12O\n" +
+	"\rWatchNodeInfo\x12#.gpumonitor.v1.WatchNodeInfoRequest\x1a\x17.gpumonitor.v1.NodeInfo0\x01B\x1aZ\x18gpu-monitor/gpumonitorpbb\x06proto3"
+
+var (
+	file_gpumonitor_proto_rawDescOnce sync.Once
+	file_gpumonitor_proto_rawDescData []byte
+)
+
+func file_gpumonitor_proto_rawDescGZIP() []byte {
+	file_gpumonitor_proto_rawDescOnce.Do(func() {
+		file_gpumonitor_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gpumonitor_proto_rawDesc), len(file_gpumonitor_proto_rawDesc)))
+	})
+	return file_gpumonitor_proto_rawDescData
+}
+
+var file_gpumonitor_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_gpumonitor_proto_goTypes = []any{
+	(*NodeInfoRequest)(nil),       // 0: gpumonitor.v1.NodeInfoRequest
+	(*WatchNodeInfoRequest)(nil),  // 1: gpumonitor.v1.WatchNodeInfoRequest
+	(*NodeInfo)(nil),              // 2: gpumonitor.v1.NodeInfo
+	(*GPUInfo)(nil),               // 3: gpumonitor.v1.GPUInfo
+	(*ECCInfo)(nil),               // 4: gpumonitor.v1.ECCInfo
+	(*ProcessInfo)(nil),           // 5: gpumonitor.v1.ProcessInfo
+	(*NVLinkInfo)(nil),            // 6: gpumonitor.v1.NVLinkInfo
+	(*MIGDeviceInfo)(nil),         // 7: gpumonitor.v1.MIGDeviceInfo
+	(*SystemInfo)(nil),            // 8: gpumonitor.v1.SystemInfo
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_gpumonitor_proto_depIdxs = []int32{
+	9, // 0: gpumonitor.v1.NodeInfo.timestamp:type_name -> google.protobuf.Timestamp
+	3, // 1: gpumonitor.v1.NodeInfo.gpus:type_name -> gpumonitor.v1.GPUInfo
+	8, // 2: gpumonitor.v1.NodeInfo.system:type_name -> gpumonitor.v1.SystemInfo
+	4, // 3: gpumonitor.v1.GPUInfo.ecc:type_name -> gpumonitor.v1.ECCInfo
+	5, // 4: gpumonitor.v1.GPUInfo.processes:type_name -> gpumonitor.v1.ProcessInfo
+	6, // 5: gpumonitor.v1.GPUInfo.nvlinks:type_name -> gpumonitor.v1.NVLinkInfo
+	7, // 6: gpumonitor.v1.GPUInfo.mig_devices:type_name -> gpumonitor.v1.MIGDeviceInfo
+	0, // 7: gpumonitor.v1.GPUMonitor.GetNodeInfo:input_type -> gpumonitor.v1.NodeInfoRequest
+	1, // 8: gpumonitor.v1.GPUMonitor.WatchNodeInfo:input_type -> gpumonitor.v1.WatchNodeInfoRequest
+	2, // 9: gpumonitor.v1.GPUMonitor.GetNodeInfo:output_type -> gpumonitor.v1.NodeInfo
+	2, // 10: gpumonitor.v1.GPUMonitor.WatchNodeInfo:output_type -> gpumonitor.v1.NodeInfo
+	9, // [9:11] is the sub-list for method output_type
+	7, // [7:9] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_gpumonitor_proto_init() }
+func file_gpumonitor_proto_init() {
+	if File_gpumonitor_proto != nil {
+		return
+	}
+	file_gpumonitor_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gpumonitor_proto_rawDesc), len(file_gpumonitor_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gpumonitor_proto_goTypes,
+		DependencyIndexes: file_gpumonitor_proto_depIdxs,
+		MessageInfos:      file_gpumonitor_proto_msgTypes,
+	}.Build()
+	File_gpumonitor_proto = out.File
+	file_gpumonitor_proto_goTypes = nil
+	file_gpumonitor_proto_depIdxs = nil
+}
@@ -0,0 +1,177 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: gpumonitor.proto
+
+// The gRPC transport between node servers and the aggregator. The messages
+// mirror the JSON payload of /gpu-info field for field, with the same names;
+// fields added there must be added here too.
+
+package gpumonitorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GPUMonitor_GetNodeInfo_FullMethodName   = "/gpumonitor.v1.GPUMonitor/GetNodeInfo"
+	GPUMonitor_WatchNodeInfo_FullMethodName = "/gpumonitor.v1.GPUMonitor/WatchNodeInfo"
+)
+
+// GPUMonitorClient is the client API for GPUMonitor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// GPUMonitor is served by node servers started with -grpc-port
+type GPUMonitorClient interface {
+	// GetNodeInfo returns the current GPU info of the node, like /gpu-info
+	GetNodeInfo(ctx context.Context, in *NodeInfoRequest, opts ...grpc.CallOption) (*NodeInfo, error)
+	// WatchNodeInfo sends the GPU info of the node right away and then on
+	// every interval, until the client cancels the call
+	WatchNodeInfo(ctx context.Context, in *WatchNodeInfoRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[NodeInfo], error)
+}
+
+type gPUMonitorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGPUMonitorClient(cc grpc.ClientConnInterface) GPUMonitorClient {
+	return &gPUMonitorClient{cc}
+}
+
+func (c *gPUMonitorClient) GetNodeInfo(ctx context.Context, in *NodeInfoRequest, opts ...grpc.CallOption) (*NodeInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NodeInfo)
+	err := c.cc.Invoke(ctx, GPUMonitor_GetNodeInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gPUMonitorClient) WatchNodeInfo(ctx context.Context, in *WatchNodeInfoRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[NodeInfo], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GPUMonitor_ServiceDesc.Streams[0], GPUMonitor_WatchNodeInfo_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchNodeInfoRequest, NodeInfo]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GPUMonitor_WatchNodeInfoClient = grpc.ServerStreamingClient[NodeInfo]
+
+// GPUMonitorServer is the server API for GPUMonitor service.
+// All implementations must embed UnimplementedGPUMonitorServer
+// for forward compatibility.
+//
+// GPUMonitor is served by node servers started with -grpc-port
+type GPUMonitorServer interface {
+	// GetNodeInfo returns the current GPU info of the node, like /gpu-info
+	GetNodeInfo(context.Context, *NodeInfoRequest) (*NodeInfo, error)
+	// WatchNodeInfo sends the GPU info of the node right away and then on
+	// every interval, until the client cancels the call
+	WatchNodeInfo(*WatchNodeInfoRequest, grpc.ServerStreamingServer[NodeInfo]) error
+	mustEmbedUnimplementedGPUMonitorServer()
+}
+
+// UnimplementedGPUMonitorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGPUMonitorServer struct{}
+
+func (UnimplementedGPUMonitorServer) GetNodeInfo(context.Context, *NodeInfoRequest) (*NodeInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNodeInfo not implemented")
+}
+func (UnimplementedGPUMonitorServer) WatchNodeInfo(*WatchNodeInfoRequest, grpc.ServerStreamingServer[NodeInfo]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNodeInfo not implemented")
+}
+func (UnimplementedGPUMonitorServer) mustEmbedUnimplementedGPUMonitorServer() {}
+func (UnimplementedGPUMonitorServer) testEmbeddedByValue()                    {}
+
+// UnsafeGPUMonitorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GPUMonitorServer will
+// result in compilation errors.
+type UnsafeGPUMonitorServer interface {
+	mustEmbedUnimplementedGPUMonitorServer()
+}
+
+func RegisterGPUMonitorServer(s grpc.ServiceRegistrar, srv GPUMonitorServer) {
+	// If the following call pancis, it indicates UnimplementedGPUMonitorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GPUMonitor_ServiceDesc, srv)
+}
+
+func _GPUMonitor_GetNodeInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUMonitorServer).GetNodeInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GPUMonitor_GetNodeInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUMonitorServer).GetNodeInfo(ctx, req.(*NodeInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GPUMonitor_WatchNodeInfo_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchNodeInfoRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GPUMonitorServer).WatchNodeInfo(m, &grpc.GenericServerStream[WatchNodeInfoRequest, NodeInfo]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GPUMonitor_WatchNodeInfoServer = grpc.ServerStreamingServer[NodeInfo]
+
+// GPUMonitor_ServiceDesc is the grpc.ServiceDesc for GPUMonitor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GPUMonitor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gpumonitor.v1.GPUMonitor",
+	HandlerType: (*GPUMonitorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetNodeInfo",
+			Handler:    _GPUMonitor_GetNodeInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchNodeInfo",
+			Handler:       _GPUMonitor_WatchNodeInfo_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gpumonitor.proto",
+}
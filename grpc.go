@@ -0,0 +1,343 @@
+package main
+
+//go:generate buf generate
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gpu-monitor/gpumonitorpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Transports the aggregator polls nodes over
+const (
+	NodeTransportHTTP = "http"
+	NodeTransportGRPC = "grpc"
+)
+
+// minWatchInterval is the shortest interval between the updates of a
+// WatchNodeInfo stream
+const minWatchInterval = time.Second
+
+// validateNodeTransport checks the transport of a node; empty means HTTP
+func validateNodeTransport(node NodeConfig) error {
+	if node.GRPCPort < 0 || node.GRPCPort > 65535 {
+		return fmt.Errorf("node %s: invalid grpc_port %d", node.Name, node.GRPCPort)
+	}
+	switch node.Transport {
+	case "", NodeTransportHTTP:
+		return nil
+	case NodeTransportGRPC:
+		if node.Mode == NodeModePush {
+			return fmt.Errorf("node %s: push nodes cannot use the %q transport", node.Name, NodeTransportGRPC)
+		}
+		if node.GRPCPort == 0 {
+			return fmt.Errorf("node %s: grpc_port is required for the %q transport", node.Name, NodeTransportGRPC)
+		}
+		return nil
+	}
+	return fmt.Errorf("node %s: invalid transport %q, must be %q or %q", node.Name, node.Transport, NodeTransportHTTP, NodeTransportGRPC)
+}
+
+// grpcNodeServer serves the GPU info of this node over gRPC
+type grpcNodeServer struct {
+	gpumonitorpb.UnimplementedGPUMonitorServer
+}
+
+// runGRPCServer runs the gRPC server of a node next to its HTTP server, with
+// the same TLS certificate and token
+func runGRPCServer(port int, certFile, keyFile, token string) {
+	var opts []grpc.ServerOption
+	if certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate for gRPC: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkGRPCToken(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkGRPCToken(stream.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}),
+		)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	server := grpc.NewServer(opts...)
+	gpumonitorpb.RegisterGPUMonitorServer(server, grpcNodeServer{})
+	if certFile != "" {
+		fmt.Printf("gRPC server starting on port %d (TLS)\n", port)
+	} else {
+		fmt.Printf("gRPC server starting on port %d\n", port)
+	}
+	log.Fatal(server.Serve(listener))
+}
+
+// checkGRPCToken checks the bearer token of a call, like requireNodeToken
+// does for HTTP requests
+func checkGRPCToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		provided, found := strings.CutPrefix(value, "Bearer ")
+		if found && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// collectNodeInfo collects the GPU info of this node like /gpu-info
+func collectNodeInfo(ctx context.Context, filter string) (*gpumonitorpb.NodeInfo, error) {
+	if _, err := filterGPUsByActivity(nil, filter); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+	}
+	nodeInfo, err := getNodeInfoFromNvidiaSmi(ctx, true)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get GPU info: %v", err)
+	}
+	nodeInfo.GPUs, _ = filterGPUsByActivity(nodeInfo.GPUs, filter)
+	if serverConfig.WithSystemMetrics {
+		nodeInfo.System = getSystemInfo()
+	}
+	return nodeInfoToProto(nodeInfo), nil
+}
+
+func (grpcNodeServer) GetNodeInfo(ctx context.Context, req *gpumonitorpb.NodeInfoRequest) (*gpumonitorpb.NodeInfo, error) {
+	return collectNodeInfo(ctx, req.GetFilter())
+}
+
+// WatchNodeInfo sends the GPU info on every interval until the client goes
+// away. A failed collection ends the stream, as the client would see it
+// fail when polling.
+func (grpcNodeServer) WatchNodeInfo(req *gpumonitorpb.WatchNodeInfoRequest, stream gpumonitorpb.GPUMonitor_WatchNodeInfoServer) error {
+	interval := max(time.Duration(req.GetIntervalMs())*time.Millisecond, minWatchInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		nodeInfo, err := collectNodeInfo(stream.Context(), req.GetFilter())
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(nodeInfo); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// grpcTokenCredentials sends the bearer token of a node with every call.
+// Like the HTTP client, it also sends it without TLS.
+type grpcTokenCredentials string
+
+func (t grpcTokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (grpcTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// grpcStream is the WatchNodeInfo stream of a node polled over gRPC. Its
+// updates are received in the background and taken by the node's polls, so
+// the node's status and backoff work the same as with HTTP.
+type grpcStream struct {
+	cancel context.CancelFunc
+	conn   *grpc.ClientConn
+	first  chan struct{} // closed on the first update or when the stream ends
+
+	mutex    sync.Mutex
+	latest   *NodeInfo // not yet taken by a poll
+	received time.Time // of the latest update, or when the stream was opened
+	err      error     // why the stream ended
+}
+
+// openGRPCStream connects to the gRPC server of a node and starts watching
+// its GPU info at the node's poll interval
+func (a *Aggregator) openGRPCStream(node NodeConfig) (*grpcStream, error) {
+	creds := insecure.NewCredentials()
+	tlsConfig, err := nodeTLSConfig(node)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if token := a.nodeAuthToken(node); token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(grpcTokenCredentials(token)))
+	}
+	target := net.JoinHostPort(a.nodeHost(node), strconv.Itoa(node.GRPCPort))
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &grpcStream{
+		cancel:   cancel,
+		conn:     conn,
+		first:    make(chan struct{}),
+		received: time.Now(),
+	}
+	req := &gpumonitorpb.WatchNodeInfoRequest{
+		Filter:     node.DefaultFilter,
+		IntervalMs: uint32(a.nodePollInterval(node).Milliseconds()),
+	}
+	go stream.receive(ctx, gpumonitorpb.NewGPUMonitorClient(conn), req)
+	return stream, nil
+}
+
+// receive stores the updates of the stream until it ends
+func (s *grpcStream) receive(ctx context.Context, client gpumonitorpb.GPUMonitorClient, req *gpumonitorpb.WatchNodeInfoRequest) {
+	var once sync.Once
+	defer once.Do(func() { close(s.first) })
+
+	watch, err := client.WatchNodeInfo(ctx, req)
+	for err == nil {
+		var msg *gpumonitorpb.NodeInfo
+		if msg, err = watch.Recv(); err != nil {
+			break
+		}
+		s.mutex.Lock()
+		s.latest = nodeInfoFromProto(msg)
+		s.received = time.Now()
+		s.mutex.Unlock()
+		once.Do(func() { close(s.first) })
+	}
+	if errors.Is(err, io.EOF) {
+		err = status.Error(codes.Unavailable, "stream closed by the node")
+	}
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+}
+
+// take returns the latest update of the stream not taken yet, if any, and
+// when it was received. It fails if the stream ended or has not sent an
+// update for longer than maxSilence.
+func (s *grpcStream) take(maxSilence time.Duration) (*NodeInfo, time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.latest != nil {
+		info := s.latest
+		s.latest = nil
+		return info, s.received, nil
+	}
+	if s.err != nil {
+		return nil, time.Time{}, s.err
+	}
+	if silence := time.Since(s.received); silence > maxSilence {
+		return nil, time.Time{}, status.Errorf(codes.DeadlineExceeded, "no update from gRPC stream for %v", silence.Round(time.Second))
+	}
+	return nil, time.Time{}, nil
+}
+
+// close ends the stream and its connection
+func (s *grpcStream) close() {
+	s.cancel()
+	s.conn.Close()
+}
+
+// grpcStreamFor returns the stream of a node polled over gRPC, opening it
+// if it is not open yet. opened is true for a new stream.
+func (a *Aggregator) grpcStreamFor(node NodeConfig) (stream *grpcStream, opened bool, err error) {
+	a.clientsMutex.Lock()
+	defer a.clientsMutex.Unlock()
+
+	if stream, exists := a.grpcStreams[node.Name]; exists {
+		return stream, false, nil
+	}
+	stream, err = a.openGRPCStream(node)
+	if err != nil {
+		return nil, false, err
+	}
+	a.grpcStreams[node.Name] = stream
+	return stream, true, nil
+}
+
+// closeGRPCStream closes the stream of a node, if any, so that its next poll
+// opens a new one
+func (a *Aggregator) closeGRPCStream(nodeName string) {
+	a.clientsMutex.Lock()
+	stream, exists := a.grpcStreams[nodeName]
+	delete(a.grpcStreams, nodeName)
+	a.clientsMutex.Unlock()
+
+	if exists {
+		stream.close()
+	}
+}
+
+// updateNodeStatusGRPC polls a node over its gRPC stream. Polls between two
+// updates of the stream leave the node's status as it is. A failed stream is
+// closed and opened again by the next poll the node's backoff allows.
+func (a *Aggregator) updateNodeStatusGRPC(ctx context.Context, node NodeConfig) {
+	stream, opened, err := a.grpcStreamFor(node)
+	if err != nil {
+		a.pollFailed(node.Name, "offline", ErrConnect, fmt.Sprintf("Failed to connect over gRPC: %v", err))
+		return
+	}
+	timeout := a.pollTimeout(node)
+	// Wait for the first update of a new stream, like for an HTTP response
+	if opened {
+		select {
+		case <-stream.first:
+		case <-time.After(timeout):
+		case <-ctx.Done():
+		}
+	}
+
+	nodeInfo, received, err := stream.take(a.nodePollInterval(node) + timeout)
+	if err == nil && nodeInfo == nil && opened {
+		err = status.Errorf(codes.DeadlineExceeded, "no update from gRPC stream within %v", timeout)
+	}
+	if err != nil {
+		a.closeGRPCStream(node.Name)
+		switch status.Code(err) {
+		case codes.Unavailable:
+			a.pollFailed(node.Name, "offline", ErrConnect, fmt.Sprintf("Failed to connect over gRPC: %v", status.Convert(err).Message()))
+		case codes.DeadlineExceeded:
+			a.pollFailed(node.Name, "offline", ErrTimeout, status.Convert(err).Message())
+		default:
+			a.pollFailed(node.Name, "error", ErrHTTP, fmt.Sprintf("gRPC error: %s: %s", status.Code(err), status.Convert(err).Message()))
+		}
+		return
+	}
+	if nodeInfo != nil {
+		a.pollSucceeded(node.Name, nodeInfo, received, received)
+	}
+}
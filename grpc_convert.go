@@ -0,0 +1,268 @@
+package main
+
+import (
+	"gpu-monitor/gpumonitorpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// nodeInfoToProto converts the GPU info of this node for the gRPC transport
+func nodeInfoToProto(info *NodeInfo) *gpumonitorpb.NodeInfo {
+	msg := &gpumonitorpb.NodeInfo{
+		SchemaVersion:     int32(info.SchemaVersion),
+		NodeName:          info.NodeName,
+		Timestamp:         timestamppb.New(info.Timestamp),
+		Gpus:              make([]*gpumonitorpb.GPUInfo, len(info.GPUs)),
+		AccountingEnabled: info.AccountingEnabled,
+		ExpectedGpuCount:  int32(info.ExpectedGPUCount),
+		MissingUuids:      info.MissingUUIDs,
+	}
+	for i := range info.GPUs {
+		msg.Gpus[i] = gpuInfoToProto(&info.GPUs[i])
+	}
+	if system := info.System; system != nil {
+		msg.System = &gpumonitorpb.SystemInfo{
+			CpuCount:       int32(system.CPUCount),
+			CpuUtilization: system.CPUUtilization,
+			LoadAvg_1:      system.LoadAvg1,
+			LoadAvg_5:      system.LoadAvg5,
+			LoadAvg_15:     system.LoadAvg15,
+			MemoryTotal:    system.MemoryTotal,
+			MemoryUsed:     system.MemoryUsed,
+			DiskTotal:      system.DiskTotal,
+			DiskUsed:       system.DiskUsed,
+		}
+	}
+	return msg
+}
+
+func gpuInfoToProto(gpu *GPUInfo) *gpumonitorpb.GPUInfo {
+	msg := &gpumonitorpb.GPUInfo{
+		Id:                   gpu.ID,
+		Index:                int32(gpu.Index),
+		Uuid:                 gpu.UUID,
+		Name:                 gpu.Name,
+		Utilization:          gpu.Utilization,
+		UtilizationEma:       gpu.UtilizationEMA,
+		MemoryControllerUtil: gpu.MemoryControllerUtil,
+		EncoderUtil:          gpu.EncoderUtil,
+		DecoderUtil:          gpu.DecoderUtil,
+		MemoryUsed:           gpu.MemoryUsed,
+		MemoryTotal:          gpu.MemoryTotal,
+		Bar1MemoryUsed:       gpu.BAR1MemoryUsed,
+		Bar1MemoryTotal:      gpu.BAR1MemoryTotal,
+		Temperature:          gpu.Temperature,
+		FanSpeed:             gpu.FanSpeed,
+		FanHealthStatus:      string(gpu.FanHealthStatus),
+		PowerUsage:           gpu.PowerUsage,
+		PowerLimit:           gpu.PowerLimit,
+		PowerLimitDrift:      gpu.PowerLimitDrift,
+		SmClock:              gpu.SMClock,
+		MemClock:             gpu.MemClock,
+		MaxSmClock:           gpu.MaxSMClock,
+		MaxMemClock:          gpu.MaxMemClock,
+		ThrottlingPct:        gpu.ThrottlingPct,
+		ThrottleReason:       gpu.ThrottleReason,
+		ThrottleReasons:      gpu.ThrottleReasons,
+		Throttled:            gpu.Throttled,
+		PcieTxThroughput:     gpu.PCIeTxThroughput,
+		PcieRxThroughput:     gpu.PCIeRxThroughput,
+		Processes:            make([]*gpumonitorpb.ProcessInfo, len(gpu.Processes)),
+		ProcessCount:         int32(gpu.ProcessCount),
+		Nvlinks:              make([]*gpumonitorpb.NVLinkInfo, len(gpu.NVLinks)),
+		NvlinkActiveCount:    int32(gpu.NVLinkActiveCount),
+		NvlinkExpectedCount:  int32(gpu.NVLinkExpectedCount),
+		MigDevices:           make([]*gpumonitorpb.MIGDeviceInfo, len(gpu.MIGDevices)),
+	}
+	if ecc := gpu.ECC; ecc != nil {
+		msg.Ecc = &gpumonitorpb.ECCInfo{
+			VolatileCorrectable:    ecc.VolatileCorrectable,
+			VolatileUncorrectable:  ecc.VolatileUncorrectable,
+			AggregateCorrectable:   ecc.AggregateCorrectable,
+			AggregateUncorrectable: ecc.AggregateUncorrectable,
+			RetiredPagesSingleBit:  ecc.RetiredPagesSingleBit,
+			RetiredPagesDoubleBit:  ecc.RetiredPagesDoubleBit,
+			PendingRetirement:      ecc.PendingRetirement,
+		}
+	}
+	for i, proc := range gpu.Processes {
+		msg.Processes[i] = &gpumonitorpb.ProcessInfo{
+			Pid:                  proc.PID,
+			Name:                 proc.Name,
+			Used:                 proc.Used,
+			User:                 proc.User,
+			ContainerId:          proc.ContainerID,
+			ContainerName:        proc.ContainerName,
+			ContainerImage:       proc.ContainerImage,
+			JobId:                proc.JobID,
+			JobUser:              proc.JobUser,
+			PowerShareMilliwatts: proc.PowerShareMilliwatts,
+			SmUtil:               proc.SmUtil,
+			MemUtil:              proc.MemUtil,
+		}
+	}
+	for i, link := range gpu.NVLinks {
+		msg.Nvlinks[i] = &gpumonitorpb.NVLinkInfo{
+			Index:          int32(link.Index),
+			State:          link.State,
+			CrcFlitErrors:  link.CRCFlitErrors,
+			CrcDataErrors:  link.CRCDataErrors,
+			ReplayErrors:   link.ReplayErrors,
+			RecoveryErrors: link.RecoveryErrors,
+			TxBytes:        link.TxBytes,
+			RxBytes:        link.RxBytes,
+			TxThroughput:   link.TxThroughput,
+			RxThroughput:   link.RxThroughput,
+			RemotePciBusId: link.RemotePCIBusID,
+		}
+	}
+	for i, device := range gpu.MIGDevices {
+		msg.MigDevices[i] = &gpumonitorpb.MIGDeviceInfo{
+			Index:               int32(device.Index),
+			Uuid:                device.UUID,
+			Profile:             device.Profile,
+			GpuInstanceId:       int32(device.GPUInstanceID),
+			ComputeInstanceId:   int32(device.ComputeInstanceID),
+			MultiprocessorCount: int32(device.MultiprocessorCount),
+			MemoryUsed:          device.MemoryUsed,
+			MemoryTotal:         device.MemoryTotal,
+			ProcessCount:        int32(device.ProcessCount),
+		}
+	}
+	return msg
+}
+
+// nodeInfoFromProto converts the GPU info received from a node over the
+// gRPC transport. Fields the node's version does not send are left as zero,
+// as with the JSON payload.
+func nodeInfoFromProto(msg *gpumonitorpb.NodeInfo) *NodeInfo {
+	info := &NodeInfo{
+		SchemaVersion:     int(msg.GetSchemaVersion()),
+		NodeName:          msg.GetNodeName(),
+		GPUs:              make([]GPUInfo, len(msg.GetGpus())),
+		AccountingEnabled: msg.GetAccountingEnabled(),
+		ExpectedGPUCount:  int(msg.GetExpectedGpuCount()),
+		MissingUUIDs:      msg.GetMissingUuids(),
+	}
+	if msg.GetTimestamp() != nil {
+		info.Timestamp = msg.GetTimestamp().AsTime()
+	}
+	if info.MissingUUIDs == nil {
+		info.MissingUUIDs = []string{}
+	}
+	for i, gpu := range msg.GetGpus() {
+		info.GPUs[i] = gpuInfoFromProto(gpu)
+	}
+	if system := msg.GetSystem(); system != nil {
+		info.System = &SystemInfo{
+			CPUCount:       int(system.GetCpuCount()),
+			CPUUtilization: system.GetCpuUtilization(),
+			LoadAvg1:       system.GetLoadAvg_1(),
+			LoadAvg5:       system.GetLoadAvg_5(),
+			LoadAvg15:      system.GetLoadAvg_15(),
+			MemoryTotal:    system.GetMemoryTotal(),
+			MemoryUsed:     system.GetMemoryUsed(),
+			DiskTotal:      system.GetDiskTotal(),
+			DiskUsed:       system.GetDiskUsed(),
+		}
+	}
+	return info
+}
+
+// gpuInfoFromProto converts a GPU received over the gRPC transport. The
+// server always includes the processes, so an empty list is not nil.
+func gpuInfoFromProto(msg *gpumonitorpb.GPUInfo) GPUInfo {
+	gpu := GPUInfo{
+		ID:                   msg.GetId(),
+		Index:                int(msg.GetIndex()),
+		UUID:                 msg.GetUuid(),
+		Name:                 msg.GetName(),
+		Utilization:          msg.GetUtilization(),
+		UtilizationEMA:       msg.GetUtilizationEma(),
+		MemoryControllerUtil: msg.GetMemoryControllerUtil(),
+		EncoderUtil:          msg.GetEncoderUtil(),
+		DecoderUtil:          msg.GetDecoderUtil(),
+		MemoryUsed:           msg.GetMemoryUsed(),
+		MemoryTotal:          msg.GetMemoryTotal(),
+		BAR1MemoryUsed:       msg.GetBar1MemoryUsed(),
+		BAR1MemoryTotal:      msg.GetBar1MemoryTotal(),
+		Temperature:          msg.GetTemperature(),
+		FanSpeed:             msg.FanSpeed,
+		FanHealthStatus:      FanHealthStatus(msg.GetFanHealthStatus()),
+		PowerUsage:           msg.GetPowerUsage(),
+		PowerLimit:           msg.GetPowerLimit(),
+		PowerLimitDrift:      msg.GetPowerLimitDrift(),
+		SMClock:              msg.GetSmClock(),
+		MemClock:             msg.GetMemClock(),
+		MaxSMClock:           msg.GetMaxSmClock(),
+		MaxMemClock:          msg.GetMaxMemClock(),
+		ThrottlingPct:        msg.GetThrottlingPct(),
+		ThrottleReason:       msg.GetThrottleReason(),
+		ThrottleReasons:      append([]string{}, msg.GetThrottleReasons()...),
+		Throttled:            msg.GetThrottled(),
+		PCIeTxThroughput:     msg.GetPcieTxThroughput(),
+		PCIeRxThroughput:     msg.GetPcieRxThroughput(),
+		Processes:            make([]ProcessInfo, len(msg.GetProcesses())),
+		ProcessCount:         int(msg.GetProcessCount()),
+		NVLinks:              make([]NVLinkInfo, len(msg.GetNvlinks())),
+		NVLinkActiveCount:    int(msg.GetNvlinkActiveCount()),
+		NVLinkExpectedCount:  int(msg.GetNvlinkExpectedCount()),
+	}
+	if ecc := msg.GetEcc(); ecc != nil {
+		gpu.ECC = &ECCInfo{
+			VolatileCorrectable:    ecc.GetVolatileCorrectable(),
+			VolatileUncorrectable:  ecc.GetVolatileUncorrectable(),
+			AggregateCorrectable:   ecc.GetAggregateCorrectable(),
+			AggregateUncorrectable: ecc.GetAggregateUncorrectable(),
+			RetiredPagesSingleBit:  ecc.GetRetiredPagesSingleBit(),
+			RetiredPagesDoubleBit:  ecc.GetRetiredPagesDoubleBit(),
+			PendingRetirement:      ecc.GetPendingRetirement(),
+		}
+	}
+	for i, proc := range msg.GetProcesses() {
+		gpu.Processes[i] = ProcessInfo{
+			PID:                  proc.GetPid(),
+			Name:                 proc.GetName(),
+			Used:                 proc.GetUsed(),
+			User:                 proc.GetUser(),
+			ContainerID:          proc.GetContainerId(),
+			ContainerName:        proc.GetContainerName(),
+			ContainerImage:       proc.GetContainerImage(),
+			JobID:                proc.GetJobId(),
+			JobUser:              proc.GetJobUser(),
+			PowerShareMilliwatts: proc.GetPowerShareMilliwatts(),
+			SmUtil:               proc.GetSmUtil(),
+			MemUtil:              proc.GetMemUtil(),
+		}
+	}
+	for i, link := range msg.GetNvlinks() {
+		gpu.NVLinks[i] = NVLinkInfo{
+			Index:          int(link.GetIndex()),
+			State:          link.GetState(),
+			CRCFlitErrors:  link.GetCrcFlitErrors(),
+			CRCDataErrors:  link.GetCrcDataErrors(),
+			ReplayErrors:   link.GetReplayErrors(),
+			RecoveryErrors: link.GetRecoveryErrors(),
+			TxBytes:        link.GetTxBytes(),
+			RxBytes:        link.GetRxBytes(),
+			TxThroughput:   link.GetTxThroughput(),
+			RxThroughput:   link.GetRxThroughput(),
+			RemotePCIBusID: link.GetRemotePciBusId(),
+		}
+	}
+	// Like the JSON payload, only GPUs in MIG mode have MIG devices
+	for _, device := range msg.GetMigDevices() {
+		gpu.MIGDevices = append(gpu.MIGDevices, MIGDeviceInfo{
+			Index:               int(device.GetIndex()),
+			UUID:                device.GetUuid(),
+			Profile:             device.GetProfile(),
+			GPUInstanceID:       int(device.GetGpuInstanceId()),
+			ComputeInstanceID:   int(device.GetComputeInstanceId()),
+			MultiprocessorCount: int(device.GetMultiprocessorCount()),
+			MemoryUsed:          device.GetMemoryUsed(),
+			MemoryTotal:         device.GetMemoryTotal(),
+			ProcessCount:        int(device.GetProcessCount()),
+		})
+	}
+	return gpu
+}
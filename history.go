@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of the history section of the config
+const (
+	defaultHistoryRetentionHours    = 24
+	defaultHistoryResolutionSeconds = 60
+)
+
+// HistoryConfig configures the in-memory history of GPU metrics
+type HistoryConfig struct {
+	//doc: Hours of GPU metrics kept for /api/nodes/{name}/history; a negative value disables the history (default 24)
+	RetentionHours int `json:"retention_hours"`
+	//doc: Seconds covered by one stored sample, which averages the polls in that time (default 60)
+	ResolutionSeconds int `json:"resolution_seconds"`
+}
+
+// withDefaults returns the config with unset fields set to their defaults
+func (c HistoryConfig) withDefaults() HistoryConfig {
+	if c.RetentionHours == 0 {
+		c.RetentionHours = defaultHistoryRetentionHours
+	}
+	if c.ResolutionSeconds <= 0 {
+		c.ResolutionSeconds = defaultHistoryResolutionSeconds
+	}
+	return c
+}
+
+// HistorySample is the average of a GPU's metrics over a step
+type HistorySample struct {
+	Time        time.Time `json:"time"` // start of the step
+	Utilization float64   `json:"utilization"`
+	MemoryUsed  uint64    `json:"memory_used"`
+	PowerUsage  uint64    `json:"power_usage"`
+	Temperature float64   `json:"temperature"`
+}
+
+// GPUHistory is the history of a single GPU
+type GPUHistory struct {
+	ID      string          `json:"id"`
+	Samples []HistorySample `json:"samples"`
+}
+
+// HistoryPurgeResponse is returned by the history purge endpoints
+type HistoryPurgeResponse struct {
+	DeletedRows int   `json:"deleted_rows"` // samples dropped
+	DurationMs  int64 `json:"duration_ms"`
+}
+
+// HistoryResponse is returned by the /api/nodes/{name}/history endpoint
+type HistoryResponse struct {
+	Node        string       `json:"node"`
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	StepSeconds int64        `json:"step_seconds"`
+	GPUs        []GPUHistory `json:"gpus"`
+}
+
+// historyBucket sums the polls of a GPU within one resolution interval
+type historyBucket struct {
+	start                                       time.Time
+	polls                                       int
+	utilization, memoryUsed, power, temperature float64
+}
+
+func (b *historyBucket) add(other historyBucket) {
+	b.polls += other.polls
+	b.utilization += other.utilization
+	b.memoryUsed += other.memoryUsed
+	b.power += other.power
+	b.temperature += other.temperature
+}
+
+func (b *historyBucket) sample() HistorySample {
+	n := float64(b.polls)
+	return HistorySample{
+		Time:        b.start,
+		Utilization: roundPercent(b.utilization / n),
+		MemoryUsed:  uint64(b.memoryUsed / n),
+		PowerUsage:  uint64(b.power / n),
+		Temperature: roundPercent(b.temperature / n),
+	}
+}
+
+// historySeries is a ring buffer of the buckets of a GPU, oldest first
+// starting at next once the buffer is full
+type historySeries struct {
+	buckets []historyBucket
+	next    int
+}
+
+// last returns the most recent bucket, or nil if there is none
+func (s *historySeries) last() *historyBucket {
+	if len(s.buckets) == 0 {
+		return nil
+	}
+	if len(s.buckets) < cap(s.buckets) {
+		return &s.buckets[len(s.buckets)-1]
+	}
+	return &s.buckets[(s.next+len(s.buckets)-1)%len(s.buckets)]
+}
+
+// append adds a bucket, overwriting the oldest one when the buffer is full
+func (s *historySeries) append(bucket historyBucket) {
+	if len(s.buckets) < cap(s.buckets) {
+		s.buckets = append(s.buckets, bucket)
+		return
+	}
+	s.buckets[s.next] = bucket
+	s.next = (s.next + 1) % len(s.buckets)
+}
+
+// historyStore keeps the recent metrics of every GPU in memory, one bucket
+// per resolution interval
+type historyStore struct {
+	mutex      sync.Mutex
+	resolution time.Duration
+	retention  time.Duration
+	size       int                                  // buckets per GPU
+	nodes      map[string]map[string]*historySeries // by node name and GPU ID
+}
+
+// newHistoryStore returns the history configured by config, or nil if it is
+// disabled
+func newHistoryStore(config HistoryConfig) *historyStore {
+	config = config.withDefaults()
+	if config.RetentionHours < 0 {
+		return nil
+	}
+	resolution := time.Duration(config.ResolutionSeconds) * time.Second
+	retention := time.Duration(config.RetentionHours) * time.Hour
+	return &historyStore{
+		resolution: resolution,
+		retention:  retention,
+		size:       int(retention/resolution) + 1,
+		nodes:      make(map[string]map[string]*historySeries),
+	}
+}
+
+// record adds a poll of a node's GPUs
+func (h *historyStore) record(nodeName string, at time.Time, gpus []GPUInfo) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	series := h.nodes[nodeName]
+	if series == nil {
+		series = make(map[string]*historySeries)
+		h.nodes[nodeName] = series
+	}
+	start := at.Truncate(h.resolution)
+	for _, gpu := range gpus {
+		poll := historyBucket{
+			start:       start,
+			polls:       1,
+			utilization: gpu.Utilization,
+			memoryUsed:  float64(gpu.MemoryUsed),
+			power:       float64(gpu.PowerUsage),
+			temperature: float64(gpu.Temperature),
+		}
+		s := series[gpu.ID]
+		if s == nil {
+			s = &historySeries{buckets: make([]historyBucket, 0, h.size)}
+			series[gpu.ID] = s
+		}
+		if last := s.last(); last != nil && last.start.Equal(start) {
+			last.add(poll)
+		} else {
+			s.append(poll)
+		}
+	}
+}
+
+// removeNode drops the history of a node
+func (h *historyStore) removeNode(nodeName string) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.nodes, nodeName)
+}
+
+// purge drops the buckets of a node, or of all nodes if nodeName is empty,
+// that start before the given time, or all of them if it is zero. It returns
+// the number of buckets dropped.
+func (h *historyStore) purge(nodeName string, before time.Time) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	deleted := 0
+	for name, series := range h.nodes {
+		if nodeName != "" && name != nodeName {
+			continue
+		}
+		for id, s := range series {
+			kept := make([]historyBucket, 0, h.size)
+			for i := range s.buckets {
+				bucket := s.buckets[(s.next+i)%len(s.buckets)]
+				if before.IsZero() || bucket.start.Before(before) {
+					deleted++
+					continue
+				}
+				kept = append(kept, bucket)
+			}
+			if len(kept) == 0 {
+				delete(series, id)
+				continue
+			}
+			*s = historySeries{buckets: kept}
+		}
+		if len(series) == 0 {
+			delete(h.nodes, name)
+		}
+	}
+	return deleted
+}
+
+// query returns the history of a node's GPUs between from and to, averaged
+// over steps. GPUs are returned in the order of gpuIDs, the node's current
+// GPUs, followed by GPUs that are no longer reported.
+func (h *historyStore) query(nodeName string, gpuIDs []string, from, to time.Time, step time.Duration) []GPUHistory {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	series := h.nodes[nodeName]
+	ids := make([]string, 0, len(series))
+	listed := make(map[string]bool, len(gpuIDs))
+	for _, id := range gpuIDs {
+		if series[id] != nil {
+			ids = append(ids, id)
+			listed[id] = true
+		}
+	}
+	var removed []string
+	for id := range series {
+		if !listed[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	ids = append(ids, removed...)
+
+	history := make([]GPUHistory, 0, len(ids))
+	for _, id := range ids {
+		s := series[id]
+		samples := []HistorySample{}
+		var current *historyBucket
+		for i := range s.buckets {
+			bucket := s.buckets[(s.next+i)%len(s.buckets)]
+			if bucket.start.Before(from) || bucket.start.After(to) {
+				continue
+			}
+			start := bucket.start.Truncate(step)
+			if current != nil && !current.start.Equal(start) {
+				samples = append(samples, current.sample())
+				current = nil
+			}
+			if current == nil {
+				current = &historyBucket{start: start}
+			}
+			current.add(bucket)
+		}
+		if current != nil {
+			samples = append(samples, current.sample())
+		}
+		history = append(history, GPUHistory{ID: id, Samples: samples})
+	}
+	return history
+}
+
+// parseHistoryTime parses a from or to parameter, either RFC 3339 or Unix
+// seconds
+func parseHistoryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseHistoryStep parses a step parameter, either a duration like "5m" or
+// seconds
+func parseHistoryStep(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(seconds) + "s"
+	}
+	step, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return step, nil
+}
+
+// nodeHistoryHandler returns the recent metrics of a node's GPUs. from
+// defaults to the start of the retention and to to now; step defaults to the
+// resolution and is rounded up to a multiple of it.
+func (a *Aggregator) nodeHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if a.history == nil {
+		writeJSONError(w, http.StatusNotFound, "History is not enabled")
+		return
+	}
+	nodeName := r.PathValue("name")
+	status, exists := a.node(nodeName)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now().UTC()
+	if value := query.Get("to"); value != "" {
+		var err error
+		if to, err = parseHistoryTime(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to parameter: %v", err))
+			return
+		}
+	}
+	from := to.Add(-a.history.retention)
+	if value := query.Get("from"); value != "" {
+		var err error
+		if from, err = parseHistoryTime(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from parameter: %v", err))
+			return
+		}
+	}
+	if from.After(to) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid from parameter: after to")
+		return
+	}
+	step := a.history.resolution
+	if value := query.Get("step"); value != "" {
+		var err error
+		if step, err = parseHistoryStep(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid step parameter: %v", err))
+			return
+		}
+		// Buckets cannot be split, so steps are whole buckets
+		if remainder := step % a.history.resolution; remainder != 0 {
+			step += a.history.resolution - remainder
+		}
+	}
+
+	var gpuIDs []string
+	status.mutex.RLock()
+	if status.Data != nil {
+		for _, gpu := range status.Data.GPUs {
+			gpuIDs = append(gpuIDs, gpu.ID)
+		}
+	}
+	status.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistoryResponse{
+		Node:        nodeName,
+		From:        from,
+		To:          to,
+		StepSeconds: int64(step / time.Second),
+		GPUs:        a.history.query(nodeName, gpuIDs, from, to, step),
+	})
+}
+
+// purgeHistoryHandler handles DELETE /api/nodes/{name}/history and
+// DELETE /api/history, which drop the history of one or all nodes, or only
+// its samples before the before parameter
+func (a *Aggregator) purgeHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAdmin(w, r) {
+		return
+	}
+	if a.history == nil {
+		writeJSONError(w, http.StatusNotFound, "History is not enabled")
+		return
+	}
+	nodeName := r.PathValue("name")
+	if nodeName != "" {
+		if _, exists := a.node(nodeName); !exists {
+			writeJSONError(w, http.StatusNotFound, "Node not found")
+			return
+		}
+	}
+	var before time.Time
+	if value := r.URL.Query().Get("before"); value != "" {
+		var err error
+		if before, err = parseHistoryTime(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid before parameter: %v", err))
+			return
+		}
+	}
+
+	start := time.Now()
+	deleted := a.history.purge(nodeName, before)
+	duration := time.Since(start)
+	if nodeName == "" {
+		nodeName = "all nodes"
+	}
+	log.Printf("Purged %d history samples of %s from %s", deleted, nodeName, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistoryPurgeResponse{
+		DeletedRows: deleted,
+		DurationMs:  duration.Milliseconds(),
+	})
+}
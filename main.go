@@ -1,18 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"embed"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,37 +28,249 @@ import (
 //go:embed index.html
 var indexHTML embed.FS
 
+// The config structs below are documented for operators by their //doc:
+// comments, from which config.schema.json and CONFIG.md are generated
+//go:generate go run ./cmd/gen-schema
+
 // NodeConfig represents a node configuration
 type NodeConfig struct {
-	Name  string `json:"name"`
-	Host  string `json:"host"`
-	Port  int    `json:"port"`
+	//doc: Unique name of the node, used in the API and as the node label of metrics
+	Name string `json:"name"`
+	//doc: Host name or IP address of the node's server; optional for push nodes
+	Host string `json:"host"`
+	//doc: Port of the node's server; optional for push nodes
+	Port int `json:"port"`
+	//doc: Display name of the node in the dashboard
 	Alias string `json:"alias"`
+
+	//doc: Timeout of requests to this node in seconds, overriding aggregator.default_poll_timeout_seconds
+	PollTimeoutSeconds int `json:"poll_timeout_seconds,omitempty"`
+	//doc: Seconds between polls of this node, overriding aggregator.poll_interval_seconds, e.g. for nodes behind slow links; rounded up to a multiple of it
+	PollIntervalSeconds int `json:"poll_interval_seconds,omitempty"`
+
+	//doc: Only fetch the node's active or idle GPUs
+	//doc:enum active,idle
+	DefaultFilter string `json:"default_filter,omitempty"`
+
+	//doc: Power limit in mW the node's GPUs should run at, overriding aggregator.expected_power_limit_milliwatts
+	ExpectedPowerLimitMilliwatts uint64 `json:"expected_power_limit_milliwatts,omitempty"`
+
+	//doc: Free-form tags such as datacenter, rack or owner; the first three are exported as the tag_0 to tag_2 metric labels
+	Tags []string `json:"tags,omitempty"`
+
+	// "poll" (the default) or "push" for nodes that push their data to
+	// /api/nodes/{name}/push, authenticated with push_token, because the
+	// aggregator cannot reach them
+	//doc: Whether the aggregator polls the node or waits for it to push its data (default "poll")
+	//doc:enum poll,push
+	Mode string `json:"mode,omitempty"`
+	//doc: Bearer token a push node authenticates its pushes with
+	PushToken string `json:"push_token,omitempty"`
+	//doc: Bearer token sent to the node's server, started with -auth-token, overriding aggregator.node_auth_token
+	AuthToken string `json:"auth_token,omitempty"`
+
+	// HTTPS of nodes started with -tls-cert/-tls-key or -tls-auto. The
+	// certificate is verified against the system roots unless a CA file or
+	// fingerprint is given or verification is skipped.
+	//doc: Whether to poll the node over HTTPS
+	TLS bool `json:"tls,omitempty"`
+	//doc: PEM file of the CA certificates to verify the node's certificate with instead of the system roots
+	TLSCAFile string `json:"tls_ca_file,omitempty"`
+	//doc: SHA-256 fingerprint the node's certificate must have, as printed by -tls-auto; the certificate chain is then not verified
+	TLSFingerprint string `json:"tls_fingerprint,omitempty"`
+	//doc: Whether to accept any certificate of the node, e.g. for self-signed certificates in a trusted network
+	TLSInsecureSkipVerify bool `json:"tls_insecure_skip_verify,omitempty"`
+
+	// The gRPC transport streams the node's data from its server started
+	// with -grpc-port instead of polling /gpu-info; port is still used for
+	// the node's metadata and management endpoints
+	//doc: Whether the aggregator polls the node's /gpu-info over HTTP or watches its gRPC stream (default "http")
+	//doc:enum http,grpc
+	Transport string `json:"transport,omitempty"`
+	//doc: Port of the node's gRPC server, started with -grpc-port; required for the grpc transport
+	GRPCPort int `json:"grpc_port,omitempty"`
 }
 
 // AggregatorConfig represents the aggregator configuration
 type AggregatorConfig struct {
-	Nodes      []NodeConfig `json:"nodes"`
+	//doc: Nodes to monitor
+	Nodes []NodeConfig `json:"nodes"`
+	//doc: Settings of the aggregator itself
 	Aggregator struct {
+		//doc: Port the aggregator listens on (default 8080)
 		Port int `json:"port"`
+
+		// Connection pool of the HTTP client used to poll nodes
+		//doc: Idle connections kept for requests other than polls, e.g. to peers (default 100)
+		MaxIdleConns int `json:"max_idle_conns"`
+		//doc: Idle connections kept per node (default 1)
+		MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+		//doc: Seconds an idle connection is kept open (default 90)
+		IdleConnTimeoutSeconds int `json:"idle_conn_timeout_seconds"`
+
+		//doc: Timeout of requests to nodes in seconds (default 5)
+		DefaultPollTimeoutSeconds int `json:"default_poll_timeout_seconds"`
+		//doc: Seconds between the starts of two poll cycles (default 2)
+		PollIntervalSeconds int `json:"poll_interval_seconds"`
+		//doc: Failed polls in a row after which an online node is marked offline or error; until then it keeps its last data (default 3)
+		FailureThreshold int `json:"failure_threshold"`
+		//doc: Successful polls in a row after which an offline or error node is marked online again (default 2)
+		RecoveryThreshold int `json:"recovery_threshold"`
+		//doc: Longest time in seconds between polls of a failing node, whose interval doubles with every failure once it is marked offline or error; a negative value disables the backoff (default 60)
+		MaxBackoffSeconds int `json:"max_backoff_seconds"`
+
+		//doc: Utilization in percent below which /api/summary counts a GPU as free (default 5)
+		FreeGPUUtilization float64 `json:"free_gpu_utilization"`
+		//doc: Used memory in MiB below which /api/summary counts a GPU as free (default 500)
+		FreeGPUMemoryMiB int `json:"free_gpu_memory_mib"`
+
+		//doc: Bearer token required by the management endpoints, which are disabled without it
+		AdminToken string `json:"admin_token"`
+		//doc: Bearer token sent to all node servers, started with -auth-token
+		NodeAuthToken string `json:"node_auth_token"`
+
+		//doc: Clock skew in seconds between a node and the aggregator that raises a warning (default 5)
+		MaxClockSkewSeconds float64 `json:"max_clock_skew_seconds"`
+
+		//doc: Path prefix to serve the dashboard and API under, e.g. /gpu
+		BasePath string `json:"base_path"`
+
+		//doc: PEM certificate file to serve HTTPS with, together with tls_key_file
+		TLSCertFile string `json:"tls_cert_file"`
+		//doc: PEM key file of tls_cert_file
+		TLSKeyFile string `json:"tls_key_file"`
+
+		//doc: Weight of the latest sample in the GPU utilization moving average, between 0 and 1 (default 0.3)
+		UtilizationEMAAlpha float64 `json:"utilization_ema_alpha"`
+
+		// Power limit all GPUs should run at (zero to not check) and the
+		// difference to it that is still accepted
+		//doc: Power limit in mW all GPUs should run at; 0 disables the check
+		ExpectedPowerLimitMilliwatts uint64 `json:"expected_power_limit_milliwatts"`
+		//doc: Difference in mW to the expected power limit that is still accepted (default 1000)
+		PowerLimitToleranceMilliwatts uint64 `json:"power_limit_tolerance_milliwatts"`
+
+		// Timeouts of client connections to the aggregator's HTTP server and
+		// the time a regular (non-streaming) request may take
+		//doc: Seconds a client may take to send a request (default 10)
+		ReadTimeoutSeconds int `json:"read_timeout_seconds"`
+		//doc: Seconds the aggregator may take to write a response (default 60)
+		WriteTimeoutSeconds int `json:"write_timeout_seconds"`
+		//doc: Seconds an idle client connection is kept open (default 120)
+		IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+		//doc: Seconds a regular, non-streaming request may take before it fails with 503 (default 30)
+		RequestTimeoutSeconds int `json:"request_timeout_seconds"`
+
+		//doc: Number of process start and exit events kept for /api/events (default 1000)
+		EventBufferSize int `json:"event_buffer_size"`
+
+		//doc: Seconds without a push after which a push node is marked offline (default 30)
+		PushTimeoutSeconds int `json:"push_timeout_seconds"`
+
+		// Nodes that register themselves at /api/register
+		//doc: Bearer token nodes register themselves with at /api/register; registration is disabled unless it or admin_token is set
+		RegistrationToken string `json:"registration_token"`
+		//doc: Seconds without a heartbeat after which a registered node is marked offline and no longer polled (default 90)
+		RegistrationStaleSeconds int `json:"registration_stale_seconds"`
+		//doc: Seconds without a heartbeat after which a registered node is removed; a negative value keeps it (default 3600)
+		RegistrationTTLSeconds int `json:"registration_ttl_seconds"`
+		//doc: Seconds without a push after which a registered push node is removed; 0 keeps it. Push nodes in the config file are only marked offline
+		DeadNodeTTLSeconds int `json:"dead_node_ttl_seconds"`
 	} `json:"aggregator"`
+	//doc: Custom DNS server used to resolve node host names
 	DNS struct {
-		Server  string `json:"server"`
-		Enabled bool   `json:"enabled"`
+		//doc: Address of the DNS server, e.g. 127.0.0.1:5353
+		Server string `json:"server"`
+		//doc: Whether to use the DNS server
+		Enabled bool `json:"enabled"`
 	} `json:"dns"`
+	//doc: Minimum changes of GPU metrics reported by the diff endpoints
+	Diff DiffThresholds `json:"diff"`
+	//doc: Peer aggregators whose nodes are shown as well
+	Federation struct {
+		//doc: Peer aggregators
+		Peers []PeerConfig `json:"peers"`
+	} `json:"federation"`
+	//doc: Periodic pushing of metrics to InfluxDB or a Prometheus Pushgateway
+	PushExport PushExportConfig `json:"push_export"`
+	//doc: Pushing of samples to a Prometheus remote_write endpoint
+	RemoteWrite RemoteWriteConfig `json:"remote_write"`
+	//doc: Append-only log of state changes
+	Audit AuditConfig `json:"audit"`
+	//doc: Alert rules and the webhooks alerts are sent to
+	Alerts AlertsConfig `json:"alerts"`
+	//doc: In-memory history of GPU metrics for trend charts
+	History HistoryConfig `json:"history"`
+	//doc: Customization of the dashboard
+	Frontend FrontendConfig `json:"frontend"`
+
+	//doc: File or URL with additional nodes in the format of the nodes section
+	ExternalNodesSource string `json:"external_nodes_source"`
+	//doc: Minutes between reloads of an external_nodes_source URL (default 10)
+	ExternalNodesPollMinutes int `json:"external_nodes_poll_minutes"`
+	//doc: Seconds between checks of the config file for changes, whose nodes are then applied without a restart; a negative value disables the checks, SIGHUP still reloads (default 5)
+	ConfigWatchSeconds int `json:"config_watch_seconds"`
 }
 
 // GPUInfo represents the information of a single GPU
 type GPUInfo struct {
-	ID            string        `json:"id"`
-	Name          string        `json:"name"`
-	Utilization   float64       `json:"utilization"`
-	MemoryUsed    uint64        `json:"memory_used"`
-	MemoryTotal   uint64        `json:"memory_total"`
-	Temperature   uint32        `json:"temperature"`
-	PowerUsage    uint64        `json:"power_usage"`
-	PowerLimit    uint64        `json:"power_limit"`
-	Processes     []ProcessInfo `json:"processes"`
+	ID                   string           `json:"id"`
+	Index                int              `json:"index"` // in nvidia-smi's order, as used by nvidia-smi -i
+	UUID                 string           `json:"uuid"`
+	Name                 string           `json:"name"`
+	Utilization          float64          `json:"utilization"`
+	UtilizationEMA       float64          `json:"utilization_ema"` // moving average, computed by the aggregator
+	MemoryControllerUtil float64          `json:"memory_controller_util"`
+	EncoderUtil          float64          `json:"encoder_util"` // NVENC, in percent
+	DecoderUtil          float64          `json:"decoder_util"` // NVDEC, in percent
+	MemoryUsed           uint64           `json:"memory_used"`
+	MemoryTotal          uint64           `json:"memory_total"`
+	BAR1MemoryUsed       uint64           `json:"bar1_memory_used"`  // 0 when not reported
+	BAR1MemoryTotal      uint64           `json:"bar1_memory_total"` // 0 when not reported
+	Temperature          uint32           `json:"temperature"`
+	FanSpeed             *float64         `json:"fan_speed"`         // percent, null for passively cooled GPUs
+	FanHealthStatus      FanHealthStatus  `json:"fan_health_status"` // set by the aggregator
+	PowerUsage           uint64           `json:"power_usage"`
+	PowerLimit           uint64           `json:"power_limit"`
+	PowerLimitDrift      bool             `json:"power_limit_drift"`  // set by the aggregator
+	SMClock              uint32           `json:"sm_clock"`           // MHz
+	MemClock             uint32           `json:"mem_clock"`          // MHz
+	MaxSMClock           uint32           `json:"max_sm_clock"`       // MHz
+	MaxMemClock          uint32           `json:"max_mem_clock"`      // MHz
+	ThrottlingPct        float64          `json:"throttling_pct"`     // SM clock below the maximum, in percent
+	ThrottleReason       string           `json:"throttle_reason"`    // active clock reasons of a busy throttled GPU
+	ThrottleReasons      []string         `json:"throttle_reasons"`   // active clock reasons, even if the GPU is idle
+	Throttled            bool             `json:"throttled"`          // busy while its SM clock is reduced for a reason
+	PCIeTxThroughput     uint64           `json:"pcie_tx_throughput"` // bytes per second sent by the GPU, 0 when not reported
+	PCIeRxThroughput     uint64           `json:"pcie_rx_throughput"` // bytes per second received by the GPU, 0 when not reported
+	ECC                  *ECCInfo         `json:"ecc"`                // null when ECC is not supported
+	Processes            []ProcessInfo    `json:"processes"`          // null when not requested
+	ProcessCount         int              `json:"process_count"`
+	NVLinks              []NVLinkInfo     `json:"nvlinks"`
+	NVLinkActiveCount    int              `json:"nvlink_active_count"`
+	NVLinkExpectedCount  int              `json:"nvlink_expected_count"`
+	Reservation          *ReservationInfo `json:"reservation,omitempty"` // set by the aggregator
+	MIGDevices           []MIGDeviceInfo  `json:"mig_devices,omitempty"` // only in MIG mode
+}
+
+// NVLinkInfo represents the state of a single NVLink of a GPU
+type NVLinkInfo struct {
+	Index          int    `json:"index"`
+	State          string `json:"state"` // "active", "inactive"
+	CRCFlitErrors  uint64 `json:"crc_flit_errors"`
+	CRCDataErrors  uint64 `json:"crc_data_errors"`
+	ReplayErrors   uint64 `json:"replay_errors"`
+	RecoveryErrors uint64 `json:"recovery_errors"`
+
+	// Data sent and received over the link, 0 when not reported, and the
+	// throughput since the previous sample, computed by the aggregator
+	TxBytes      uint64 `json:"tx_bytes"`
+	RxBytes      uint64 `json:"rx_bytes"`
+	TxThroughput uint64 `json:"tx_throughput"` // bytes per second
+	RxThroughput uint64 `json:"rx_throughput"` // bytes per second
+
+	// Device at the other end of the link, empty if unknown
+	RemotePCIBusID string `json:"remote_pci_bus_id"`
 }
 
 // ProcessInfo represents information about a process using GPU
@@ -61,47 +278,322 @@ type ProcessInfo struct {
 	PID  uint32 `json:"pid"`
 	Name string `json:"name"`
 	Used uint64 `json:"used"`
+	User string `json:"user"` // user the process runs as, empty if unknown
+
+	// Container the process runs in, empty outside of containers; the name
+	// and image are also empty if the container runtime cannot be asked
+	ContainerID    string `json:"container_id"`
+	ContainerName  string `json:"container_name"`
+	ContainerImage string `json:"container_image"`
+
+	// Slurm job the process belongs to, empty unless the server runs with
+	// -slurm; the job's user may differ from User, e.g. for setuid tools
+	JobID   string `json:"job_id"`
+	JobUser string `json:"job_user"`
+
+	// Share of the GPU's power draw attributed to the process, proportional
+	// to its memory usage
+	PowerShareMilliwatts uint64 `json:"power_share_milliwatts"`
+
+	// SM and memory utilization of the process from accounting mode, zero
+	// when accounting is disabled
+	SmUtil  float64 `json:"sm_util"`
+	MemUtil float64 `json:"mem_util"`
 }
 
 // NodeInfo represents the information of a node
 type NodeInfo struct {
-	NodeName    string    `json:"node_name"`
-	Timestamp   time.Time `json:"timestamp"`
-	GPUs        []GPUInfo `json:"gpus"`
+	SchemaVersion int         `json:"schema_version"`
+	NodeName      string      `json:"node_name"`
+	Timestamp     time.Time   `json:"timestamp"`
+	GPUs          []GPUInfo   `json:"gpus"`
+	System        *SystemInfo `json:"system,omitempty"`
+
+	// Whether the per-process utilization comes from accounting mode
+	AccountingEnabled bool `json:"accounting_enabled"`
+
+	// GPUs listed by nvidia-smi -L, and those of them missing from the report
+	ExpectedGPUCount int      `json:"expected_gpu_count"`
+	MissingUUIDs     []string `json:"missing_uuids"`
+}
+
+// ServerConfig represents the configuration of the GPU info server. The
+// fields with JSON tags can be set in the file given by -server-config.
+type ServerConfig struct {
+	WithSystemMetrics bool          `json:"-"`
+	AllowManagement   bool          `json:"-"`
+	EnableAccounting  bool          `json:"-"`
+	TLSAuto           bool          `json:"-"`
+	TLSDir            string        `json:"-"`
+	TLSCertFile       string        `json:"-"`
+	TLSKeyFile        string        `json:"-"`
+	SMITimeout        time.Duration `json:"-"`
+	NvidiaSmiPath     string        `json:"-"`
+	Collector         string        `json:"-"` // "smi" or "nvml"
+	ContainerSocket   string        `json:"-"`
+	Slurm             bool          `json:"-"`
+	ReplayFile        string        `json:"-"`
+	PushURL           string        `json:"-"`
+	PushToken         string        `json:"-"`
+	PushInterval      time.Duration `json:"-"`
+	RegisterURL       string        `json:"-"`
+	RegisterToken     string        `json:"-"`
+	RegisterName      string        `json:"-"`
+	RegisterHost      string        `json:"-"`
+	RegisterInterval  time.Duration `json:"-"`
+	GRPCPort          int           `json:"-"` // 0 disables the gRPC server
+
+	// Bearer token required by all endpoints but /health; none if empty
+	AuthToken string `json:"auth_token"`
+
+	// GPUs (by index, bus ID or UUID) to report, all if empty, and GPUs and
+	// processes (by name) hidden from reporting. Glob patterns and regular
+	// expressions prefixed with "re:" are supported.
+	GPUs                []string `json:"gpus"`
+	ExcludeGPUIDs       []string `json:"exclude_gpu_ids"`
+	ExcludeProcessNames []string `json:"exclude_process_names"`
+
+	// How long nvidia-smi output is shared between requests; zero selects
+	// the default and a negative value disables the cache
+	CacheTTLMs int `json:"cache_ttl_ms"`
 }
 
+// defaultSMITimeout is the maximum time nvidia-smi may run by default
+const defaultSMITimeout = 10 * time.Second
+
+// serverConfig is the configuration of the running GPU info server
+var serverConfig ServerConfig
+
+// nvmlEnabled is set by initServer when the NVML collector is selected and
+// NVML could be loaded
+var nvmlEnabled bool
+
 // NodeStatus represents the status of a node
 type NodeStatus struct {
 	NodeConfig
-	LastUpdate time.Time `json:"last_update"`
-	Status     string    `json:"status"` // "online", "offline", "error"
-	Data       *NodeInfo `json:"data,omitempty"`
-	Error      string    `json:"error,omitempty"`
+	LastUpdate  time.Time     `json:"last_update"`
+	LastSuccess time.Time     `json:"last_success"` // only set by successful polls
+	Status      string        `json:"status"`       // "online", "offline", "error"
+	Data        *NodeInfo     `json:"data,omitempty"`
+	NodeError   *MonitorError `json:"error,omitempty"`
+
+	// Failed polls since the aggregator started, by error code
+	errorCounts [numMonitorErrorCodes]uint64
+	// Stalled polls restarted by the watchdog
+	watchdogRestarts uint64
+
+	LastFetchDurationMs int64 `json:"last_fetch_duration_ms"`
+
+	// Polls in a row that failed or succeeded, for flap suppression, and
+	// when a failing node is polled next while it is backed off
+	ConsecutiveFailures  int        `json:"consecutive_failures"`
+	ConsecutiveSuccesses int        `json:"consecutive_successes"`
+	BackoffUntil         *time.Time `json:"backoff_until,omitempty"`
+
+	// How far the node's clock is ahead of the aggregator's, and a warning
+	// if that exceeds max_clock_skew_seconds
+	ClockSkewSeconds float64 `json:"clock_skew_seconds"`
+	ClockSkewWarning string  `json:"clock_skew_warning,omitempty"`
+
+	// GPUs with memory pages pending retirement, by GPU ID
+	PendingRetirementGPUs []string `json:"pending_retirement_gpus,omitempty"`
+
+	// Payload schema version reported by the node's agent
+	AgentSchemaVersion int `json:"agent_schema_version"`
+	// Newer schema version that has already been warned about
+	warnedSchemaVersion int
+
+	pollStats pollStatsTracker
+
+	// Moving average of the utilization of each GPU, by GPU ID
+	utilizationEMA map[string]float64
+	// GPUs whose power limit drifted from the expected one, by GPU ID
+	powerLimitDrift map[string]bool
+	// Recent temperature and fan speed samples of each GPU, by GPU ID
+	fanHistory map[string][]GPUSample
+	// Busy GPUs that are throttled, by GPU ID
+	throttled map[string]bool
+
+	// Previous poll cycle's sample, retained for diffing
+	prevData   *NodeInfo
+	prevStatus string
+	prevUpdate time.Time
+}
+
+// nodeEntry is the status of a node in the aggregator. Each node has its own
+// lock so that concurrent polls of different nodes do not contend. The
+// aggregator's mutex only guards the nodes map and node list; it is always
+// taken before a node's lock.
+type nodeEntry struct {
+	mutex         sync.RWMutex
+	watch         pollWatch
+	lastPollStart time.Time // start of the cycle the node was last polled in
+	NodeStatus
+}
+
+// snapshot returns a deep copy of the node status that is safe to use after
+// the node's lock has been released. Must be called with the lock held.
+func (s *NodeStatus) snapshot() NodeStatus {
+	c := *s
+	// The push token is a secret and must not be served with the status
+	c.PushToken = ""
+	c.AuthToken = ""
+	c.Data = s.Data.clone()
+	c.prevData = s.prevData.clone()
+	// Detach the latency ring buffer, which is only safe to read under the
+	// node's lock
+	c.pollStats = pollStatsTracker{
+		stats:           s.pollStats.snapshot(),
+		durationCounts:  s.pollStats.durationCounts,
+		durationSeconds: s.pollStats.durationSeconds,
+	}
+	return c
+}
+
+// clone returns a deep copy of the node info
+func (n *NodeInfo) clone() *NodeInfo {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	if n.System != nil {
+		system := *n.System
+		c.System = &system
+	}
+	if n.MissingUUIDs != nil {
+		c.MissingUUIDs = make([]string, len(n.MissingUUIDs))
+		copy(c.MissingUUIDs, n.MissingUUIDs)
+	}
+	if n.GPUs != nil {
+		c.GPUs = make([]GPUInfo, len(n.GPUs))
+		for i, gpu := range n.GPUs {
+			c.GPUs[i] = gpu
+			if gpu.Processes != nil {
+				c.GPUs[i].Processes = make([]ProcessInfo, len(gpu.Processes))
+				copy(c.GPUs[i].Processes, gpu.Processes)
+			}
+			if gpu.ThrottleReasons != nil {
+				c.GPUs[i].ThrottleReasons = make([]string, len(gpu.ThrottleReasons))
+				copy(c.GPUs[i].ThrottleReasons, gpu.ThrottleReasons)
+			}
+			if gpu.NVLinks != nil {
+				c.GPUs[i].NVLinks = make([]NVLinkInfo, len(gpu.NVLinks))
+				copy(c.GPUs[i].NVLinks, gpu.NVLinks)
+			}
+			if gpu.ECC != nil {
+				ecc := *gpu.ECC
+				c.GPUs[i].ECC = &ecc
+			}
+			if gpu.MIGDevices != nil {
+				c.GPUs[i].MIGDevices = make([]MIGDeviceInfo, len(gpu.MIGDevices))
+				copy(c.GPUs[i].MIGDevices, gpu.MIGDevices)
+			}
+		}
+	}
+	return &c
+}
+
+// rotate moves the current sample into the previous slot before it is replaced
+func (s *NodeStatus) rotate() {
+	s.prevData = s.Data
+	s.prevStatus = s.Status
+	s.prevUpdate = s.LastUpdate
 }
 
 // Aggregator holds the state of the aggregator
 type Aggregator struct {
-	config  AggregatorConfig
-	nodes   map[string]*NodeStatus
-	mutex   sync.RWMutex
-	client  *http.Client
+	config AggregatorConfig
+	nodes  map[string]*nodeEntry
+	mutex  sync.RWMutex
+
+	// Nodes in display order: static nodes followed by external and
+	// registered ones
+	nodeList        []NodeConfig
+	externalNodes   []NodeConfig
+	registeredNodes []NodeConfig
+	heartbeats      map[string]time.Time // of registered nodes, by name
+
+	// Config file that nodes added or removed at runtime are saved to
+	configFile string
+	persist    bool
+	client     *http.Client
+
+	// Per-node clients, each with the node's timeout and its own transport
+	// cloned from the poll transport, so that every node keeps its keep-alive
+	// connection regardless of the number of nodes
+	transport    *http.Transport
+	clients      map[string]*http.Client
+	grpcStreams  map[string]*grpcStream // of nodes with the grpc transport
+	clientsMutex sync.Mutex
+
+	startTime       time.Time
+	cyclesCompleted uint64
+	currentCycle    time.Time // start of the running poll cycle, if any
+	lastCycle       PollCycleStats
+
+	metadata      map[string]*metadataCacheEntry
+	metadataMutex sync.Mutex
+
+	remoteWriter *remoteWriter
+	audit        *auditLogger
+	events       *eventLog
+	hub          *eventHub // streams events to /api/events clients
+	reservations *reservationStore
+	history      *historyStore // nil when disabled
+	alerts       *alertEngine  // nil without alert rules
 }
 
 // SMIOutput represents the structure of nvidia-smi XML output
 type SMIOutput struct {
-	AttachedGPUs int   `xml:"attached_gpus"`
-	GPUs         []GPU `xml:"gpu"`
+	DriverVersion string `xml:"driver_version"`
+	CUDAVersion   string `xml:"cuda_version"`
+	AttachedGPUs  int    `xml:"attached_gpus"`
+	GPUs          []GPU  `xml:"gpu"`
 }
 
 // GPU represents a single GPU device
 type GPU struct {
-	ID          string    `xml:"id,attr"`
-	ProductName string    `xml:"product_name"`
-	FBMemory    Memory    `xml:"fb_memory_usage"`
-	Utilization Util      `xml:"utilization"`
-	Temperature Temp      `xml:"temperature"`
-	Power       Power     `xml:"gpu_power_readings"`
-	Processes   Processes `xml:"processes"`
+	ID                   string       `xml:"id,attr"`
+	ProductName          string       `xml:"product_name"`
+	Serial               string       `xml:"serial"`
+	UUID                 string       `xml:"uuid"`
+	VBIOS                string       `xml:"vbios_version"`
+	ComputeMode          string       `xml:"compute_mode"`
+	MIGDevices           MIGDevices   `xml:"mig_devices"`
+	AccountingMode       string       `xml:"accounting_mode"`
+	PCI                  PCI          `xml:"pci"`
+	ECCMode              ECCMode      `xml:"ecc_mode"`
+	ECCErrors            ECCErrors    `xml:"ecc_errors"`
+	RetiredPages         RetiredPages `xml:"retired_pages"`
+	FBMemory             Memory       `xml:"fb_memory_usage"`
+	BAR1Memory           Memory       `xml:"bar1_memory_usage"`
+	Utilization          Util         `xml:"utilization"`
+	Temperature          Temp         `xml:"temperature"`
+	FanSpeed             string       `xml:"fan_speed"`
+	ClockReasons         ClockReasons `xml:"clocks_event_reasons"`
+	ClockReasonsFallback ClockReasons `xml:"clocks_throttle_reasons"` // drivers before 535
+	Clocks               Clocks       `xml:"clocks"`
+	MaxClocks            Clocks       `xml:"max_clocks"`
+	Power                Power        `xml:"gpu_power_readings"`
+	PowerFallback        Power        `xml:"power_readings"` // drivers before 525
+	Processes            Processes    `xml:"processes"`
+	NVLink               NVLink       `xml:"nvlink"`
+}
+
+// PCI represents the PCIe link information of a GPU
+type PCI struct {
+	MaxLinkGen       string `xml:"pci_gpu_link_info>pcie_gen>max_link_gen"`
+	CurrentLinkGen   string `xml:"pci_gpu_link_info>pcie_gen>current_link_gen"`
+	MaxLinkWidth     string `xml:"pci_gpu_link_info>link_widths>max_link_width"`
+	CurrentLinkWidth string `xml:"pci_gpu_link_info>link_widths>current_link_width"`
+	TxUtil           string `xml:"tx_util"` // e.g. "1200 KB/s"
+	RxUtil           string `xml:"rx_util"`
+}
+
+// ECCMode represents the ECC mode of a GPU
+type ECCMode struct {
+	Current string `xml:"current_ecc"`
+	Pending string `xml:"pending_ecc"`
 }
 
 // Memory represents GPU memory usage
@@ -113,7 +605,10 @@ type Memory struct {
 
 // Util represents GPU utilization
 type Util struct {
-	GPU string `xml:"gpu_util"`
+	GPU         string `xml:"gpu_util"`
+	MemUtil     string `xml:"memory_util"`
+	EncoderUtil string `xml:"encoder_util"`
+	DecoderUtil string `xml:"decoder_util"`
 }
 
 // Temp represents GPU temperature
@@ -121,11 +616,38 @@ type Temp struct {
 	GPUTemp string `xml:"gpu_temp"`
 }
 
-// Power represents GPU power usage
+// Power represents GPU power usage. Depending on the driver version, the
+// draw and limit are reported in different fields; normalizeSMIOutput fills
+// PowerDraw and PowerLimit from the ones present.
 type Power struct {
 	PowerDraw   string `xml:"power_draw"`
 	PowerLimit  string `xml:"current_power_limit"`
 	PowerState  string `xml:"power_state"`
+
+	InstantPowerDraw   string `xml:"instant_power_draw"`
+	AveragePowerDraw   string `xml:"average_power_draw"`
+	EnforcedPowerLimit string `xml:"enforced_power_limit"`
+	LegacyPowerLimit   string `xml:"power_limit"`
+}
+
+// NVLink represents the NVLink section of a GPU
+type NVLink struct {
+	Links []NVLinkLink `xml:"link"`
+}
+
+// NVLinkLink represents a single NVLink
+type NVLinkLink struct {
+	ID             string `xml:"id,attr"`
+	State          string `xml:"state"`
+	CRCFlitErrors  string `xml:"crc_flit_errors"`
+	CRCDataErrors  string `xml:"crc_data_errors"`
+	ReplayErrors   string `xml:"replay_errors"`
+	RecoveryErrors string `xml:"recovery_errors"`
+
+	// Not part of nvidia-smi -q; added from nvidia-smi nvlink or NVML
+	TxData         string `xml:"-"` // e.g. "1024 KiB"
+	RxData         string `xml:"-"`
+	RemotePCIBusID string `xml:"-"`
 }
 
 // Processes represents running processes
@@ -139,40 +661,211 @@ type Process struct {
 	ProcessName string `xml:"process_name"`
 	UsedMemory  string `xml:"used_memory"`
 	Type        string `xml:"type"`
+
+	// MIG device the process runs on; "N/A" without MIG
+	GPUInstanceID     string `xml:"gpu_instance_id"`
+	ComputeInstanceID string `xml:"compute_instance_id"`
 }
 
 func main() {
 	// Define command line flags
-	mode := flag.String("mode", "aggregator", "Run mode: 'server' or 'aggregator'")
+	mode := flag.String("mode", "aggregator", "Run mode: 'server', 'exporter', 'aggregator' or 'check-nodes'")
 	port := flag.String("port", "", "Port to listen on (overrides config)")
 	configFile := flag.String("config", "config.json", "Path to config file")
+	persist := flag.Bool("persist", false, "Aggregator mode: save nodes added or removed through the API to the config file")
+	basePath := flag.String("base-path", "", "Aggregator mode: path prefix to serve the dashboard and API under, e.g. /gpu (overrides config)")
+	gpus := flag.String("gpus", "", "Server mode: comma-separated GPU indices, bus IDs or UUIDs to report (overrides the server config file)")
+	var excludeProcesses stringList
+	flag.Var(&excludeProcesses, "exclude-process", "Server mode: glob or re:<regex> pattern of process names to hide; repeatable")
+	serverConfigFile := flag.String("server-config", "", "Server mode: path to optional server config file")
+	withSystemMetrics := flag.Bool("with-system-metrics", runtime.GOOS == "linux", "Server mode: include host CPU/memory/disk metrics")
+	smiTimeout := flag.Duration("smi-timeout", defaultSMITimeout, "Server mode: maximum time nvidia-smi may run before it is killed")
+	nvidiaSmiPath := flag.String("nvidia-smi-path", "nvidia-smi", "Server mode: nvidia-smi binary to run, looked up in PATH if it has no directory")
+	collector := flag.String("collector", "smi", "Server mode: collect GPU info with 'smi' (run nvidia-smi) or 'nvml' (query NVML directly, falling back to nvidia-smi if unavailable)")
+	containerSocket := flag.String("container-socket", "/var/run/docker.sock", "Server mode: Docker-compatible API socket to look up the names and images of the containers of GPU processes; empty to disable")
+	slurm := flag.Bool("slurm", false, "Server mode: map GPU processes to the Slurm jobs they belong to, from their cgroups and scontrol")
+	pushURL := flag.String("push-url", "", "Server mode: also push GPU info to this aggregator push endpoint, e.g. http://aggregator:8080/api/nodes/<name>/push")
+	pushToken := flag.String("push-token", "", "Server mode: bearer token for -push-url")
+	pushInterval := flag.Duration("push-interval", 5*time.Second, "Server mode: interval between pushes to -push-url")
+	registerURL := flag.String("register-url", "", "Server mode: register this node with the aggregator's registration endpoint and send heartbeats, e.g. http://aggregator:8080/api/register")
+	registerToken := flag.String("register-token", "", "Server mode: bearer token for -register-url")
+	registerName := flag.String("register-name", "", "Server mode: node name to register with (default the host name)")
+	registerHost := flag.String("register-host", "", "Server mode: host name or address the aggregator should poll (default the address the registration comes from)")
+	registerInterval := flag.Duration("register-interval", 0, "Server mode: interval between heartbeats to -register-url (default the interval the aggregator asks for)")
+	grpcPort := flag.Int("grpc-port", 0, "Server mode: also serve GPU info over gRPC on this port, for aggregators with the node's transport set to grpc; 0 to disable")
+	replayFile := flag.String("replay-file", "", "Server mode: serve nvidia-smi -q -x output recorded in this file, or in the files of this directory in turn, instead of running nvidia-smi")
+	authToken := flag.String("auth-token", "", "Server mode: bearer token required by all endpoints except /health (overrides the server config file)")
+	allowManagement := flag.Bool("allow-management", false, "Server mode: enable management endpoints such as killing GPU processes")
+	tlsAuto := flag.Bool("tls-auto", false, "Server mode: serve HTTPS with a self-signed certificate, generated if missing or expired")
+	tlsDir := flag.String("tls-dir", defaultTLSDir(), "Server mode: directory of the -tls-auto certificate and key")
+	tlsCert := flag.String("tls-cert", "", "Server and aggregator mode: PEM certificate file to serve HTTPS with (aggregator: overrides config)")
+	tlsKey := flag.String("tls-key", "", "Server and aggregator mode: PEM key file of -tls-cert (aggregator: overrides config)")
+	enableAccounting := flag.Bool("enable-accounting", false, "Server mode: enable nvidia-smi accounting mode at startup for per-process utilization (requires root)")
+	showVersion := flag.Bool("version", false, "Print version information and exit")
 	flag.Parse()
 
+	if *showVersion {
+		printVersion()
+		return
+	}
+
 	switch *mode {
-	case "server":
-		runServer(*port)
+	case "server", "exporter":
+		config := ServerConfig{}
+		if *serverConfigFile != "" {
+			if err := loadServerConfig(*serverConfigFile, &config); err != nil {
+				log.Fatalf("Failed to load server config: %v", err)
+			}
+		}
+		config.WithSystemMetrics = *withSystemMetrics
+		config.AllowManagement = *allowManagement
+		config.EnableAccounting = *enableAccounting
+		config.TLSAuto = *tlsAuto
+		config.TLSDir = *tlsDir
+		config.TLSCertFile = *tlsCert
+		config.TLSKeyFile = *tlsKey
+		if err := validateTLSFiles(config.TLSCertFile, config.TLSKeyFile); err != nil {
+			log.Fatalf("Invalid TLS flags: %v", err)
+		}
+		if config.TLSAuto && config.TLSCertFile != "" {
+			log.Fatalf("Invalid TLS flags: -tls-auto and -tls-cert are mutually exclusive")
+		}
+		config.SMITimeout = *smiTimeout
+		config.NvidiaSmiPath = *nvidiaSmiPath
+		config.Collector = *collector
+		if config.Collector != "smi" && config.Collector != "nvml" {
+			log.Fatalf("Invalid collector: %s. Use 'smi' or 'nvml'", config.Collector)
+		}
+		config.ContainerSocket = *containerSocket
+		config.Slurm = *slurm
+		config.ReplayFile = *replayFile
+		config.PushURL = *pushURL
+		config.PushToken = *pushToken
+		config.PushInterval = *pushInterval
+		config.RegisterURL = *registerURL
+		config.RegisterToken = *registerToken
+		config.RegisterName = *registerName
+		config.RegisterHost = *registerHost
+		config.RegisterInterval = *registerInterval
+		config.GRPCPort = *grpcPort
+		if config.GRPCPort < 0 || config.GRPCPort > 65535 {
+			log.Fatalf("Invalid gRPC port: %d", config.GRPCPort)
+		}
+		if *authToken != "" {
+			config.AuthToken = *authToken
+		}
+		if config.PushURL != "" && config.PushInterval <= 0 {
+			log.Fatalf("Invalid push interval: %v", config.PushInterval)
+		}
+		if *gpus != "" {
+			config.GPUs = strings.Split(*gpus, ",")
+		}
+		config.ExcludeProcessNames = append(config.ExcludeProcessNames, excludeProcesses...)
+		if err := validatePatterns(config); err != nil {
+			log.Fatalf("Invalid server config: %v", err)
+		}
+		if *mode == "exporter" {
+			runExporter(*port, config)
+			return
+		}
+		runServer(*port, config)
 	case "aggregator":
-		runAggregator(*configFile, *port)
+		runAggregator(*configFile, *port, *basePath, *tlsCert, *tlsKey, *persist)
+	case "check-nodes":
+		runCheckNodes(*configFile)
 	default:
-		log.Fatalf("Invalid mode: %s. Use 'server' or 'aggregator'", *mode)
+		log.Fatalf("Invalid mode: %s. Use 'server', 'exporter', 'aggregator' or 'check-nodes'", *mode)
+	}
+}
+
+// initServer applies the server config shared by the server and exporter
+// modes
+func initServer(config ServerConfig) {
+	serverConfig = config
+	if config.ReplayFile != "" {
+		replay, err := newSMIReplay(config.ReplayFile)
+		if err != nil {
+			log.Fatalf("Failed to load replay file: %v", err)
+		}
+		smiReplaySource = replay
+		log.Printf("Replaying nvidia-smi output from %s (%d files)", config.ReplayFile, len(replay.files))
+	}
+	if config.Collector == "nvml" && smiReplaySource == nil {
+		if err := initNVML(); err != nil {
+			log.Printf("NVML is not available, falling back to nvidia-smi: %v", err)
+		} else {
+			nvmlEnabled = true
+			log.Printf("Collecting GPU info with NVML")
+		}
+	}
+	if ttl := smiCacheTTL(config); ttl > 0 {
+		smiOutputCache = newSMICache(ttl)
+	}
+	if config.EnableAccounting {
+		enableAccounting()
 	}
 }
 
 // runServer runs the GPU info server
-func runServer(port string) {
+func runServer(port string, config ServerConfig) {
 	if port == "" {
 		port = "8081"
 	}
+	initServer(config)
+	if config.PushURL != "" {
+		go runPusher(config.PushURL, config.PushToken, config.PushInterval)
+	}
 
 	http.HandleFunc("/gpu-info", gpuInfoHandler)
+	http.HandleFunc("/gpu-metadata", gpuMetadataHandler)
+	http.HandleFunc("/nvidia-smi-version", smiVersionHandler)
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/api/version", versionHandler)
+	http.HandleFunc("/metrics", newGPUExporter().metricsHandler)
+	if config.AllowManagement {
+		http.HandleFunc("/gpu-kill-process", killProcessHandler)
+	}
+
+	var handler http.Handler = http.DefaultServeMux
+	if config.AuthToken != "" {
+		handler = requireNodeToken(handler, config.AuthToken)
+	}
 
+	certFile, keyFile, fingerprint := config.TLSCertFile, config.TLSKeyFile, ""
+	if config.TLSAuto {
+		var cert *x509.Certificate
+		var err error
+		certFile, keyFile, cert, err = ensureSelfSignedCert(config.TLSDir)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS certificate: %v", err)
+		}
+		fingerprint = certFingerprint(cert)
+		fmt.Printf("Using TLS certificate %s (expires %s)\n", certFile, cert.NotAfter.Format(time.RFC3339))
+		fmt.Printf("Certificate SHA-256 fingerprint: %s\n", fingerprint)
+	}
+
+	if config.RegisterURL != "" {
+		node, err := registrationConfig(config, port, fingerprint)
+		if err != nil {
+			log.Fatalf("Failed to register: %v", err)
+		}
+		go runRegistrar(config.RegisterURL, config.RegisterToken, node, config.RegisterInterval)
+	}
+
+	if config.GRPCPort > 0 {
+		go runGRPCServer(config.GRPCPort, certFile, keyFile, config.AuthToken)
+	}
+
+	if certFile != "" {
+		fmt.Printf("GPU Server starting on port %s (HTTPS)\n", port)
+		log.Fatal(http.ListenAndServeTLS(":"+port, certFile, keyFile, handler))
+	}
 	fmt.Printf("GPU Server starting on port %s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
 
 // runAggregator runs the aggregator server
-func runAggregator(configFile, portOverride string) {
+func runAggregator(configFile, portOverride, basePathOverride, tlsCertOverride, tlsKeyOverride string, persist bool) {
 	// Load configuration
 	config, err := loadConfig(configFile)
 	if err != nil {
@@ -188,58 +881,291 @@ func runAggregator(configFile, portOverride string) {
 	} else if config.Aggregator.Port == 0 {
 		config.Aggregator.Port = 8080
 	}
+	if basePathOverride != "" {
+		config.Aggregator.BasePath = basePathOverride
+	}
+	if tlsCertOverride != "" || tlsKeyOverride != "" {
+		config.Aggregator.TLSCertFile = tlsCertOverride
+		config.Aggregator.TLSKeyFile = tlsKeyOverride
+	}
+	config.Aggregator.BasePath, err = normalizeBasePath(config.Aggregator.BasePath)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := prepareAggregatorConfig(config); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
 
 	// Create aggregator
-	aggregator := &Aggregator{
-		config: *config,
-		nodes:  make(map[string]*NodeStatus),
-		client: &http.Client{
-			Timeout: 2 * time.Second,
-		},
+	aggregator := newAggregator(config, configFile, persist)
+	if config.Audit.File != "" {
+		aggregator.audit, err = newAuditLogger(config.Audit)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+	}
+	aggregator.alerts, err = newAlertEngine(config.Alerts, aggregator.client, aggregator.audit)
+	if err != nil {
+		log.Fatalf("Failed to set up alerting: %v", err)
 	}
+	if config.ExternalNodesSource != "" {
+		aggregator.reloadExternalNodes()
+		go aggregator.watchExternalNodes()
+	}
+	go aggregator.watchConfig()
 
-	// Initialize node statuses in the order they appear in config
-	for _, node := range config.Nodes {
-		aggregator.nodes[node.Name] = &NodeStatus{
-			NodeConfig: node,
-			Status:     "unknown",
-		}
+	if config.RemoteWrite.URL != "" {
+		aggregator.remoteWriter = newRemoteWriter(config.RemoteWrite, aggregator.client)
 	}
 
-	// Start background polling
+	// Poll once before serving so that the first responses already have node
+	// data, then keep polling in the background
+	aggregator.updateNodeStatuses()
+	log.Printf("Initial poll completed in %dms: %d of %d nodes online",
+		aggregator.lastCycle.DurationMs, aggregator.lastCycle.NodesSucceeded,
+		aggregator.lastCycle.NodesSucceeded+aggregator.lastCycle.NodesFailed)
 	go aggregator.pollNodes()
+	go aggregator.watchdogLoop()
+	if config.PushExport.Type != "" {
+		go startPushExporter(aggregator)
+	}
+	if aggregator.remoteWriter != nil {
+		go aggregator.remoteWriter.run()
+	}
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%d", config.Aggregator.Port)
 	http.HandleFunc("/api/nodes", aggregator.nodesHandler)
-	http.HandleFunc("/api/nodes/", aggregator.nodeHandler)
-	http.Handle("/", http.FileServer(http.FS(indexHTML)))
+	http.HandleFunc("GET /api/nodes/flat", aggregator.flatNodesHandler)
+	http.HandleFunc("GET /api/summary", aggregator.summaryHandler)
+	http.HandleFunc("GET /api/free-gpus", aggregator.freeGPUsHandler)
+	http.HandleFunc("GET /api/nodes/{name}", aggregator.nodeHandler)
+	http.HandleFunc("DELETE /api/nodes/{name}", aggregator.removeNodeHandler)
+	http.HandleFunc("GET /api/nodes/{name}/metadata", aggregator.nodeMetadataHandler)
+	http.HandleFunc("GET /api/nodes/{name}/diff", aggregator.nodeDiffHandler)
+	http.HandleFunc("GET /api/nodes/{name}/poll-stats", aggregator.nodePollStatsHandler)
+	http.HandleFunc("GET /api/nodes/{name}/history", aggregator.nodeHistoryHandler)
+	http.HandleFunc("DELETE /api/nodes/{name}/history", aggregator.purgeHistoryHandler)
+	http.HandleFunc("DELETE /api/history", aggregator.purgeHistoryHandler)
+	http.HandleFunc("GET /api/nodes/{name}/gpus/{gpu_id}", aggregator.nodeGPUHandler)
+	http.HandleFunc("GET /api/nodes/{name}/topology", aggregator.nodeTopologyHandler)
+	http.HandleFunc("POST /api/nodes/{name}/processes/{pid}/kill", aggregator.nodeProcessHandler)
+	http.HandleFunc("POST /api/nodes/{name}/push", aggregator.nodePushHandler)
+	http.HandleFunc("POST /api/register", aggregator.registerHandler)
+	http.HandleFunc("/api/diff", aggregator.diffHandler)
+	http.HandleFunc("/api/federated", aggregator.federatedHandler)
+	http.HandleFunc("/health", aggregator.healthHandler)
+	http.HandleFunc("/api/version", versionHandler)
+	http.HandleFunc("/ready", aggregator.readyHandler)
+	http.HandleFunc("/api/stats", aggregator.statsHandler)
+	http.HandleFunc("/api/audit", aggregator.auditHandler)
+	http.HandleFunc("GET /api/events", aggregator.eventsHandler)
+	http.HandleFunc("GET /api/alerts", aggregator.alertsHandler)
+	http.HandleFunc("GET /api/alerts/history", aggregator.alertHistoryHandler)
+	http.HandleFunc("POST /api/alerts/{id}/silence", aggregator.silenceAlertHandler)
+	http.HandleFunc("GET /api/admin/silences", aggregator.silencesHandler)
+	http.HandleFunc("POST /api/admin/silences", aggregator.createSilenceHandler)
+	http.HandleFunc("DELETE /api/admin/silences/{id}", aggregator.deleteSilenceHandler)
+	http.HandleFunc("GET /api/reservations", aggregator.reservationsHandler)
+	http.HandleFunc("POST /api/reservations", aggregator.createReservationHandler)
+	http.HandleFunc("DELETE /api/reservations/{id}", aggregator.releaseReservationHandler)
+	http.HandleFunc("/api/config/frontend", aggregator.frontendConfigHandler)
+	http.HandleFunc("/metrics", aggregator.metricsHandler)
+	http.HandleFunc("/debug/config", aggregator.debugConfigHandler)
+	index, err := indexHandler(config.Aggregator.BasePath)
+	if err != nil {
+		log.Fatalf("Failed to load dashboard: %v", err)
+	}
+	http.Handle("/", index)
 
-	fmt.Printf("Aggregator server starting on %s\n", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	server := newAggregatorServer(addr, withBasePath(config.Aggregator.BasePath, http.DefaultServeMux), config)
+	if config.Aggregator.TLSCertFile != "" {
+		fmt.Printf("Aggregator server starting on %s%s (HTTPS)\n", addr, config.Aggregator.BasePath)
+		log.Fatal(server.ListenAndServeTLS(config.Aggregator.TLSCertFile, config.Aggregator.TLSKeyFile))
+	}
+	fmt.Printf("Aggregator server starting on %s%s\n", addr, config.Aggregator.BasePath)
+	log.Fatal(server.ListenAndServe())
 }
 
-func loadConfig(filename string) (*AggregatorConfig, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// prepareAggregatorConfig validates the aggregator configuration and fills in
+// defaults
+func prepareAggregatorConfig(config *AggregatorConfig) error {
+	if config.Aggregator.DefaultPollTimeoutSeconds <= 0 {
+		config.Aggregator.DefaultPollTimeoutSeconds = 5
+	}
+	if config.ExternalNodesPollMinutes <= 0 {
+		config.ExternalNodesPollMinutes = 10
+	}
+	if config.ConfigWatchSeconds == 0 {
+		config.ConfigWatchSeconds = defaultConfigWatchSeconds
+	}
+	setServerTimeoutDefaults(config)
+	if config.Aggregator.EventBufferSize <= 0 {
+		config.Aggregator.EventBufferSize = defaultEventBufferSize
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, err
+	if err := validatePushExport(config.PushExport); err != nil {
+		return err
+	}
+	if err := validateFrontendConfig(config.Frontend); err != nil {
+		return err
+	}
+	if err := validateAlertsConfig(config.Alerts); err != nil {
+		return err
+	}
+	if err := validateEMAAlpha(config.Aggregator.UtilizationEMAAlpha); err != nil {
+		return err
+	}
+	if err := validateTLSFiles(config.Aggregator.TLSCertFile, config.Aggregator.TLSKeyFile); err != nil {
+		return fmt.Errorf("aggregator: %v", err)
+	}
+	if config.Aggregator.UtilizationEMAAlpha == 0 {
+		config.Aggregator.UtilizationEMAAlpha = defaultUtilizationEMAAlpha
+	}
+	if config.Aggregator.Port < 0 || config.Aggregator.Port > 65535 {
+		return fmt.Errorf("aggregator: invalid port %d", config.Aggregator.Port)
+	}
+	return validateNodes(config.Nodes)
+}
+
+// validateNodes checks the configured nodes, reporting every invalid node
+// rather than only the first
+func validateNodes(nodes []NodeConfig) error {
+	var errs []error
+	seen := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		if err := validateNodeConfig(node); err != nil {
+			errs = append(errs, fmt.Errorf("nodes[%d]: %v", i, err))
+			continue
+		}
+		if first, duplicate := seen[node.Name]; duplicate {
+			errs = append(errs, fmt.Errorf("nodes[%d]: node %s: duplicate name, also used by nodes[%d]", i, node.Name, first))
+			continue
+		}
+		seen[node.Name] = i
 	}
+	return errors.Join(errs...)
+}
+
+// newAggregator creates an aggregator for the configured nodes. Polling and
+// the HTTP server are started by the caller.
+func newAggregator(config *AggregatorConfig, configFile string, persist bool) *Aggregator {
+	transport := newPollTransport(config)
+	aggregator := &Aggregator{
+		config:     *config,
+		nodes:      make(map[string]*nodeEntry),
+		heartbeats: make(map[string]time.Time),
+		client: &http.Client{
+			Timeout:   2 * time.Second,
+			Transport: transport,
+		},
+		transport:    transport,
+		clients:      make(map[string]*http.Client),
+		grpcStreams:  make(map[string]*grpcStream),
+		startTime:    time.Now(),
+		metadata:     make(map[string]*metadataCacheEntry),
+		events:       newEventLog(config.Aggregator.EventBufferSize),
+		hub:          newEventHub(),
+		reservations: newReservationStore(),
+		history:      newHistoryStore(config.History),
+		configFile:   configFile,
+		persist:      persist,
+	}
+
+	// Initialize node statuses in the order they appear in config
+	for _, node := range config.Nodes {
+		aggregator.nodes[node.Name] = &nodeEntry{NodeStatus: NodeStatus{
+			NodeConfig: node,
+			Status:     "unknown",
+		}}
+	}
+	aggregator.nodeList = config.Nodes
+	return aggregator
+}
 
+// newPollTransport creates the HTTP transport used for requests that are not
+// made with a node's client, and the template of the per-node transports.
+// Keep-alive connections are pooled so that repeated requests reuse them.
+func newPollTransport(config *AggregatorConfig) *http.Transport {
+	maxIdleConns := config.Aggregator.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = 100
+	}
+	// A single idle connection per node is enough for polling every few
+	// seconds
+	maxIdleConnsPerHost := config.Aggregator.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = 1
+	}
+	idleConnTimeout := time.Duration(config.Aggregator.IdleConnTimeoutSeconds) * time.Second
+	if idleConnTimeout == 0 {
+		idleConnTimeout = 90 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.IdleConnTimeout = idleConnTimeout
+	return transport
+}
+
+// loadConfig reads the aggregator config file, in JSON, YAML or TOML
+func loadConfig(filename string) (*AggregatorConfig, error) {
 	var config AggregatorConfig
-	err = json.Unmarshal(data, &config)
-	if err != nil {
+	if err := readConfigFile(filename, &config); err != nil {
 		return nil, err
 	}
-
 	return &config, nil
 }
 
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// loadServerConfig reads the server config file into config
+func loadServerConfig(filename string, config *ServerConfig) error {
+	return readConfigFile(filename, config)
+}
+
+// APIError is the body of JSON error responses
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// APIErrorDetail describes an error: the HTTP status code and a message
+type APIErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSONError replies with an APIError, a structured alternative to
+// http.Error that clients can parse uniformly
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{Error: APIErrorDetail{Code: status, Message: message}})
+}
+
+// errorMessage extracts the message of an error response body, which is
+// either an APIError or plain text
+func errorMessage(body []byte) string {
+	var apiErr APIError
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+		return apiErr.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
 func parsePort(portStr string) (int, error) {
 	if portStr == "" {
 		return 0, fmt.Errorf("empty port string")
@@ -266,50 +1192,161 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 
 func gpuInfoHandler(w http.ResponseWriter, r *http.Request) {
 	// Get GPU info using nvidia-smi
-	gpus, err := getGPUInfoFromNvidiaSmi()
+	includeProcesses := true
+	if value := r.URL.Query().Get("include_processes"); value != "" {
+		var err error
+		includeProcesses, err = strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid include_processes parameter: %v", err))
+			return
+		}
+	}
+
+	nodeInfo, err := getNodeInfoFromNvidiaSmi(r.Context(), includeProcesses)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get GPU info: %v", err))
+		return
+	}
+	nodeInfo.GPUs, err = filterGPUsByActivity(nodeInfo.GPUs, r.URL.Query().Get("filter"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get GPU info: %v", err), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filter parameter: %v", err))
 		return
 	}
 
-	nodeInfo := NodeInfo{
-		NodeName:  getHostname(),
-		Timestamp: time.Now(),
-		GPUs:      gpus,
+	if serverConfig.WithSystemMetrics {
+		nodeInfo.System = getSystemInfo()
 	}
 
+	w.Header().Add("Vary", "Accept")
+	if wantsMsgpack(r) {
+		w.Header().Set("Content-Type", msgpackContentType)
+		w.Write(encodeMsgpack(nodeInfo))
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(nodeInfo)
+	if err := writeNodeInfoJSON(w, nodeInfo); err != nil {
+		log.Printf("Failed to write GPU info: %v", err)
+	}
 }
 
-func getGPUInfoFromNvidiaSmi() ([]GPUInfo, error) {
-	// Run nvidia-smi command to get GPU information in XML format
-	cmd := exec.Command("nvidia-smi", "-q", "-x")
+// runNvidiaSmiCommand runs nvidia-smi with the given arguments. It is killed
+// along with its children when ctx is done (e.g. the HTTP client went away)
+// or when it does not finish within the configured timeout (e.g. because a
+// GPU fell off the bus).
+func runNvidiaSmiCommand(ctx context.Context, args ...string) ([]byte, error) {
+	timeout := serverConfig.SMITimeout
+	if timeout <= 0 {
+		timeout = defaultSMITimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	smiPath := serverConfig.NvidiaSmiPath
+	if smiPath == "" {
+		smiPath = "nvidia-smi"
+	}
+	cmd := exec.CommandContext(ctx, smiPath, args...)
+	killProcessGroupOnCancel(cmd)
+	// Don't wait on output pipes held open by orphaned children
+	cmd.WaitDelay = time.Second
+
 	output, err := cmd.Output()
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return nil, fmt.Errorf("nvidia-smi timed out after %v", timeout)
+	case context.Canceled:
+		return nil, fmt.Errorf("nvidia-smi canceled: %v", ctx.Err())
+	}
+	return output, err
+}
+
+// runNvidiaSmi returns the parsed output of nvidia-smi, reusing recent
+// output while it is fresh
+func runNvidiaSmi(ctx context.Context) (*SMIOutput, error) {
+	if smiOutputCache != nil {
+		return smiOutputCache.get(ctx, fetchNvidiaSmi)
+	}
+	return fetchNvidiaSmi(ctx)
+}
+
+// fetchNvidiaSmi runs nvidia-smi, or reads the next replay file, and parses
+// its output. With the NVML collector, NVML is queried instead, and
+// nvidia-smi is only run if that fails.
+func fetchNvidiaSmi(ctx context.Context) (*SMIOutput, error) {
+	if smiReplaySource != nil {
+		file, err := smiReplaySource.open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replay file: %v", err)
+		}
+		defer file.Close()
+		return parseSMIOutput(file)
+	}
+	if nvmlEnabled {
+		smiOutput, err := collectNVML(ctx)
+		if err == nil {
+			return smiOutput, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("Failed to collect GPU info with NVML, running nvidia-smi: %v", err)
+	}
+
+	// Run nvidia-smi command to get GPU information in XML format
+	output, err := runNvidiaSmiCommand(ctx, "-q", "-x")
 	if err != nil {
 		return nil, fmt.Errorf("failed to run nvidia-smi: %v", err)
 	}
+	smiOutput, err := parseSMIOutput(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	addNVLinkCounters(ctx, smiOutput)
+	return smiOutput, nil
+}
 
-	// Parse the XML output
+// parseSMIOutput parses the XML output of nvidia-smi -q -x
+func parseSMIOutput(r io.Reader) (*SMIOutput, error) {
 	var smiOutput SMIOutput
-	err = xml.Unmarshal(output, &smiOutput)
-	if err != nil {
+	if err := xml.NewDecoder(r).Decode(&smiOutput); err != nil {
 		return nil, fmt.Errorf("failed to parse nvidia-smi XML output: %v", err)
 	}
+	normalizeSMIOutput(&smiOutput)
+
+	return &smiOutput, nil
+}
+
+// getGPUInfoFromNvidiaSmi collects the GPU info of this node
+func getGPUInfoFromNvidiaSmi(ctx context.Context) ([]GPUInfo, error) {
+	nodeInfo, err := getNodeInfoFromNvidiaSmi(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return nodeInfo.GPUs, nil
+}
+
+// getNodeInfoFromNvidiaSmi collects the GPU info of this node along with
+// whether accounting data is included and which GPUs are missing. Without
+// includeProcesses only the number of processes of each GPU is reported, and
+// the per-process lookups are skipped.
+func getNodeInfoFromNvidiaSmi(ctx context.Context, includeProcesses bool) (*NodeInfo, error) {
+	smiOutput, err := runNvidiaSmi(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Convert to our GPUInfo format
 	gpus := make([]GPUInfo, len(smiOutput.GPUs))
 	for i, gpu := range smiOutput.GPUs {
 		// Parse utilization
-		utilization := 0.0
-		if strings.HasSuffix(gpu.Utilization.GPU, " %") {
-			utilStr := strings.TrimSuffix(gpu.Utilization.GPU, " %")
-			utilization, _ = strconv.ParseFloat(utilStr, 64)
-		}
+		utilization := parsePercentValue(gpu.Utilization.GPU)
+		memoryControllerUtil := parsePercentValue(gpu.Utilization.MemUtil)
 		
 		// Parse memory
 		memoryUsed := parseMemoryValue(gpu.FBMemory.Used)
 		memoryTotal := parseMemoryValue(gpu.FBMemory.Total)
+		bar1MemoryUsed := parseMemoryValue(gpu.BAR1Memory.Used)
+		bar1MemoryTotal := parseMemoryValue(gpu.BAR1Memory.Total)
 		
 		// Parse temperature
 		temperature := uint32(0)
@@ -319,6 +1356,13 @@ func getGPUInfoFromNvidiaSmi() ([]GPUInfo, error) {
 			temperature = uint32(tempVal)
 		}
 		
+		// Parse fan speed; "N/A" means the GPU has no fan
+		var fanSpeed *float64
+		if strings.HasSuffix(gpu.FanSpeed, " %") {
+			speed := parsePercentValue(gpu.FanSpeed)
+			fanSpeed = &speed
+		}
+		
 		// Parse power - handle different formats
 		powerUsage := parsePowerValue(gpu.Power.PowerDraw)
 		powerLimit := parsePowerValue(gpu.Power.PowerLimit)
@@ -329,13 +1373,22 @@ func getGPUInfoFromNvidiaSmi() ([]GPUInfo, error) {
 			usedMemory := parseMemoryValue(proc.UsedMemory)
 			pid, _ := strconv.ParseUint(proc.PID, 10, 32)
 			
-			// Skip processes with 0 memory usage
-			if usedMemory > 0 {
-				processes = append(processes, ProcessInfo{
+			// Skip processes with 0 memory usage and excluded processes
+			if usedMemory > 0 && !isProcessExcluded(proc.ProcessName) {
+				info := ProcessInfo{
 					PID:  uint32(pid),
 					Name: proc.ProcessName,
 					Used: usedMemory,
-				})
+				}
+				// Replayed PIDs are not processes of this host, and only
+				// the number of processes is reported without
+				// includeProcesses
+				if smiReplaySource == nil && includeProcesses {
+					info.User = processUser(info.PID)
+					info.ContainerID, info.ContainerName, info.ContainerImage = processContainer(ctx, info.PID)
+					info.JobID, info.JobUser = processSlurmJob(ctx, info.PID)
+				}
+				processes = append(processes, info)
 			}
 		}
 		
@@ -343,21 +1396,110 @@ func getGPUInfoFromNvidiaSmi() ([]GPUInfo, error) {
 		sort.Slice(processes, func(i, j int) bool {
 			return processes[i].Used > processes[j].Used
 		})
+		attributePower(processes, powerUsage)
+		
+		// Parse NVLinks; GPUs without NVLink report an empty list
+		nvlinks, nvlinkActive := parseNVLinks(gpu.NVLink)
 		
 		gpus[i] = GPUInfo{
-			ID:          gpu.ID,
-			Name:        gpu.ProductName,
-			Utilization: utilization,
-			MemoryUsed:  memoryUsed,
-			MemoryTotal: memoryTotal,
-			Temperature: temperature,
-			PowerUsage:  powerUsage,
-			PowerLimit:  powerLimit,
-			Processes:   processes,
+			ID:                   gpu.ID,
+			Index:                i,
+			UUID:                 gpu.UUID,
+			Name:                 gpu.ProductName,
+			Utilization:          utilization,
+			MemoryControllerUtil: memoryControllerUtil,
+			EncoderUtil:          parsePercentValue(gpu.Utilization.EncoderUtil),
+			DecoderUtil:          parsePercentValue(gpu.Utilization.DecoderUtil),
+			MemoryUsed:           memoryUsed,
+			MemoryTotal:          memoryTotal,
+			BAR1MemoryUsed:       bar1MemoryUsed,
+			BAR1MemoryTotal:      bar1MemoryTotal,
+			Temperature:          temperature,
+			FanSpeed:             fanSpeed,
+			PowerUsage:           powerUsage,
+			PowerLimit:           powerLimit,
+			SMClock:              parseClockValue(gpu.Clocks.SMClock),
+			MemClock:             parseClockValue(gpu.Clocks.MemClock),
+			MaxSMClock:           parseClockValue(gpu.MaxClocks.SMClock),
+			MaxMemClock:          parseClockValue(gpu.MaxClocks.MemClock),
+			PCIeTxThroughput:     parseThroughputValue(gpu.PCI.TxUtil),
+			PCIeRxThroughput:     parseThroughputValue(gpu.PCI.RxUtil),
+			ECC:                  parseECC(gpu.ECCErrors, gpu.RetiredPages),
+			Processes:            processes,
+			ProcessCount:         len(processes),
+			NVLinks:              nvlinks,
+			NVLinkActiveCount:    nvlinkActive,
+			NVLinkExpectedCount:  len(nvlinks),
+			MIGDevices:           parseMIGDevices(gpu.MIGDevices, gpu.Processes.ProcessInfo),
 		}
+		setThrottling(&gpus[i], gpu.ClockReasons)
 	}
 	
-	return gpus, nil
+	gpus = filterGPUs(smiOutput, gpus)
+	nodeInfo := &NodeInfo{
+		SchemaVersion:    nodeInfoSchemaVersion,
+		NodeName:         getHostname(),
+		Timestamp:        time.Now(),
+		GPUs:             gpus,
+		ExpectedGPUCount: len(gpus),
+		MissingUUIDs:     []string{},
+	}
+	if includeProcesses {
+		nodeInfo.AccountingEnabled = applyAccounting(ctx, smiOutput, gpus)
+	} else {
+		for i := range gpus {
+			gpus[i].Processes = nil
+		}
+	}
+
+	// A GPU that fell off the bus may be missing from the XML output but is
+	// still listed by nvidia-smi -L. Replayed output has nothing to compare to.
+	if smiReplaySource != nil {
+		return nodeInfo, nil
+	}
+	if listed, err := getGPUList(ctx); err != nil {
+		log.Printf("Failed to list GPUs: %v", err)
+	} else {
+		nodeInfo.MissingUUIDs = findMissingGPUs(listed, smiOutput)
+		nodeInfo.ExpectedGPUCount += len(nodeInfo.MissingUUIDs)
+		nameMIGDevices(ctx, listed, gpus)
+	}
+
+	return nodeInfo, nil
+}
+
+// attributePower distributes the GPU's power draw across its processes
+// weighted by their memory usage, a rough heuristic for chargeback
+func attributePower(processes []ProcessInfo, powerUsage uint64) {
+	var totalUsed uint64
+	for _, proc := range processes {
+		totalUsed += proc.Used
+	}
+	if totalUsed == 0 {
+		return
+	}
+	for i := range processes {
+		processes[i].PowerShareMilliwatts = uint64(float64(powerUsage) * float64(processes[i].Used) / float64(totalUsed))
+	}
+}
+
+func parsePercentValue(value string) float64 {
+	// Parse percentage value like "85 %"; "N/A" or empty values are zero
+	if !strings.HasSuffix(value, " %") {
+		return 0
+	}
+	num, _ := strconv.ParseFloat(strings.TrimSuffix(value, " %"), 64)
+	return roundPercent(num)
+}
+
+// percentPrecision is the number of decimals percentages are reported with
+const percentPrecision = 1
+
+// roundPercent rounds a percentage to percentPrecision decimals, e.g.
+// 85.33333 to 85.3
+func roundPercent(value float64) float64 {
+	scale := math.Pow10(percentPrecision)
+	return math.Round(value*scale) / scale
 }
 
 func parseMemoryValue(value string) uint64 {
@@ -406,6 +1548,43 @@ func parseMemoryValue(value string) uint64 {
 	return 0
 }
 
+// parseNVLinks converts the NVLink section of a GPU and counts the active links
+func parseNVLinks(nvlink NVLink) ([]NVLinkInfo, int) {
+	links := make([]NVLinkInfo, 0, len(nvlink.Links))
+	active := 0
+	for i, link := range nvlink.Links {
+		index, err := strconv.Atoi(link.ID)
+		if err != nil {
+			index = i
+		}
+
+		state := "inactive"
+		if strings.EqualFold(strings.TrimSpace(link.State), "active") {
+			state = "active"
+			active++
+		}
+
+		links = append(links, NVLinkInfo{
+			Index:          index,
+			State:          state,
+			CRCFlitErrors:  parseCounterValue(link.CRCFlitErrors),
+			CRCDataErrors:  parseCounterValue(link.CRCDataErrors),
+			ReplayErrors:   parseCounterValue(link.ReplayErrors),
+			RecoveryErrors: parseCounterValue(link.RecoveryErrors),
+			TxBytes:        parseDataValue(link.TxData),
+			RxBytes:        parseDataValue(link.RxData),
+			RemotePCIBusID: strings.TrimSpace(link.RemotePCIBusID),
+		})
+	}
+	return links, active
+}
+
+// parseCounterValue parses an integer counter, treating "N/A" as zero
+func parseCounterValue(value string) uint64 {
+	num, _ := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	return num
+}
+
 func parsePowerValue(value string) uint64 {
 	// Parse power value like "250.00 W" or "317.45 W"
 	value = strings.TrimSpace(value)
@@ -439,82 +1618,337 @@ func getHostname() string {
 }
 
 // Aggregator functions
+// defaultPollInterval is the default time between the starts of two poll
+// cycles
+const defaultPollInterval = 2 * time.Second
+
+// pollInterval returns the time between the starts of two poll cycles
+func (a *Aggregator) pollInterval() time.Duration {
+	if a.config.Aggregator.PollIntervalSeconds <= 0 {
+		return defaultPollInterval
+	}
+	return time.Duration(a.config.Aggregator.PollIntervalSeconds) * time.Second
+}
+
+// nodePollInterval returns the time between the polls of a node that is not
+// backed off
+func (a *Aggregator) nodePollInterval(node NodeConfig) time.Duration {
+	return max(time.Duration(node.PollIntervalSeconds)*time.Second, a.pollInterval())
+}
+
+// pollDue reports whether a node is due to be polled in the cycle starting
+// at start, and if so records the poll. Nodes with a poll_interval_seconds
+// longer than the cycle's interval skip cycles until it has passed, and so do
+// failing nodes while they are backed off.
+func (a *Aggregator) pollDue(node NodeConfig, start time.Time) bool {
+	cycle := a.pollInterval()
+	interval := a.nodePollInterval(node)
+	status, exists := a.node(node.Name)
+	if !exists {
+		return true
+	}
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.BackoffUntil = nil
+	if backoff := a.pollBackoff(status.ConsecutiveFailures, interval); backoff > interval {
+		interval = backoff
+		next := status.lastPollStart.Add(interval).UTC()
+		status.BackoffUntil = &next
+	}
+	// Allow for cycles starting slightly early, e.g. after a slow cycle
+	if interval > cycle && !status.lastPollStart.IsZero() && start.Sub(status.lastPollStart) < interval-cycle/2 {
+		return false
+	}
+	status.lastPollStart = start
+	return true
+}
+
+// pollNodes polls all nodes on every tick. The first poll is done by
+// runAggregator before the HTTP server starts.
 func (a *Aggregator) pollNodes() {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(a.pollInterval())
 	defer ticker.Stop()
 
-	for {
+	for range ticker.C {
 		a.updateNodeStatuses()
-		<-ticker.C
 	}
 }
 
 func (a *Aggregator) updateNodeStatuses() {
+	cycle := PollCycleStats{Start: time.Now().UTC()}
+	a.mutex.Lock()
+	a.currentCycle = cycle.Start
+	a.mutex.Unlock()
+
 	var wg sync.WaitGroup
+	var cycleMutex sync.Mutex
 
+	a.expireRegisteredNodes(time.Now())
 	// Process nodes in the order they appear in config
-	for _, node := range a.config.Nodes {
+	for _, node := range a.nodeConfigs() {
+		// Registered nodes are no longer polled once their heartbeats stop
+		if !a.checkRegisteredNode(node) {
+			continue
+		}
+		// Push nodes are only checked for whether they still push
+		if node.Mode == NodeModePush {
+			a.checkPushedNode(node)
+			continue
+		}
+		if !a.pollDue(node, cycle.Start) {
+			continue
+		}
 		wg.Add(1)
 		go func(node NodeConfig) {
-			defer wg.Done()
-			a.updateNodeStatus(node)
+			// The watchdog may release a stalled poll before it returns
+			release := sync.OnceFunc(wg.Done)
+			defer release()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			start := time.Now()
+			id := a.beginPoll(node.Name, cancel, release)
+			a.updateNodeStatus(ctx, node)
+			duration := time.Since(start)
+			if !a.endPoll(node.Name, id) {
+				return
+			}
+
+			online := false
+			if status, exists := a.node(node.Name); exists {
+				status.mutex.Lock()
+				status.LastFetchDurationMs = duration.Milliseconds()
+				online = status.Status == "online"
+				status.pollStats.record(duration, online)
+				status.mutex.Unlock()
+			}
+
+			cycleMutex.Lock()
+			cycle.recordNode(node.Name, duration, online)
+			cycleMutex.Unlock()
 		}(node)
 	}
 
 	wg.Wait()
+
+	cycle.End = time.Now().UTC()
+	cycle.DurationMs = cycle.End.Sub(cycle.Start).Milliseconds()
+	if duration := cycle.End.Sub(cycle.Start); duration > a.pollInterval() {
+		log.Printf("Warning: poll cycle took %v, longer than the poll interval of %v (slowest node: %s, %dms)",
+			duration, a.pollInterval(), cycle.SlowestNode, cycle.SlowestNodeLatencyMs)
+	}
+
+	a.mutex.Lock()
+	a.lastCycle = cycle
+	a.currentCycle = time.Time{}
+	a.cyclesCompleted++
+	a.mutex.Unlock()
+
+	if a.remoteWriter != nil {
+		a.remoteWriter.enqueue(a.snapshotNodes())
+	}
+	if a.alerts != nil {
+		a.alerts.evaluate(a.snapshotNodes(), time.Now().UTC())
+	}
+}
+
+// maxClockSkew returns the clock skew threshold for warnings
+func (a *Aggregator) maxClockSkew() time.Duration {
+	if a.config.Aggregator.MaxClockSkewSeconds <= 0 {
+		return defaultMaxClockSkew
+	}
+	return time.Duration(a.config.Aggregator.MaxClockSkewSeconds * float64(time.Second))
+}
+
+// pollTimeout returns the timeout of requests to a node
+func (a *Aggregator) pollTimeout(node NodeConfig) time.Duration {
+	if node.PollTimeoutSeconds > 0 {
+		return time.Duration(node.PollTimeoutSeconds) * time.Second
+	}
+	return time.Duration(a.config.Aggregator.DefaultPollTimeoutSeconds) * time.Second
 }
 
-func (a *Aggregator) updateNodeStatus(node NodeConfig) {
-	// Use custom DNS resolver if configured
-	host := node.Host
+// clientFor returns the HTTP client used for requests to a node, whose
+// timeout is the node's poll timeout
+func (a *Aggregator) clientFor(node NodeConfig) *http.Client {
+	timeout := a.pollTimeout(node)
+
+	a.clientsMutex.Lock()
+	defer a.clientsMutex.Unlock()
+
+	client, exists := a.clients[node.Name]
+	if !exists {
+		transport := a.newNodeTransport(node)
+		client = &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		}
+		if token := a.nodeAuthToken(node); token != "" {
+			client.Transport = &tokenTransport{Transport: transport, token: token}
+		}
+		a.clients[node.Name] = client
+	} else if client.Timeout != timeout {
+		client = &http.Client{
+			Timeout:   timeout,
+			Transport: client.Transport,
+		}
+		a.clients[node.Name] = client
+	}
+	return client
+}
+
+// newNodeTransport creates the transport dedicated to one node, with the
+// node's TLS settings and the pool settings of the poll transport
+func (a *Aggregator) newNodeTransport(node NodeConfig) *http.Transport {
+	transport := a.transport.Clone()
+	tlsConfig, err := nodeTLSConfig(node)
+	if err != nil {
+		log.Printf("Warning: node %s: %v", node.Name, err)
+	} else if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport
+}
+
+// removeClient drops the client of a removed node and closes its idle
+// connections
+func (a *Aggregator) removeClient(nodeName string) {
+	a.clientsMutex.Lock()
+	client, exists := a.clients[nodeName]
+	delete(a.clients, nodeName)
+	a.clientsMutex.Unlock()
+
+	if exists {
+		client.CloseIdleConnections()
+	}
+	a.closeGRPCStream(nodeName)
+}
+
+// nodeHost returns the host of a node, resolved with the custom DNS server
+// if configured
+func (a *Aggregator) nodeHost(node NodeConfig) string {
 	if a.config.DNS.Enabled && a.config.DNS.Server != "" {
 		// Try to resolve the host using custom DNS
 		resolvedIP, err := a.resolveWithCustomDNS(node.Host, a.config.DNS.Server)
 		if err == nil && resolvedIP != "" {
-			host = resolvedIP
+			return resolvedIP
 		}
 	}
-	
-	url := fmt.Sprintf("http://%s:%d/gpu-info", host, node.Port)
+	return node.Host
+}
+
+// nodeURL builds the URL of an endpoint on a node
+func (a *Aggregator) nodeURL(node NodeConfig, path string) string {
+	host := a.nodeHost(node)
+	scheme := "http"
+	if node.TLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d%s", scheme, host, node.Port, path)
+}
+
+func (a *Aggregator) updateNodeStatus(ctx context.Context, node NodeConfig) {
+	if node.Transport == NodeTransportGRPC {
+		a.updateNodeStatusGRPC(ctx, node)
+		return
+	}
+	url := a.nodeURL(node, "/gpu-info")
+	if node.DefaultFilter != "" {
+		url += "?filter=" + node.DefaultFilter
+	}
 	
 	// Create request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		a.updateNodeError(node.Name, fmt.Sprintf("Failed to create request: %v", err))
+		a.pollFailed(node.Name, "offline", ErrConnect, fmt.Sprintf("Failed to create request: %v", err))
 		return
 	}
+	// Prefer the compact encoding; agents that do not support it send JSON
+	req.Header.Set("Accept", msgpackContentType+", application/json;q=0.9")
 
 	// Make request
-	resp, err := a.client.Do(req)
+	requestStart := time.Now()
+	resp, err := a.clientFor(node).Do(req)
 	if err != nil {
-		a.updateNodeError(node.Name, fmt.Sprintf("Failed to connect: %v", err))
+		a.pollFailed(node.Name, "offline", connectErrorCode(err), fmt.Sprintf("Failed to connect: %v", err))
 		return
 	}
 	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	defer io.Copy(io.Discard, resp.Body)
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		a.updateNodeError(node.Name, fmt.Sprintf("HTTP error: %d", resp.StatusCode))
+		collectErr := fmt.Sprintf("HTTP error: %d", resp.StatusCode)
+		if body, _ := io.ReadAll(io.LimitReader(resp.Body, 512)); len(body) > 0 {
+			collectErr += ": " + errorMessage(body)
+		}
+
+		// The node answered, so check whether only GPU collection is broken
+		if err := a.probeNodeHealth(ctx, node); err != nil {
+			a.pollFailed(node.Name, "offline", ErrHTTP, fmt.Sprintf("gpu-info probe failed (%s); health probe failed (%v)", collectErr, err))
+		} else {
+			a.pollFailed(node.Name, "error", ErrHTTP, fmt.Sprintf("gpu-info probe failed (%s); health probe OK", collectErr))
+		}
 		return
 	}
 
-	// Parse response
-	var nodeInfo NodeInfo
-	err = json.NewDecoder(resp.Body).Decode(&nodeInfo)
+	// Parse response; payloads of older and newer agents are decoded on a
+	// best-effort basis
+	var nodeInfo *NodeInfo
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), msgpackContentType) {
+		nodeInfo, err = decodeNodeInfoMsgpack(resp.Body)
+	} else {
+		nodeInfo, err = decodeNodeInfo(resp.Body)
+	}
 	if err != nil {
-		a.updateNodeError(node.Name, fmt.Sprintf("Failed to parse response: %v", err))
+		a.pollFailed(node.Name, "error", ErrParse, fmt.Sprintf("Failed to parse response: %v", err))
 		return
 	}
+	a.pollSucceeded(node.Name, nodeInfo, requestStart, time.Now())
+}
+
+// recordNodeInfo records the data of a successful poll or push of a node.
+// The request start and receive times are used to estimate the node's clock
+// skew.
+func (a *Aggregator) recordNodeInfo(nodeName string, nodeInfo *NodeInfo, requestStart, received time.Time) {
+	// Node timestamps are reported in UTC whatever the node's timezone
+	nodeInfo.Timestamp = nodeInfo.Timestamp.UTC()
 
 	// Update node status
-	a.mutex.Lock()
-	if status, exists := a.nodes[node.Name]; exists {
+	if status, exists := a.node(nodeName); exists {
+		status.mutex.Lock()
+		a.logStatusTransition(nodeName, status.Status, "online", "")
+		status.rotate()
+		setNVLinkThroughput(status.prevData, nodeInfo)
 		status.Status = "online"
-		status.LastUpdate = time.Now()
-		status.Data = &nodeInfo
-		status.Error = ""
+		status.LastUpdate = received.UTC()
+		status.LastSuccess = status.LastUpdate
+		status.Data = nodeInfo
+		status.NodeError = nil
+		status.updateUtilizationEMA(nodeInfo.GPUs, a.config.Aggregator.UtilizationEMAAlpha)
+		events := processEvents(status.diff(a.config.Diff.withDefaults()))
+		a.events.add(events...)
+		for _, event := range events {
+			a.hub.publish("process_lifecycle", nodeName, event)
+		}
+		a.checkPowerLimits(&status.NodeStatus, nodeInfo.GPUs)
+		a.checkFanHealth(&status.NodeStatus, nodeInfo.GPUs)
+		a.checkThrottling(&status.NodeStatus, nodeInfo.GPUs)
+		a.checkPageRetirement(&status.NodeStatus, nodeInfo.GPUs)
+		a.reservations.annotate(nodeName, nodeInfo.GPUs)
+		a.history.record(nodeName, status.LastUpdate, nodeInfo.GPUs)
+		if !nodeInfo.Timestamp.IsZero() {
+			status.updateClockSkew(clockSkew(nodeInfo.Timestamp, requestStart, received), a.maxClockSkew())
+		}
+		status.AgentSchemaVersion = nodeInfo.SchemaVersion
+		if nodeInfo.SchemaVersion > nodeInfoSchemaVersion && status.warnedSchemaVersion != nodeInfo.SchemaVersion {
+			log.Printf("Warning: node %s reports schema version %d, newer than the supported version %d; parsing on a best-effort basis",
+				nodeName, nodeInfo.SchemaVersion, nodeInfoSchemaVersion)
+			status.warnedSchemaVersion = nodeInfo.SchemaVersion
+		}
+		status.mutex.Unlock()
 	}
-	a.mutex.Unlock()
 }
 
 func (a *Aggregator) resolveWithCustomDNS(hostname, dnsServer string) (string, error) {
@@ -542,44 +1976,325 @@ func (a *Aggregator) resolveWithCustomDNS(hostname, dnsServer string) (string, e
 	return "", fmt.Errorf("no IP address found for hostname: %s", hostname)
 }
 
-func (a *Aggregator) updateNodeError(nodeName, errorMsg string) {
-	a.mutex.Lock()
-	if status, exists := a.nodes[nodeName]; exists {
-		status.Status = "offline"
-		status.LastUpdate = time.Now()
+// logStatusTransition logs a change of a node's status
+func (a *Aggregator) logStatusTransition(nodeName, from, to, errorMsg string) {
+	if from == to {
+		return
+	}
+	event := StatusChangeEvent{Node: nodeName, From: from, To: to, Error: errorMsg}
+	a.audit.record("node_status", event)
+	a.hub.publish("status", nodeName, event)
+	if errorMsg != "" {
+		log.Printf("Node %s: %s -> %s: %s", nodeName, from, to, errorMsg)
+	} else {
+		log.Printf("Node %s: %s -> %s", nodeName, from, to)
+	}
+}
+
+// probeNodeHealth checks whether a node's /health endpoint answers
+func (a *Aggregator) probeNodeHealth(ctx context.Context, node NodeConfig) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.nodeURL(node, "/health"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.clientFor(node).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// updateNodeError records a failed poll. The status is "offline" when the
+// node is unreachable and "error" when it is reachable but GPU collection fails.
+func (a *Aggregator) updateNodeError(nodeName, statusValue string, code MonitorErrorCode, errorMsg string) {
+	if status, exists := a.node(nodeName); exists {
+		status.mutex.Lock()
+		a.logStatusTransition(nodeName, status.Status, statusValue, errorMsg)
+		status.rotate()
+		status.Status = statusValue
+		status.LastUpdate = time.Now().UTC()
 		status.Data = nil
-		status.Error = errorMsg
+		status.NodeError = &MonitorError{Code: code, Message: errorMsg}
+		status.errorCounts[code]++
+		status.mutex.Unlock()
 	}
-	a.mutex.Unlock()
+
+	// Static info may change while the node is down (e.g. a driver upgrade)
+	a.invalidateMetadata(nodeName)
 }
 
 func (a *Aggregator) nodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		a.addNodesHandler(w, r)
+		return
+	}
+
+	// Optional delta mode: only return nodes updated after the given time
+	var since time.Time
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid since parameter: %v", err))
+			return
+		}
+	}
+
+	// Optional pagination
+	query := r.URL.Query()
+	paginated := query.Has("limit") || query.Has("offset")
+	limit, offset := 0, 0
+	if paginated {
+		var err error
+		if limit, err = parseNonNegativeInt(query.Get("limit")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit parameter: %v", err))
+			return
+		}
+		if offset, err = parseNonNegativeInt(query.Get("offset")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid offset parameter: %v", err))
+			return
+		}
+	}
+
 	a.mutex.RLock()
-	// Return nodes in the order they appear in config
-	nodes := make([]*NodeStatus, 0, len(a.config.Nodes))
-	for _, nodeConfig := range a.config.Nodes {
+	// Select nodes in the order they appear in config
+	matched := make([]*nodeEntry, 0, len(a.nodeList))
+	for _, nodeConfig := range a.nodeList {
 		if nodeStatus, exists := a.nodes[nodeConfig.Name]; exists {
-			nodes = append(nodes, nodeStatus)
+			nodeStatus.mutex.RLock()
+			lastUpdate := nodeStatus.LastUpdate
+			nodeStatus.mutex.RUnlock()
+			if !since.IsZero() && !lastUpdate.After(since) {
+				continue
+			}
+			matched = append(matched, nodeStatus)
+		}
+	}
+	total := len(matched)
+	page := matched
+	if paginated {
+		page = matched[min(offset, total):]
+		if limit > 0 && limit < len(page) {
+			page = page[:limit]
 		}
 	}
+	// Copies are encoded so that concurrent polls cannot mutate them
+	// mid-encoding
+	nodes := make([]NodeStatus, len(page))
+	for i, nodeStatus := range page {
+		nodeStatus.mutex.RLock()
+		nodes[i] = nodeStatus.snapshot()
+		nodeStatus.mutex.RUnlock()
+	}
 	a.mutex.RUnlock()
 
+	lastUpdates := make([]time.Time, len(nodes))
+	for i, node := range nodes {
+		lastUpdates[i] = node.LastUpdate
+	}
+	setDataAgeHeader(w, time.Now(), lastUpdates...)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(nodes)
+	if !paginated {
+		json.NewEncoder(w).Encode(nodes)
+		return
+	}
+	json.NewEncoder(w).Encode(NodePage{
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		Nodes:  nodes,
+	})
 }
 
-func (a *Aggregator) nodeHandler(w http.ResponseWriter, r *http.Request) {
-	nodeName := r.URL.Path[len("/api/nodes/"):]
-	
+// snapshotNodes returns copies of all node statuses in config order
+func (a *Aggregator) snapshotNodes() []NodeStatus {
 	a.mutex.RLock()
-	node, exists := a.nodes[nodeName]
-	a.mutex.RUnlock()
+	defer a.mutex.RUnlock()
+
+	nodes := make([]NodeStatus, 0, len(a.nodeList))
+	for _, nodeConfig := range a.nodeList {
+		if nodeStatus, exists := a.nodes[nodeConfig.Name]; exists {
+			nodeStatus.mutex.RLock()
+			nodes = append(nodes, nodeStatus.snapshot())
+			nodeStatus.mutex.RUnlock()
+		}
+	}
+	return nodes
+}
+
+// setDataAgeHeader sets the X-Data-Age-Seconds header to the age of the
+// oldest of the given update times, so that clients can tell how stale a
+// response is. Nodes that were never polled are ignored.
+func setDataAgeHeader(w http.ResponseWriter, now time.Time, lastUpdates ...time.Time) {
+	var oldest time.Time
+	for _, lastUpdate := range lastUpdates {
+		if !lastUpdate.IsZero() && (oldest.IsZero() || lastUpdate.Before(oldest)) {
+			oldest = lastUpdate
+		}
+	}
+	if oldest.IsZero() {
+		return
+	}
+	age := max(now.Sub(oldest).Seconds(), 0)
+	w.Header().Set("X-Data-Age-Seconds", strconv.FormatFloat(age, 'f', 1, 64))
+}
+
+// NodePage is the envelope of a paginated node list. A limit of 0 means no limit.
+type NodePage struct {
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+	Nodes  []NodeStatus `json:"nodes"`
+}
+
+// parseNonNegativeInt parses an optional query parameter, treating empty as 0
+func parseNonNegativeInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	num, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if num < 0 {
+		return 0, fmt.Errorf("must not be negative: %d", num)
+	}
+	return num, nil
+}
+
+func (a *Aggregator) nodeHandler(w http.ResponseWriter, r *http.Request) {
+	nodeStatus, exists := a.node(r.PathValue("name"))
+	var node NodeStatus
+	if exists {
+		nodeStatus.mutex.RLock()
+		node = nodeStatus.snapshot()
+		nodeStatus.mutex.RUnlock()
+	}
 
 	if !exists {
-		http.Error(w, "Node not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Node not found")
 		return
 	}
 
+	setDataAgeHeader(w, time.Now(), node.LastUpdate)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(node)
+}
+
+// nodeGPUHandler returns a single GPU of a node, selected by its index in
+// the node's GPU list, its UUID or its PCI bus ID
+func (a *Aggregator) nodeGPUHandler(w http.ResponseWriter, r *http.Request) {
+	nodeStatus, exists := a.node(r.PathValue("name"))
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	var gpu GPUInfo
+	found := false
+	nodeStatus.mutex.RLock()
+	if nodeStatus.Data != nil {
+		gpu, found = findGPU(nodeStatus.Data.GPUs, r.PathValue("gpu_id"))
+	}
+	nodeStatus.mutex.RUnlock()
+
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "GPU not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gpu)
+}
+
+// findGPU looks up a GPU by index, UUID or bus ID
+func findGPU(gpus []GPUInfo, gpuID string) (GPUInfo, bool) {
+	if index, err := strconv.Atoi(gpuID); err == nil {
+		if index >= 0 && index < len(gpus) {
+			return gpus[index], true
+		}
+		return GPUInfo{}, false
+	}
+	for _, gpu := range gpus {
+		if strings.EqualFold(gpu.UUID, gpuID) || strings.EqualFold(gpu.ID, gpuID) {
+			return gpu, true
+		}
+	}
+	return GPUInfo{}, false
+}
+
+// nodeConfigs returns the current node list
+func (a *Aggregator) nodeConfigs() []NodeConfig {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.nodeList
+}
+
+// node returns the entry of a node by name
+func (a *Aggregator) node(nodeName string) (*nodeEntry, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	node, exists := a.nodes[nodeName]
+	return node, exists
+}
+
+// nodeConfig returns the configuration of a node by name
+func (a *Aggregator) nodeConfig(nodeName string) (NodeConfig, bool) {
+	node, exists := a.node(nodeName)
+	if !exists {
+		return NodeConfig{}, false
+	}
+	node.mutex.RLock()
+	defer node.mutex.RUnlock()
+	return node.NodeConfig, true
+}
+
+// healthHandler reports the aggregator's own liveness
+func (a *Aggregator) healthHandler(w http.ResponseWriter, r *http.Request) {
+	a.mutex.RLock()
+	online := 0
+	for _, node := range a.nodes {
+		node.mutex.RLock()
+		if node.Status == "online" {
+			online++
+		}
+		node.mutex.RUnlock()
+	}
+	health := map[string]interface{}{
+		"status":                "ok",
+		"uptime_seconds":        int64(time.Since(a.startTime).Seconds()),
+		"nodes_online":          online,
+		"nodes_total":           len(a.nodes),
+		"last_poll_duration_ms": a.lastCycle.DurationMs,
+	}
+	a.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// readyHandler reports whether the aggregator has completed a poll cycle
+// recently, so that a stalled poller eventually reports not-ready
+func (a *Aggregator) readyHandler(w http.ResponseWriter, r *http.Request) {
+	a.mutex.RLock()
+	lastPollEnd := a.lastCycle.End
+	a.mutex.RUnlock()
+
+	if lastPollEnd.IsZero() {
+		writeJSONError(w, http.StatusServiceUnavailable, "No poll cycle completed yet")
+		return
+	}
+	if since := time.Since(lastPollEnd); since > pollStallThreshold {
+		writeJSONError(w, http.StatusServiceUnavailable, fmt.Sprintf("No poll cycle completed for %v", since.Round(time.Second)))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
 }
\ No newline at end of file
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestAggregator creates an aggregator for the given nodes with the
+// default config. It is not persisted and does not poll.
+func newTestAggregator(t testing.TB, nodes ...NodeConfig) *Aggregator {
+	t.Helper()
+	config := &AggregatorConfig{Nodes: nodes}
+	if err := prepareAggregatorConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return newAggregator(config, "", false)
+}
+
+// testNode returns the config of a node that is never polled
+func testNode(name string) NodeConfig {
+	return NodeConfig{Name: name, Host: "127.0.0.1", Port: 8080}
+}
+
+// newTestAgent starts an agent answering with the given handler, and returns
+// the config of a node polling it
+func newTestAgent(t *testing.T, name string, handler http.HandlerFunc) NodeConfig {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(serverURL.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NodeConfig{Name: name, Host: serverURL.Hostname(), Port: port}
+}
+
+// setLastUpdate sets when a node was last updated
+func setLastUpdate(a *Aggregator, name string, lastUpdate time.Time) {
+	status, _ := a.node(name)
+	status.mutex.Lock()
+	status.LastUpdate = lastUpdate
+	status.Status = "online"
+	status.mutex.Unlock()
+}
+
+// getNodes calls nodesHandler with the given query
+func getNodes(t *testing.T, a *Aggregator, query string) (int, []NodeStatus) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	a.nodesHandler(recorder, httptest.NewRequest("GET", "/api/nodes"+query, nil))
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, nil
+	}
+	var nodes []NodeStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, nodes
+}
+
+func TestNodesHandlerSince(t *testing.T) {
+	a := newTestAggregator(t, testNode("a"), testNode("b"), testNode("c"))
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	setLastUpdate(a, "a", base.Add(-time.Minute))
+	setLastUpdate(a, "b", base.Add(time.Second))
+	setLastUpdate(a, "c", base.Add(time.Minute))
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		want  []string
+	}{
+		{"full list without since", "", http.StatusOK, []string{"a", "b", "c"}},
+		{"partial delta", "?since=" + base.Format(time.RFC3339), http.StatusOK, []string{"b", "c"}},
+		{"equal time is not newer", "?since=" + base.Add(time.Minute).Format(time.RFC3339), http.StatusOK, []string{}},
+		{"empty delta", "?since=" + base.Add(time.Hour).Format(time.RFC3339), http.StatusOK, []string{}},
+		{"time zone offset", "?since=2024-05-01T14:00:30%2B02:00", http.StatusOK, []string{"c"}},
+		{"garbage", "?since=yesterday", http.StatusBadRequest, nil},
+		{"date only", "?since=2024-05-01", http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, nodes := getNodes(t, a, test.query)
+			if code != test.code {
+				t.Fatalf("status = %d, want %d", code, test.code)
+			}
+			if test.want == nil {
+				return
+			}
+			// An empty delta is an empty list, not null
+			if nodes == nil {
+				t.Fatal("got null, want a list")
+			}
+			names := make([]string, len(nodes))
+			for i, node := range nodes {
+				names[i] = node.Name
+			}
+			if len(names) != len(test.want) {
+				t.Fatalf("nodes = %v, want %v", names, test.want)
+			}
+			for i := range names {
+				if names[i] != test.want[i] {
+					t.Fatalf("nodes = %v, want %v", names, test.want)
+				}
+			}
+		})
+	}
+}
+
+// Run with -race: polls replace the node's data and reservations update it in
+// place while handlers encode it
+func TestConcurrentPollAndServe(t *testing.T) {
+	var polls atomic.Int64
+	node := newTestAgent(t, "a", func(w http.ResponseWriter, r *http.Request) {
+		n := polls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(NodeInfo{GPUs: []GPUInfo{{
+			ID:          "00000000:01:00.0",
+			Utilization: float64(n % 100),
+			MemoryUsed:  uint64(n),
+			Processes:   []ProcessInfo{{PID: uint32(n), Name: "train", Used: uint64(n)}},
+		}}})
+	})
+	a := newTestAggregator(t, node)
+
+	handlers := []http.HandlerFunc{a.nodesHandler, a.nodeHandler, a.flatNodesHandler, a.summaryHandler, a.metricsHandler}
+	var polling, serving sync.WaitGroup
+	// Serve until the polls are done, leaving them time to run
+	var done atomic.Bool
+	for range 2 {
+		polling.Add(1)
+		go func() {
+			defer polling.Done()
+			for range 10 {
+				a.updateNodeStatus(context.Background(), node)
+			}
+		}()
+	}
+	serving.Add(1)
+	go func() {
+		defer serving.Done()
+		for !done.Load() {
+			a.reservations.reserve("r", "a", "00000000:01:00.0", "alice", time.Minute)
+			a.annotateNode("a")
+			a.reservations.release("r")
+			a.annotateNode("a")
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for _, handler := range handlers {
+		serving.Add(1)
+		go func() {
+			defer serving.Done()
+			for !done.Load() {
+				request := httptest.NewRequest("GET", "/api/nodes/a", nil)
+				request.SetPathValue("name", "a")
+				recorder := httptest.NewRecorder()
+				handler(recorder, request)
+				if recorder.Code != http.StatusOK {
+					t.Errorf("status = %d, want 200", recorder.Code)
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	polling.Wait()
+	done.Store(true)
+	serving.Wait()
+
+	if status, _ := a.node("a"); status.Status != "online" {
+		t.Errorf("node status = %q, want online", status.Status)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	a := newTestAggregator(t, testNode("a"))
+	status, _ := a.node("a")
+	status.Status = "online"
+	status.Data = &NodeInfo{
+		System: &SystemInfo{CPUCount: 8},
+		GPUs: []GPUInfo{{
+			ID:          "00000000:01:00.0",
+			Utilization: 50,
+			Processes:   []ProcessInfo{{PID: 1, Name: "train"}},
+		}},
+	}
+
+	status.mutex.RLock()
+	snapshot := status.snapshot()
+	status.mutex.RUnlock()
+
+	// Mutate the live status the way polls do
+	status.mutex.Lock()
+	status.Status = "offline"
+	status.Data.System.CPUCount = 1
+	status.Data.GPUs[0].Utilization = 100
+	status.Data.GPUs[0].Processes[0].Name = "other"
+	status.Data.GPUs = append(status.Data.GPUs, GPUInfo{ID: "00000000:02:00.0"})
+	status.mutex.Unlock()
+
+	if snapshot.Status != "online" {
+		t.Errorf("snapshot status = %q, want online", snapshot.Status)
+	}
+	if snapshot.Data == status.Data || snapshot.Data.System == status.Data.System {
+		t.Fatal("snapshot shares data with the live status")
+	}
+	if snapshot.Data.System.CPUCount != 8 {
+		t.Errorf("snapshot CPU count = %d, want 8", snapshot.Data.System.CPUCount)
+	}
+	if len(snapshot.Data.GPUs) != 1 {
+		t.Fatalf("snapshot has %d GPUs, want 1", len(snapshot.Data.GPUs))
+	}
+	if gpu := snapshot.Data.GPUs[0]; gpu.Utilization != 50 || gpu.Processes[0].Name != "train" {
+		t.Errorf("snapshot GPU = %v%% %q, want 50%% \"train\"", gpu.Utilization, gpu.Processes[0].Name)
+	}
+}
+
+// BenchmarkConcurrentPolls records the results of 100 nodes polled at once,
+// as in every poll cycle, while handlers take snapshots of all nodes. Each
+// node has its own lock, so the polls only contend with the readers.
+func BenchmarkConcurrentPolls(b *testing.B) {
+	nodes := make([]NodeConfig, 100)
+	for i := range nodes {
+		nodes[i] = testNode(fmt.Sprintf("node-%03d", i))
+	}
+	a := newTestAggregator(b, nodes...)
+
+	b.ResetTimer()
+	for range b.N {
+		var wg sync.WaitGroup
+		for i, node := range nodes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Every tenth node fails
+				if i%10 == 0 {
+					a.pollFailed(node.Name, "offline", ErrConnect, "Failed to connect: connection refused")
+					return
+				}
+				now := time.Now()
+				a.recordNodeInfo(node.Name, &NodeInfo{GPUs: []GPUInfo{{
+					ID:          "00000000:01:00.0",
+					Utilization: float64(i),
+					Processes:   []ProcessInfo{{PID: uint32(i), Name: "train"}},
+				}}}, now, now)
+			}()
+		}
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.snapshotNodes()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// countDials makes the aggregator count the connections it opens to nodes
+func countDials(a *Aggregator) *atomic.Int64 {
+	var dials atomic.Int64
+	dialer := &net.Dialer{}
+	a.transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials.Add(1)
+		return dialer.DialContext(ctx, network, address)
+	}
+	return &dials
+}
+
+// countingAgent starts an agent reporting one GPU, and counts its requests
+func countingAgent(t *testing.T, node NodeConfig) (NodeConfig, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	agent := newTestAgent(t, node.Name, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(NodeInfo{GPUs: []GPUInfo{{ID: "00000000:01:00.0"}}})
+	})
+	node.Host, node.Port = agent.Host, agent.Port
+	return node, &requests
+}
+
+func TestPollConnectionReuse(t *testing.T) {
+	node, requests := countingAgent(t, NodeConfig{Name: "a"})
+	a := newTestAggregator(t, node)
+	dials := countDials(a)
+
+	const polls = 5
+	for range polls {
+		a.updateNodeStatus(context.Background(), node)
+	}
+	if got := requests.Load(); got != polls {
+		t.Errorf("agent got %d requests, want %d", got, polls)
+	}
+	// Keep-alive connections are pooled, so every poll reuses the first one
+	if got := dials.Load(); got != 1 {
+		t.Errorf("aggregator opened %d connections for %d polls, want 1", got, polls)
+	}
+}
+
+func TestSkippedPollMakesNoRequests(t *testing.T) {
+	// Polled every other minute, so only the first of two cycles in a row
+	// polls it
+	node, requests := countingAgent(t, NodeConfig{Name: "a", PollIntervalSeconds: 120})
+	a := newTestAggregator(t, node)
+	dials := countDials(a)
+
+	a.updateNodeStatuses()
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("agent got %d requests in the first cycle, want 1", got)
+	}
+	a.updateNodeStatuses()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("agent got %d requests after a skipped poll, want 1", got)
+	}
+	if got := dials.Load(); got != 1 {
+		t.Errorf("aggregator opened %d connections, want 1", got)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// KillRequest is the body of a request to signal a GPU process
+type KillRequest struct {
+	PID    uint32 `json:"pid"`
+	Signal string `json:"signal"`
+}
+
+// KillResponse reports the outcome of a kill request
+type KillResponse struct {
+	PID    uint32 `json:"pid"`
+	Name   string `json:"name"`
+	Signal string `json:"signal"`
+	Status string `json:"status"`
+}
+
+// killProcessHandler signals a process that is currently using a GPU. Only
+// registered in server mode when -allow-management is set.
+func killProcessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req KillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if req.Signal == "" {
+		req.Signal = "SIGTERM"
+	}
+	if !isSupportedSignal(req.Signal) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported signal: %s", req.Signal))
+		return
+	}
+
+	// Only processes that are currently using a GPU may be signalled
+	gpus, err := getGPUInfoFromNvidiaSmi(r.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get GPU info: %v", err))
+		return
+	}
+	name, found := findGPUProcess(gpus, req.PID)
+	if !found {
+		log.Printf("Kill request from %s: PID %d is not a GPU process, rejected", r.RemoteAddr, req.PID)
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("PID %d is not using a GPU", req.PID))
+		return
+	}
+
+	if err := killProcess(int(req.PID), req.Signal); err != nil {
+		log.Printf("Kill request from %s: %s to PID %d (%s) failed: %v", r.RemoteAddr, req.Signal, req.PID, name, err)
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to send %s to PID %d: %v", req.Signal, req.PID, err))
+		return
+	}
+	log.Printf("Kill request from %s: %s sent to PID %d (%s)", r.RemoteAddr, req.Signal, req.PID, name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KillResponse{
+		PID:    req.PID,
+		Name:   name,
+		Signal: req.Signal,
+		Status: "sent",
+	})
+}
+
+// findGPUProcess looks up a PID in the process lists of the GPUs
+func findGPUProcess(gpus []GPUInfo, pid uint32) (string, bool) {
+	for _, gpu := range gpus {
+		for _, proc := range gpu.Processes {
+			if proc.PID == pid {
+				return proc.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// nodeProcessHandler handles /api/nodes/{name}/processes/{pid}/kill by
+// forwarding the request to the node's management endpoint. The request is
+// sent with the node's token, so it requires the admin token.
+func (a *Aggregator) nodeProcessHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAdmin(w, r) {
+		return
+	}
+	nodeName := r.PathValue("name")
+	pidStr := r.PathValue("pid")
+	pid, err := strconv.ParseUint(pidStr, 10, 32)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid PID: %s", pidStr))
+		return
+	}
+
+	node, exists := a.nodeConfig(nodeName)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	// The signal is optional in the body; the PID comes from the path
+	var req KillRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+	}
+	req.PID = uint32(pid)
+	body, _ := json.Marshal(req)
+
+	resp, err := a.clientFor(node).Post(a.nodeURL(node, "/gpu-kill-process"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, fmt.Sprintf("Failed to connect: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	log.Printf("Forwarded kill request from %s for PID %d on node %s: HTTP %d", r.RemoteAddr, pid, nodeName, resp.StatusCode)
+
+	// Relay the node's response as-is
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
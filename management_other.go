@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+func isSupportedSignal(name string) bool {
+	return name == "SIGTERM" || name == "SIGKILL" || name == "SIGUSR1"
+}
+
+// killProcess is not supported on this platform
+func killProcess(pid int, signal string) error {
+	return fmt.Errorf("sending signals is not supported on this platform")
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// supportedSignals are the signals that may be sent to GPU processes
+var supportedSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+}
+
+func isSupportedSignal(name string) bool {
+	_, ok := supportedSignals[name]
+	return ok
+}
+
+// killProcess sends the named signal to a process
+func killProcess(pid int, signal string) error {
+	sig, ok := supportedSignals[signal]
+	if !ok {
+		return fmt.Errorf("unsupported signal: %s", signal)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// metadataCacheTTL is how long the aggregator keeps a node's static GPU info
+const metadataCacheTTL = 10 * time.Minute
+
+// NodeMetadata represents the static information of a node's GPUs, which only
+// changes on hardware changes, driver upgrades or reboots
+type NodeMetadata struct {
+	NodeName      string        `json:"node_name"`
+	DriverVersion string        `json:"driver_version"`
+	CUDAVersion   string        `json:"cuda_version"`
+	GPUs          []GPUMetadata `json:"gpus"`
+	FetchedAt     time.Time     `json:"fetched_at"`
+}
+
+// GPUMetadata represents the static information of a single GPU
+type GPUMetadata struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	UUID           string `json:"uuid"`
+	Serial         string `json:"serial"`
+	VBIOSVersion   string `json:"vbios_version"`
+	PCIeGen        string `json:"pcie_gen"`
+	PCIeMaxGen     string `json:"pcie_max_gen"`
+	PCIeWidth      string `json:"pcie_width"`
+	PCIeMaxWidth   string `json:"pcie_max_width"`
+	ECCMode        string `json:"ecc_mode"`
+	ECCModePending string `json:"ecc_mode_pending"`
+	ComputeMode    string `json:"compute_mode"`
+}
+
+// metadataCacheEntry holds a cached NodeMetadata in the aggregator
+type metadataCacheEntry struct {
+	data      *NodeMetadata
+	fetchedAt time.Time
+}
+
+// getNodeMetadataFromNvidiaSmi collects the static GPU info of this node
+func getNodeMetadataFromNvidiaSmi(ctx context.Context) (*NodeMetadata, error) {
+	smiOutput, err := runNvidiaSmi(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &NodeMetadata{
+		NodeName:      getHostname(),
+		DriverVersion: smiOutput.DriverVersion,
+		CUDAVersion:   smiOutput.CUDAVersion,
+		GPUs:          make([]GPUMetadata, 0, len(smiOutput.GPUs)),
+		FetchedAt:     time.Now(),
+	}
+	for i, gpu := range smiOutput.GPUs {
+		if !isGPUSelected(i, gpu) {
+			continue
+		}
+		metadata.GPUs = append(metadata.GPUs, GPUMetadata{
+			ID:             gpu.ID,
+			Name:           gpu.ProductName,
+			UUID:           gpu.UUID,
+			Serial:         gpu.Serial,
+			VBIOSVersion:   gpu.VBIOS,
+			PCIeGen:        gpu.PCI.CurrentLinkGen,
+			PCIeMaxGen:     gpu.PCI.MaxLinkGen,
+			PCIeWidth:      gpu.PCI.CurrentLinkWidth,
+			PCIeMaxWidth:   gpu.PCI.MaxLinkWidth,
+			ECCMode:        gpu.ECCMode.Current,
+			ECCModePending: gpu.ECCMode.Pending,
+			ComputeMode:    gpu.ComputeMode,
+		})
+	}
+
+	return metadata, nil
+}
+
+func gpuMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	metadata, err := getNodeMetadataFromNvidiaSmi(r.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get GPU metadata: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
+
+// nodeMetadata returns the static info of a node, fetching it from the node
+// if the cached copy is missing or older than metadataCacheTTL
+func (a *Aggregator) nodeMetadata(node NodeConfig) (*NodeMetadata, error) {
+	a.metadataMutex.Lock()
+	entry, exists := a.metadata[node.Name]
+	a.metadataMutex.Unlock()
+	if exists && time.Since(entry.fetchedAt) < metadataCacheTTL {
+		return entry.data, nil
+	}
+
+	resp, err := a.clientFor(node).Get(a.nodeURL(node, "/gpu-metadata"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	var metadata NodeMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	a.metadataMutex.Lock()
+	a.metadata[node.Name] = &metadataCacheEntry{
+		data:      &metadata,
+		fetchedAt: time.Now(),
+	}
+	a.metadataMutex.Unlock()
+
+	return &metadata, nil
+}
+
+// invalidateMetadata drops the cached static info of a node
+func (a *Aggregator) invalidateMetadata(nodeName string) {
+	a.metadataMutex.Lock()
+	delete(a.metadata, nodeName)
+	a.metadataMutex.Unlock()
+}
+
+func (a *Aggregator) nodeMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+	nodeConfig, exists := a.nodeConfig(nodeName)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	metadata, err := a.nodeMetadata(nodeConfig)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, fmt.Sprintf("Failed to get node metadata: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// metricFamily is a named metric with its samples, serialized in the
+// Prometheus text exposition format
+type metricFamily struct {
+	Name    string
+	Help    string
+	Type    string // "gauge", "counter", "histogram"
+	Samples []metricSample
+}
+
+// metricSample is a single labelled value of a metric family
+type metricSample struct {
+	Suffix string // of the family's name, e.g. "_bucket" of histograms
+	Labels []metricLabel
+	Value  float64
+}
+
+// metricLabel is a label name/value pair
+type metricLabel struct {
+	Name  string
+	Value string
+}
+
+func (f *metricFamily) add(value float64, labels ...metricLabel) {
+	f.Samples = append(f.Samples, metricSample{Labels: labels, Value: value})
+}
+
+// addHistogram adds the _bucket, _sum and _count samples of a histogram,
+// given the cumulative count of each bucket's upper bound
+func (f *metricFamily) addHistogram(bounds []float64, counts []uint64, sum float64, count uint64, labels ...metricLabel) {
+	for i, bound := range bounds {
+		f.Samples = append(f.Samples, metricSample{Suffix: "_bucket", Labels: withLabels(labels, metricLabel{"le", formatFloat(bound)}), Value: float64(counts[i])})
+	}
+	f.Samples = append(f.Samples,
+		metricSample{Suffix: "_bucket", Labels: withLabels(labels, metricLabel{"le", "+Inf"}), Value: float64(count)},
+		metricSample{Suffix: "_sum", Labels: labels, Value: sum},
+		metricSample{Suffix: "_count", Labels: labels, Value: float64(count)})
+}
+
+// sampleName returns the name of a sample of the family
+func (f *metricFamily) sampleName(sample metricSample) string {
+	return f.Name + sample.Suffix
+}
+
+// metricTagLabels is the number of node tags exported as the fixed labels
+// tag_0, tag_1, ... of every node and GPU metric
+const metricTagLabels = 3
+
+// nodeMetricLabels returns the labels identifying a node: its name and the
+// tag labels, which are empty for tags the node does not have
+func nodeMetricLabels(node NodeConfig) []metricLabel {
+	labels := make([]metricLabel, 0, 1+metricTagLabels)
+	labels = append(labels, metricLabel{"node", node.Name})
+	for i := 0; i < metricTagLabels; i++ {
+		value := ""
+		if i < len(node.Tags) {
+			value = node.Tags[i]
+		}
+		labels = append(labels, metricLabel{fmt.Sprintf("tag_%d", i), value})
+	}
+	return labels
+}
+
+// withLabels returns a copy of labels with more labels appended
+func withLabels(labels []metricLabel, more ...metricLabel) []metricLabel {
+	return append(append(make([]metricLabel, 0, len(labels)+len(more)), labels...), more...)
+}
+
+// gpuMetricFamilies builds the node and GPU metrics of the given nodes
+func gpuMetricFamilies(nodes []NodeStatus) []*metricFamily {
+	nodeInfo := &metricFamily{Name: "node_info", Help: "Node metadata as labels; always 1.", Type: "gauge"}
+	nodeUp := &metricFamily{Name: "node_up", Help: "Whether the node was reachable and reported GPU data (1) or not (0).", Type: "gauge"}
+	utilization := &metricFamily{Name: "gpu_utilization_percent", Help: "GPU utilization in percent.", Type: "gauge"}
+	utilizationEMA := &metricFamily{Name: "gpu_utilization_ema_percent", Help: "Exponential moving average of GPU utilization in percent.", Type: "gauge"}
+	memoryControllerUtil := &metricFamily{Name: "gpu_memory_controller_utilization_percent", Help: "GPU memory controller utilization in percent.", Type: "gauge"}
+	encoderUtil := &metricFamily{Name: "gpu_encoder_utilization_percent", Help: "GPU video encoder (NVENC) utilization in percent.", Type: "gauge"}
+	decoderUtil := &metricFamily{Name: "gpu_decoder_utilization_percent", Help: "GPU video decoder (NVDEC) utilization in percent.", Type: "gauge"}
+	memoryUsed := &metricFamily{Name: "gpu_memory_used_bytes", Help: "GPU memory used in bytes.", Type: "gauge"}
+	memoryTotal := &metricFamily{Name: "gpu_memory_total_bytes", Help: "GPU memory total in bytes.", Type: "gauge"}
+	bar1MemoryUsed := &metricFamily{Name: "gpu_bar1_memory_used_bytes", Help: "GPU BAR1 memory used in bytes.", Type: "gauge"}
+	bar1MemoryTotal := &metricFamily{Name: "gpu_bar1_memory_total_bytes", Help: "GPU BAR1 memory total in bytes.", Type: "gauge"}
+	temperature := &metricFamily{Name: "gpu_temperature_celsius", Help: "GPU temperature in degrees Celsius.", Type: "gauge"}
+	powerUsage := &metricFamily{Name: "gpu_power_usage_watts", Help: "GPU power draw in watts.", Type: "gauge"}
+	powerLimit := &metricFamily{Name: "gpu_power_limit_watts", Help: "GPU power limit in watts.", Type: "gauge"}
+	throttling := &metricFamily{Name: "gpu_throttling_percent", Help: "How far the GPU's SM clock is below its maximum, in percent.", Type: "gauge"}
+	fanSpeed := &metricFamily{Name: "gpu_fan_speed_percent", Help: "GPU fan speed in percent of its maximum.", Type: "gauge"}
+	smClock := &metricFamily{Name: "gpu_sm_clock_mhz", Help: "GPU SM clock in MHz.", Type: "gauge"}
+	memClock := &metricFamily{Name: "gpu_memory_clock_mhz", Help: "GPU memory clock in MHz.", Type: "gauge"}
+	pcieTx := &metricFamily{Name: "gpu_pcie_tx_bytes_per_second", Help: "PCIe throughput sent by the GPU in bytes per second.", Type: "gauge"}
+	pcieRx := &metricFamily{Name: "gpu_pcie_rx_bytes_per_second", Help: "PCIe throughput received by the GPU in bytes per second.", Type: "gauge"}
+	eccErrors := &metricFamily{Name: "gpu_ecc_errors", Help: "ECC errors of the GPU by counter (volatile since the driver was loaded, aggregate over its lifetime) and type.", Type: "gauge"}
+	retiredPages := &metricFamily{Name: "gpu_retired_pages", Help: "Memory pages retired by the GPU by cause (single_bit or double_bit).", Type: "gauge"}
+	pendingRetirement := &metricFamily{Name: "gpu_pending_page_retirement", Help: "Whether the GPU has memory pages pending retirement (1) or not (0).", Type: "gauge"}
+	nvlinkActive := &metricFamily{Name: "gpu_nvlink_active", Help: "Whether the NVLink is active (1) or not (0).", Type: "gauge"}
+	nvlinkData := &metricFamily{Name: "gpu_nvlink_data_bytes_total", Help: "Data sent (tx) or received (rx) over the NVLink in bytes.", Type: "counter"}
+	nvlinkThroughput := &metricFamily{Name: "gpu_nvlink_throughput_bytes_per_second", Help: "Throughput sent (tx) or received (rx) over the NVLink since the previous poll in bytes per second.", Type: "gauge"}
+	nvlinkErrors := &metricFamily{Name: "gpu_nvlink_errors_total", Help: "Errors of the NVLink by type.", Type: "counter"}
+	fanHealth := &metricFamily{Name: "gpu_fan_health_status", Help: "Whether the GPU's fan is suspected to have failed (1) or not (0).", Type: "gauge"}
+	nodeErrors := &metricFamily{Name: "node_error_total", Help: "Failed polls of the node by error code.", Type: "counter"}
+	watchdogRestarts := &metricFamily{Name: "node_watchdog_restart_total", Help: "Stalled polls of the node restarted by the watchdog.", Type: "counter"}
+	pollSuccesses := &metricFamily{Name: "node_poll_success_total", Help: "Successful polls of the node.", Type: "counter"}
+	pollFailures := &metricFamily{Name: "node_poll_failure_total", Help: "Failed polls of the node.", Type: "counter"}
+	pollDuration := &metricFamily{Name: "node_poll_duration_seconds", Help: "Duration of the node's polls in seconds.", Type: "histogram"}
+	pollLatencyP95 := &metricFamily{Name: "node_poll_latency_p95_seconds", Help: "95th percentile latency of the node's recent polls in seconds.", Type: "gauge"}
+	processMemoryUsed := &metricFamily{Name: "gpu_process_memory_used_bytes", Help: "GPU memory used by a process in bytes.", Type: "gauge"}
+
+	for _, node := range nodes {
+		up := 0.0
+		if node.Status == "online" {
+			up = 1
+		}
+		nodeLabels := nodeMetricLabels(node.NodeConfig)
+		nodeInfo.add(1, metricLabel{"node", node.Name}, metricLabel{"alias", node.Alias},
+			metricLabel{"host", node.Host}, metricLabel{"tags", strings.Join(node.Tags, ",")})
+		nodeUp.add(up, nodeLabels...)
+		for code, count := range node.errorCounts {
+			nodeErrors.add(float64(count), withLabels(nodeLabels, metricLabel{"error_code", MonitorErrorCode(code).String()})...)
+		}
+		watchdogRestarts.add(float64(node.watchdogRestarts), nodeLabels...)
+		if stats := node.pollStats.stats; stats.TotalPolls > 0 {
+			pollSuccesses.add(float64(stats.SuccessfulPolls), nodeLabels...)
+			pollFailures.add(float64(stats.FailedPolls), nodeLabels...)
+			pollDuration.addHistogram(pollDurationBuckets[:], node.pollStats.durationCounts[:], node.pollStats.durationSeconds, stats.TotalPolls, nodeLabels...)
+			pollLatencyP95.add(stats.P95LatencyMs/1000, nodeLabels...)
+		}
+
+		if node.Data == nil {
+			continue
+		}
+		for _, gpu := range node.Data.GPUs {
+			labels := withLabels(nodeLabels, metricLabel{"gpu_id", gpu.ID}, metricLabel{"gpu_name", gpu.Name})
+			utilization.add(gpu.Utilization, labels...)
+			utilizationEMA.add(gpu.UtilizationEMA, labels...)
+			memoryControllerUtil.add(gpu.MemoryControllerUtil, labels...)
+			encoderUtil.add(gpu.EncoderUtil, labels...)
+			decoderUtil.add(gpu.DecoderUtil, labels...)
+			memoryUsed.add(float64(gpu.MemoryUsed), labels...)
+			memoryTotal.add(float64(gpu.MemoryTotal), labels...)
+			if gpu.BAR1MemoryTotal > 0 {
+				bar1MemoryUsed.add(float64(gpu.BAR1MemoryUsed), labels...)
+				bar1MemoryTotal.add(float64(gpu.BAR1MemoryTotal), labels...)
+			}
+			temperature.add(float64(gpu.Temperature), labels...)
+			powerUsage.add(float64(gpu.PowerUsage)/1000, labels...)
+			powerLimit.add(float64(gpu.PowerLimit)/1000, labels...)
+			if gpu.MaxSMClock > 0 {
+				throttling.add(gpu.ThrottlingPct, labels...)
+				smClock.add(float64(gpu.SMClock), labels...)
+				memClock.add(float64(gpu.MemClock), labels...)
+			}
+			if gpu.FanSpeed != nil {
+				fanSpeed.add(*gpu.FanSpeed, labels...)
+			}
+			pcieTx.add(float64(gpu.PCIeTxThroughput), labels...)
+			pcieRx.add(float64(gpu.PCIeRxThroughput), labels...)
+			if ecc := gpu.ECC; ecc != nil {
+				for _, counter := range []struct {
+					counter, errorType string
+					count              uint64
+				}{
+					{"volatile", "correctable", ecc.VolatileCorrectable},
+					{"volatile", "uncorrectable", ecc.VolatileUncorrectable},
+					{"aggregate", "correctable", ecc.AggregateCorrectable},
+					{"aggregate", "uncorrectable", ecc.AggregateUncorrectable},
+				} {
+					eccErrors.add(float64(counter.count), withLabels(labels, metricLabel{"counter", counter.counter}, metricLabel{"type", counter.errorType})...)
+				}
+				retiredPages.add(float64(ecc.RetiredPagesSingleBit), withLabels(labels, metricLabel{"cause", "single_bit"})...)
+				retiredPages.add(float64(ecc.RetiredPagesDoubleBit), withLabels(labels, metricLabel{"cause", "double_bit"})...)
+				pending := 0.0
+				if ecc.PendingRetirement {
+					pending = 1
+				}
+				pendingRetirement.add(pending, labels...)
+			}
+			for _, link := range gpu.NVLinks {
+				linkLabels := withLabels(labels, metricLabel{"link", strconv.Itoa(link.Index)})
+				active := 0.0
+				if link.State == "active" {
+					active = 1
+				}
+				nvlinkActive.add(active, linkLabels...)
+				nvlinkData.add(float64(link.TxBytes), withLabels(linkLabels, metricLabel{"direction", "tx"})...)
+				nvlinkData.add(float64(link.RxBytes), withLabels(linkLabels, metricLabel{"direction", "rx"})...)
+				nvlinkThroughput.add(float64(link.TxThroughput), withLabels(linkLabels, metricLabel{"direction", "tx"})...)
+				nvlinkThroughput.add(float64(link.RxThroughput), withLabels(linkLabels, metricLabel{"direction", "rx"})...)
+				for _, counter := range []struct {
+					errorType string
+					count     uint64
+				}{
+					{"crc_flit", link.CRCFlitErrors},
+					{"crc_data", link.CRCDataErrors},
+					{"replay", link.ReplayErrors},
+					{"recovery", link.RecoveryErrors},
+				} {
+					nvlinkErrors.add(float64(counter.count), withLabels(linkLabels, metricLabel{"type", counter.errorType})...)
+				}
+			}
+			if gpu.FanHealthStatus != FanHealthUnknown {
+				suspected := 0.0
+				if gpu.FanHealthStatus == FanHealthSuspectedFailure {
+					suspected = 1
+				}
+				fanHealth.add(suspected, labels...)
+			}
+			for _, proc := range gpu.Processes {
+				processMemoryUsed.add(float64(proc.Used), withLabels(labels,
+					metricLabel{"pid", strconv.FormatUint(uint64(proc.PID), 10)}, metricLabel{"process_name", proc.Name},
+					metricLabel{"user", proc.User}, metricLabel{"job_id", proc.JobID})...)
+			}
+		}
+	}
+
+	return []*metricFamily{nodeInfo, nodeUp, nodeErrors, watchdogRestarts, pollSuccesses, pollFailures, pollDuration, pollLatencyP95,
+		utilization, utilizationEMA, memoryControllerUtil, encoderUtil, decoderUtil, memoryUsed, memoryTotal, bar1MemoryUsed, bar1MemoryTotal, temperature, powerUsage, powerLimit, throttling,
+		fanSpeed, smClock, memClock, pcieTx, pcieRx, eccErrors, retiredPages, pendingRetirement,
+		nvlinkActive, nvlinkData, nvlinkThroughput, nvlinkErrors, fanHealth, processMemoryUsed}
+}
+
+// writePrometheusText writes metric families in the Prometheus text format
+func writePrometheusText(w io.Writer, families []*metricFamily) error {
+	for _, family := range families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family.Name, family.Help, family.Name, family.Type); err != nil {
+			return err
+		}
+		for _, sample := range family.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", family.sampleName(sample), formatLabels(sample.Labels), formatFloat(sample.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeOpenMetricsText writes metric families in the OpenMetrics text format.
+// Counter samples carry the _total suffix that the family name omits, and the
+// output ends with the mandatory # EOF line.
+func writeOpenMetricsText(w io.Writer, families []*metricFamily) error {
+	for _, family := range families {
+		name, suffix := family.Name, ""
+		if family.Type == "counter" {
+			name, suffix = strings.TrimSuffix(family.Name, "_total"), "_total"
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, family.Type, name, escapeLabelValue(family.Help)); err != nil {
+			return err
+		}
+		for _, sample := range family.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s%s%s %s\n", name, suffix, sample.Suffix, formatLabels(sample.Labels), formatFloat(sample.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// wantsOpenMetrics reports whether a scrape asked for the OpenMetrics format,
+// either with ?format=openmetrics or through the Accept header
+func wantsOpenMetrics(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "openmetrics"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+}
+
+// writeMetrics writes metric families in the format requested by the scrape
+func writeMetrics(w http.ResponseWriter, r *http.Request, families []*metricFamily) {
+	if wantsOpenMetrics(r) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		writeOpenMetricsText(w, families)
+		return
+	}
+	w.Header().Set("Content-Type", prometheusContentType)
+	writePrometheusText(w, families)
+}
+
+func (a *Aggregator) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	writeMetrics(w, r, gpuMetricFamilies(a.snapshotNodes()))
+}
+
+// formatLabels formats labels as {a="1",b="2"}
+func formatLabels(labels []metricLabel) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = label.Name + `="` + escapeLabelValue(label.Value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWantsOpenMetrics(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		want   bool
+	}{
+		{"", "", false},
+		{"", "text/plain", false},
+		{"?format=openmetrics", "", true},
+		{"?format=prometheus", "", false},
+		{"", "application/openmetrics-text; version=1.0.0,text/plain;q=0.5", true},
+		// An explicit format wins over the Accept header
+		{"?format=prometheus", "application/openmetrics-text", false},
+		{"?format=openmetrics", "text/plain", true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics"+test.query, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if got := wantsOpenMetrics(r); got != test.want {
+			t.Errorf("wantsOpenMetrics(%q, Accept %q) = %v, want %v", test.query, test.accept, got, test.want)
+		}
+	}
+}
+
+func TestMetricsHandlerFormats(t *testing.T) {
+	node, _ := countingAgent(t, NodeConfig{Name: "a"})
+	a := newTestAggregator(t, node)
+	a.updateNodeStatuses()
+
+	tests := []struct {
+		name        string
+		query       string
+		accept      string
+		contentType string
+		openMetrics bool
+	}{
+		{"default", "", "", prometheusContentType, false},
+		{"format parameter", "?format=openmetrics", "", openMetricsContentType, true},
+		{"accept header", "", "application/openmetrics-text; version=1.0.0", openMetricsContentType, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics"+test.query, nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			recorder := httptest.NewRecorder()
+			a.metricsHandler(recorder, r)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", recorder.Code)
+			}
+			if got := recorder.Header().Get("Content-Type"); got != test.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, test.contentType)
+			}
+
+			body := recorder.Body.String()
+			if !strings.Contains(body, "gpu_utilization_percent{") {
+				t.Error("no GPU samples")
+			}
+			eofs := strings.Count(body, "# EOF")
+			if !test.openMetrics {
+				if eofs != 0 {
+					t.Error("Prometheus text contains # EOF")
+				}
+				if !strings.Contains(body, "# TYPE node_poll_success_total counter\n") {
+					t.Error("counter family is not named with _total")
+				}
+				return
+			}
+			if eofs != 1 || !strings.HasSuffix(body, "\n# EOF\n") {
+				t.Errorf("OpenMetrics text does not end with a single # EOF line: ...%q", body[max(0, len(body)-40):])
+			}
+			// Counter families are named without _total, their samples with it
+			if !strings.Contains(body, "# TYPE node_poll_success counter\n") || !strings.Contains(body, "\nnode_poll_success_total{") {
+				t.Error("counter is not named by the OpenMetrics conventions")
+			}
+			if !strings.Contains(body, "\nnode_poll_duration_seconds_bucket{") || !strings.Contains(body, "\nnode_poll_duration_seconds_count{") {
+				t.Error("histogram samples are missing")
+			}
+		})
+	}
+}
+
+func TestWriteMetricsCounter(t *testing.T) {
+	counter := &metricFamily{Name: "node_polls_total", Help: "Polls of the node.", Type: "counter"}
+	counter.add(3, metricLabel{"node", "a"})
+	families := []*metricFamily{counter}
+
+	var prometheus strings.Builder
+	if err := writePrometheusText(&prometheus, families); err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP node_polls_total Polls of the node.\n# TYPE node_polls_total counter\nnode_polls_total{node=\"a\"} 3\n"
+	if prometheus.String() != want {
+		t.Errorf("Prometheus text:\n%s\nwant:\n%s", prometheus.String(), want)
+	}
+
+	// Counter families are named without _total, their samples with it
+	var openMetrics strings.Builder
+	if err := writeOpenMetricsText(&openMetrics, families); err != nil {
+		t.Fatal(err)
+	}
+	want = "# TYPE node_polls counter\n# HELP node_polls Polls of the node.\nnode_polls_total{node=\"a\"} 3\n# EOF\n"
+	if openMetrics.String() != want {
+		t.Errorf("OpenMetrics text:\n%s\nwant:\n%s", openMetrics.String(), want)
+	}
+}
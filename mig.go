@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+// MIGDevices represents the MIG devices of a GPU in MIG mode in the
+// nvidia-smi XML output; GPUs without MIG report "None"
+type MIGDevices struct {
+	Devices []MIGDevice `xml:"mig_device"`
+}
+
+// MIGDevice represents a single MIG device, a GPU instance and one of its
+// compute instances
+type MIGDevice struct {
+	Index               string `xml:"index"`
+	GPUInstanceID       string `xml:"gpu_instance_id"`
+	ComputeInstanceID   string `xml:"compute_instance_id"`
+	MultiprocessorCount string `xml:"device_attributes>shared>multiprocessor_count"`
+	FBMemory            Memory `xml:"fb_memory_usage"`
+
+	// Not part of nvidia-smi -q; set by the NVML collector and otherwise
+	// looked up in nvidia-smi -L
+	UUID    string `xml:"-"`
+	Profile string `xml:"-"`
+}
+
+// MIGDeviceInfo represents the occupancy of a MIG device. Neither nvidia-smi
+// nor NVML report the utilization of MIG devices, so it is left out.
+type MIGDeviceInfo struct {
+	Index               int    `json:"index"`
+	UUID                string `json:"uuid"`    // empty if unknown
+	Profile             string `json:"profile"` // e.g. "1g.10gb", empty if unknown
+	GPUInstanceID       int    `json:"gpu_instance_id"`
+	ComputeInstanceID   int    `json:"compute_instance_id"`
+	MultiprocessorCount int    `json:"multiprocessor_count"`
+	MemoryUsed          uint64 `json:"memory_used"`
+	MemoryTotal         uint64 `json:"memory_total"`
+	ProcessCount        int    `json:"process_count"`
+}
+
+// migInstance identifies the MIG device a process runs on
+type migInstance struct {
+	gpuInstanceID, computeInstanceID string
+}
+
+// parseMIGDevices converts the MIG devices of a GPU, counting the reported
+// processes on each. GPUs without MIG give nil.
+func parseMIGDevices(devices MIGDevices, processes []Process) []MIGDeviceInfo {
+	if len(devices.Devices) == 0 {
+		return nil
+	}
+	counts := make(map[migInstance]int)
+	for _, proc := range processes {
+		// Counted like the processes reported in GPUInfo
+		if parseMemoryValue(proc.UsedMemory) > 0 && !isProcessExcluded(proc.ProcessName) {
+			counts[migInstance{proc.GPUInstanceID, proc.ComputeInstanceID}]++
+		}
+	}
+
+	infos := make([]MIGDeviceInfo, len(devices.Devices))
+	for i, device := range devices.Devices {
+		index, _ := strconv.Atoi(device.Index)
+		gpuInstanceID, _ := strconv.Atoi(device.GPUInstanceID)
+		computeInstanceID, _ := strconv.Atoi(device.ComputeInstanceID)
+		multiprocessors, _ := strconv.Atoi(device.MultiprocessorCount)
+		infos[i] = MIGDeviceInfo{
+			Index:               index,
+			UUID:                device.UUID,
+			Profile:             device.Profile,
+			GPUInstanceID:       gpuInstanceID,
+			ComputeInstanceID:   computeInstanceID,
+			MultiprocessorCount: multiprocessors,
+			MemoryUsed:          parseMemoryValue(device.FBMemory.Used),
+			MemoryTotal:         parseMemoryValue(device.FBMemory.Total),
+			ProcessCount:        counts[migInstance{device.GPUInstanceID, device.ComputeInstanceID}],
+		}
+	}
+	return infos
+}
+
+// nameMIGDevices fills in the profiles and UUIDs of MIG devices from
+// nvidia-smi -L. The listing is cached, so it is refreshed once if a MIG
+// device is missing from it, e.g. after the GPU was repartitioned.
+func nameMIGDevices(ctx context.Context, listed []listedGPU, gpus []GPUInfo) {
+	refreshed := false
+	for i := range gpus {
+		for j := range gpus[i].MIGDevices {
+			device := &gpus[i].MIGDevices[j]
+			if device.Profile != "" {
+				continue
+			}
+			mig, found := findListedMIGDevice(listed, gpus[i].UUID, device.Index)
+			if !found && !refreshed {
+				refreshed = true
+				invalidateGPUList()
+				var err error
+				if listed, err = getGPUList(ctx); err != nil {
+					log.Printf("Failed to list MIG devices: %v", err)
+					return
+				}
+				mig, found = findListedMIGDevice(listed, gpus[i].UUID, device.Index)
+			}
+			if found {
+				device.Profile = mig.Profile
+				device.UUID = mig.UUID
+			}
+		}
+	}
+}
+
+// findListedMIGDevice returns a MIG device of a GPU listed by nvidia-smi -L
+func findListedMIGDevice(listed []listedGPU, gpuUUID string, index int) (listedMIGDevice, bool) {
+	for _, gpu := range listed {
+		if gpu.UUID != gpuUUID {
+			continue
+		}
+		for _, mig := range gpu.MIGDevices {
+			if mig.Index == index {
+				return mig, true
+			}
+		}
+	}
+	return listedMIGDevice{}, false
+}
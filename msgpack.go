@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// msgpackContentType is the media type of MessagePack encoded /gpu-info
+// responses, a compact alternative to JSON for the node to aggregator hop
+const msgpackContentType = "application/msgpack"
+
+// wantsMsgpack reports whether the client asked for a MessagePack response
+func wantsMsgpack(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), msgpackContentType)
+}
+
+// encodeMsgpack encodes a value as MessagePack. Structs are encoded as maps
+// keyed by their JSON field names, following the same omitempty and "-" rules
+// as encoding/json, so that the payload mirrors the JSON one field for field.
+// Times are encoded as RFC 3339 strings.
+func encodeMsgpack(v interface{}) []byte {
+	return appendMsgpack(nil, reflect.ValueOf(v))
+}
+
+func appendMsgpack(buf []byte, v reflect.Value) []byte {
+	if !v.IsValid() {
+		return append(buf, 0xc0)
+	}
+	if v.Type() == timeType {
+		return appendMsgpackString(buf, v.Interface().(time.Time).Format(time.RFC3339Nano))
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, 0xc0)
+		}
+		return appendMsgpack(buf, v.Elem())
+
+	case reflect.Struct:
+		var names []string
+		var values []reflect.Value
+		collectMsgpackFields(v, &names, &values)
+		buf = appendMsgpackHeader(buf, 0x80, 0xde, len(names))
+		for i, name := range names {
+			buf = appendMsgpackString(buf, name)
+			buf = appendMsgpack(buf, values[i])
+		}
+		return buf
+
+	case reflect.Map:
+		if v.IsNil() {
+			return append(buf, 0xc0)
+		}
+		buf = appendMsgpackHeader(buf, 0x80, 0xde, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			buf = appendMsgpackString(buf, fmt.Sprint(iter.Key().Interface()))
+			buf = appendMsgpack(buf, iter.Value())
+		}
+		return buf
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return append(buf, 0xc0)
+		}
+		buf = appendMsgpackHeader(buf, 0x90, 0xdc, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			buf = appendMsgpack(buf, v.Index(i))
+		}
+		return buf
+
+	case reflect.String:
+		return appendMsgpackString(buf, v.String())
+
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(buf, v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendMsgpackUint(buf, v.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		// Whole numbers such as most percentages are smaller as integers
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return appendMsgpackInt(buf, int64(f))
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+
+	return append(buf, 0xc0)
+}
+
+// collectMsgpackFields lists the fields of a struct that encoding/json would
+// encode, flattening embedded structs
+func collectMsgpackFields(v reflect.Value, names *[]string, values *[]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			collectMsgpackFields(v.Field(i), names, values)
+			continue
+		}
+		if options == "omitempty" && v.Field(i).IsZero() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*names = append(*names, name)
+		*values = append(*values, v.Field(i))
+	}
+}
+
+// appendMsgpackHeader appends an array or map header, using the fix format
+// for up to 15 elements and the 16 or 32-bit format otherwise
+func appendMsgpackHeader(buf []byte, fixPrefix, prefix16 byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fixPrefix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, prefix16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, prefix16+1), uint32(n))
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgpackUint(buf, uint64(n))
+	case n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+}
+
+func appendMsgpackUint(buf []byte, n uint64) []byte {
+	switch {
+	case n <= 0x7f:
+		return append(buf, byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xcf), n)
+}
+
+// decodeMsgpack decodes a MessagePack document into the generic values
+// encoding/json produces when decoding into an interface{}: maps with string
+// keys, slices, strings, float64 numbers, bools and nil
+func decodeMsgpack(r io.Reader) (interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &msgpackDecoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return value, nil
+}
+
+// msgpackMaxDepth bounds the nesting of decoded documents
+const msgpackMaxDepth = 32
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next consumes n bytes
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of the given size
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, fmt.Errorf("msgpack: nesting deeper than %d", msgpackMaxDepth)
+	}
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return float64(c), nil
+	case c >= 0xe0:
+		return float64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.arrayValue(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.stringValue(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		return float64(n), err
+	case 0xd0:
+		n, err := d.uint(1)
+		return float64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return float64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return float64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return float64(int64(n)), err
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.stringValue(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		// Binary data is treated like a string
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.stringValue(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayValue(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(int(n), depth)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+func (d *msgpackDecoder) stringValue(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) arrayValue(n int, depth int) (interface{}, error) {
+	// Every element takes at least one byte
+	if n > len(d.data)-d.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, value)
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) mapValue(n int, depth int) (interface{}, error) {
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	obj := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key of type %T", key)
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = value
+	}
+	return obj, nil
+}
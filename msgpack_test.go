@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNodeInfo returns node info that uses every kind of field, with enough
+// GPUs and long enough strings for the wider MessagePack headers
+func testNodeInfo() *NodeInfo {
+	fanSpeed := 41.5
+	info := &NodeInfo{
+		SchemaVersion:     nodeInfoSchemaVersion,
+		NodeName:          "gpu-01",
+		Timestamp:         time.Date(2024, 5, 1, 12, 30, 15, 123456789, time.UTC),
+		System:            &SystemInfo{CPUCount: 64, CPUUtilization: 12.5, LoadAvg1: 3.25, MemoryTotal: 512 << 30, MemoryUsed: 100 << 30},
+		AccountingEnabled: true,
+		ExpectedGPUCount:  20,
+		MissingUUIDs:      []string{"GPU-ffffffff-1c2d-4e5f-8a9b-0c1d2e3f4a5b"},
+	}
+	for i := range 20 {
+		info.GPUs = append(info.GPUs, GPUInfo{
+			ID:              fmt.Sprintf("00000000:%02X:00.0", i),
+			Index:           i,
+			UUID:            fmt.Sprintf("GPU-%08x-1c2d-4e5f-8a9b-0c1d2e3f4a5b", i),
+			Name:            "NVIDIA A100-SXM4-80GB",
+			Utilization:     85.3,
+			MemoryUsed:      74694262784,
+			MemoryTotal:     85899345920,
+			Temperature:     64,
+			FanSpeed:        &fanSpeed,
+			PowerUsage:      312450,
+			PowerLimit:      400000,
+			ThrottleReasons: []string{"HwThermalSlowdown"},
+			Throttled:       true,
+			ECC:             &ECCInfo{VolatileCorrectable: 12, AggregateCorrectable: 1 << 40, PendingRetirement: true},
+			Processes: []ProcessInfo{{
+				PID:  4194304,
+				Name: "python " + strings.Repeat("--flag ", 50),
+				Used: 70656 << 20,
+				User: "alice",
+			}},
+			ProcessCount: 1,
+			NVLinks:      []NVLinkInfo{{Index: 0, State: "active", ReplayErrors: 14}},
+			MIGDevices:   []MIGDeviceInfo{{Index: 0, GPUInstanceID: 1, MemoryTotal: 40192 << 20}},
+		})
+	}
+	return info
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	info := testNodeInfo()
+	encoded := encodeMsgpack(info)
+	decoded, err := decodeNodeInfoMsgpack(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The payload mirrors the JSON one, so both decode to the same info
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := decodeNodeInfo(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, fromJSON) {
+		t.Errorf("MessagePack round trip differs from JSON:\n%+v\n%+v", decoded.GPUs[0], fromJSON.GPUs[0])
+	}
+	if !decoded.Timestamp.Equal(info.Timestamp) {
+		t.Errorf("timestamp = %v, want %v", decoded.Timestamp, info.Timestamp)
+	}
+	if len(encoded) >= len(data) {
+		t.Errorf("MessagePack payload is %d bytes, JSON %d", len(encoded), len(data))
+	}
+}
+
+func TestMsgpackValues(t *testing.T) {
+	tests := []struct {
+		value any
+		want  any // as decoded into an interface{} by encoding/json
+	}{
+		{nil, nil},
+		{true, true},
+		{false, false},
+		{0, 0.0},
+		{127, 127.0},
+		{128, 128.0},
+		{65535, 65535.0},
+		{65536, 65536.0},
+		{uint64(1) << 40, float64(1 << 40)},
+		{-1, -1.0},
+		{-32, -32.0},
+		{-33, -33.0},
+		{-129, -129.0},
+		{-32769, -32769.0},
+		{int64(math.MinInt32) - 1, float64(math.MinInt32) - 1},
+		{85.3, 85.3},
+		{-0.5, -0.5},
+		{100.0, 100.0},
+		{"", ""},
+		{strings.Repeat("a", 31), strings.Repeat("a", 31)},
+		{strings.Repeat("a", 32), strings.Repeat("a", 32)},
+		{strings.Repeat("a", 256), strings.Repeat("a", 256)},
+		{strings.Repeat("a", 65536), strings.Repeat("a", 65536)},
+		{[]int{}, []any{}},
+		{[]string(nil), nil},
+		{map[string]int{"a": 1}, map[string]any{"a": 1.0}},
+	}
+	for _, test := range tests {
+		got, err := decodeMsgpack(bytes.NewReader(encodeMsgpack(test.value)))
+		if err != nil {
+			t.Errorf("%.40v: %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%.40v decoded as %.40v, want %.40v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestMsgpackMalformed(t *testing.T) {
+	encoded := encodeMsgpack(testNodeInfo())
+	// Every truncation is an error rather than a panic or partial info
+	for n := 0; n < len(encoded); n += 7 {
+		if _, err := decodeNodeInfoMsgpack(bytes.NewReader(encoded[:n])); err == nil {
+			t.Fatalf("no error for %d of %d bytes", n, len(encoded))
+		}
+	}
+
+	tests := map[string][]byte{
+		"trailing bytes":   append(encodeMsgpack(1), 0x01),
+		"unsupported type": {0xc1},
+		"integer map key":  {0x81, 0x01, 0x01},
+		"huge array":       {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"deep nesting":     bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2),
+	}
+	for name, data := range tests {
+		if _, err := decodeMsgpack(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestGPUInfoHandlerMsgpack(t *testing.T) {
+	useMockNvidiaSmi(t, "normal_4gpu.xml")
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/gpu-info", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		gpuInfoHandler(recorder, r)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Accept %q: status = %d: %s", accept, recorder.Code, recorder.Body)
+		}
+		return recorder
+	}
+
+	// JSON stays the default for browsers and curl
+	plain := get("")
+	if contentType := plain.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("default Content-Type = %q", contentType)
+	}
+	fromJSON, err := decodeNodeInfo(plain.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As sent by the aggregator
+	compact := get(msgpackContentType + ", application/json;q=0.9")
+	if contentType := compact.Header().Get("Content-Type"); contentType != msgpackContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, msgpackContentType)
+	}
+	fromMsgpack, err := decodeNodeInfoMsgpack(compact.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromMsgpack.GPUs, fromJSON.GPUs) {
+		t.Error("GPUs differ between the MessagePack and JSON responses")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// MonitorErrorCode classifies why polling a node failed
+type MonitorErrorCode int
+
+const (
+	ErrConnect MonitorErrorCode = iota // the node could not be reached
+	ErrHTTP                            // the node answered with an HTTP or gRPC error
+	ErrParse                           // the node's response could not be decoded
+	ErrTimeout                         // the node did not answer in time
+
+	numMonitorErrorCodes = iota
+)
+
+var monitorErrorCodeNames = [numMonitorErrorCodes]string{
+	ErrConnect: "connect",
+	ErrHTTP:    "http",
+	ErrParse:   "parse",
+	ErrTimeout: "timeout",
+}
+
+func (c MonitorErrorCode) String() string {
+	if c < 0 || int(c) >= len(monitorErrorCodeNames) {
+		return fmt.Sprintf("MonitorErrorCode(%d)", int(c))
+	}
+	return monitorErrorCodeNames[c]
+}
+
+// MarshalJSON encodes the code by its name, e.g. "parse"
+func (c MonitorErrorCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON decodes a code by its name. Unknown names, e.g. from a newer
+// peer aggregator, are an error.
+func (c *MonitorErrorCode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for code, codeName := range monitorErrorCodeNames {
+		if codeName == name {
+			*c = MonitorErrorCode(code)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown error code %q", name)
+}
+
+// MonitorError describes why the last poll of a node failed
+type MonitorError struct {
+	Code    MonitorErrorCode `json:"code"`
+	Message string           `json:"message"`
+}
+
+func (e *MonitorError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// UnmarshalJSON also accepts the plain error string reported by older peer
+// aggregators, classified as a connection error
+func (e *MonitorError) UnmarshalJSON(data []byte) error {
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		*e = MonitorError{Code: ErrConnect, Message: message}
+		return nil
+	}
+	type plain MonitorError
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// connectErrorCode classifies a failed request as a timeout or a connection
+// error
+func connectErrorCode(err error) MonitorErrorCode {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return ErrConnect
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// useMockNvidiaSmi puts testdata/nvidia-smi first in PATH, answering with the
+// given fixture
+func useMockNvidiaSmi(t *testing.T, fixture string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the mock nvidia-smi is a shell script")
+	}
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("NVIDIA_SMI_FIXTURE", fixture)
+}
+
+func TestGetGPUInfoFromNvidiaSmi(t *testing.T) {
+	const mib = 1024 * 1024
+
+	tests := []struct {
+		fixture string
+		gpus    int
+		check   func(t *testing.T, gpus []GPUInfo)
+	}{
+		{
+			fixture: "normal_4gpu.xml",
+			gpus:    4,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				gpu := gpus[0]
+				if gpu.ID != "00000000:07:00.0" || gpu.UUID != "GPU-00000000-1c2d-4e5f-8a9b-0c1d2e3f4a5b" || gpu.Name != "NVIDIA A100-SXM4-80GB" {
+					t.Errorf("identity = %q %q %q", gpu.ID, gpu.UUID, gpu.Name)
+				}
+				if gpu.Utilization != 97 || gpu.Temperature != 64 {
+					t.Errorf("utilization, temperature = %v, %v; want 97, 64", gpu.Utilization, gpu.Temperature)
+				}
+				if gpu.MemoryTotal != 81920*mib || gpu.PowerUsage != 312450 || gpu.PowerLimit != 400000 {
+					t.Errorf("memory total, power = %v, %v/%v", gpu.MemoryTotal, gpu.PowerUsage, gpu.PowerLimit)
+				}
+				if !gpu.Throttled {
+					t.Error("GPU 0 is not throttled")
+				}
+				for i, gpu := range gpus {
+					if gpu.Index != i {
+						t.Errorf("GPU %d has index %d", i, gpu.Index)
+					}
+					if gpu.MIGDevices != nil {
+						t.Errorf("GPU %d has MIG devices", i)
+					}
+					// GPUs without NVLink report an empty list
+					if gpu.NVLinks == nil || len(gpu.NVLinks) != 0 || gpu.NVLinkExpectedCount != 0 {
+						t.Errorf("GPU %d has NVLinks %v", i, gpu.NVLinks)
+					}
+				}
+			},
+		},
+		{
+			fixture: "ecc_errors.xml",
+			gpus:    1,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				want := ECCInfo{
+					VolatileCorrectable:    12,
+					VolatileUncorrectable:  2,
+					AggregateCorrectable:   151,
+					AggregateUncorrectable: 2,
+					RetiredPagesDoubleBit:  2,
+					PendingRetirement:      true,
+				}
+				if ecc := gpus[0].ECC; ecc == nil || *ecc != want {
+					t.Errorf("ECC = %+v, want %+v", ecc, want)
+				}
+			},
+		},
+		{
+			fixture: "mig.xml",
+			gpus:    1,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				devices := gpus[0].MIGDevices
+				if len(devices) != 2 {
+					t.Fatalf("got %d MIG devices, want 2", len(devices))
+				}
+				if devices[0].GPUInstanceID != 1 || devices[0].MemoryUsed != 20480*mib || devices[0].ProcessCount != 1 {
+					t.Errorf("MIG device 0 = %+v", devices[0])
+				}
+				if devices[1].GPUInstanceID != 2 || devices[1].MemoryUsed != 0 || devices[1].ProcessCount != 0 {
+					t.Errorf("MIG device 1 = %+v", devices[1])
+				}
+				// The GPU's utilization is N/A in MIG mode
+				if gpus[0].Utilization != 0 {
+					t.Errorf("utilization = %v, want 0", gpus[0].Utilization)
+				}
+			},
+		},
+		{
+			fixture: "nvlink.xml",
+			gpus:    1,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				gpu := gpus[0]
+				if gpu.NVLinkActiveCount != 11 || gpu.NVLinkExpectedCount != 12 || len(gpu.NVLinks) != 12 {
+					t.Fatalf("%d of %d NVLinks active, %d links; want 11 of 12", gpu.NVLinkActiveCount, gpu.NVLinkExpectedCount, len(gpu.NVLinks))
+				}
+				for i, link := range gpu.NVLinks {
+					if link.Index != i {
+						t.Errorf("link %d has index %d", i, link.Index)
+					}
+				}
+				if link := gpu.NVLinks[2]; link.State != "active" || link.CRCFlitErrors != 3 || link.ReplayErrors != 14 || link.CRCDataErrors != 0 {
+					t.Errorf("link 2 = %+v, want active with 3 CRC flit and 14 replay errors", link)
+				}
+				// The counters of the down link are N/A
+				if link := gpu.NVLinks[7]; link != (NVLinkInfo{Index: 7, State: "inactive"}) {
+					t.Errorf("link 7 = %+v, want inactive without errors", link)
+				}
+			},
+		},
+		{
+			fixture: "power_na.xml",
+			gpus:    1,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				gpu := gpus[0]
+				if gpu.PowerUsage != 0 || gpu.PowerLimit != 0 {
+					t.Errorf("power = %v/%v, want 0/0", gpu.PowerUsage, gpu.PowerLimit)
+				}
+				if len(gpu.Processes) != 1 || gpu.Processes[0].PowerShareMilliwatts != 0 {
+					t.Errorf("processes = %+v, want one without a power share", gpu.Processes)
+				}
+			},
+		},
+		{
+			fixture: "no_processes.xml",
+			gpus:    1,
+			check: func(t *testing.T, gpus []GPUInfo) {
+				gpu := gpus[0]
+				if gpu.Processes == nil || len(gpu.Processes) != 0 || gpu.ProcessCount != 0 {
+					t.Errorf("processes = %#v (%d), want an empty list", gpu.Processes, gpu.ProcessCount)
+				}
+				if gpu.MemoryUsed != 0 || gpu.Utilization != 0 {
+					t.Errorf("memory used, utilization = %v, %v; want 0, 0", gpu.MemoryUsed, gpu.Utilization)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			useMockNvidiaSmi(t, test.fixture)
+			gpus, err := getGPUInfoFromNvidiaSmi(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gpus) != test.gpus {
+				t.Fatalf("got %d GPUs, want %d", len(gpus), test.gpus)
+			}
+			test.check(t, gpus)
+		})
+	}
+}
+
+func TestParseNVLinks(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "nvlink.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var smiOutput SMIOutput
+	if err := xml.Unmarshal(data, &smiOutput); err != nil {
+		t.Fatal(err)
+	}
+	if len(smiOutput.GPUs) != 1 {
+		t.Fatalf("got %d GPUs, want 1", len(smiOutput.GPUs))
+	}
+
+	links, active := parseNVLinks(smiOutput.GPUs[0].NVLink)
+	if active != 11 || len(links) != 12 {
+		t.Fatalf("%d of %d NVLinks active, want 11 of 12", active, len(links))
+	}
+	for i, link := range links {
+		if link.Index != i {
+			t.Errorf("link %d has index %d", i, link.Index)
+		}
+	}
+	if link := links[2]; link.State != "active" || link.CRCFlitErrors != 3 || link.ReplayErrors != 14 || link.CRCDataErrors != 0 {
+		t.Errorf("link 2 = %+v, want active with 3 CRC flit and 14 replay errors", link)
+	}
+	// The counters of the down link are N/A
+	if link := links[7]; link != (NVLinkInfo{Index: 7, State: "inactive"}) {
+		t.Errorf("link 7 = %+v, want inactive without errors", link)
+	}
+
+	// GPUs without NVLink report an empty list
+	links, active = parseNVLinks(NVLink{})
+	if links == nil || len(links) != 0 || active != 0 {
+		t.Errorf("no NVLink section: %v, %d active", links, active)
+	}
+}
+
+func TestParsePercentValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		json  string
+	}{
+		{"85.33333 %", 85.3, "85.3"},
+		{"85.35 %", 85.4, "85.4"},
+		{"99.96 %", 100, "100"},
+		{"100 %", 100, "100"},
+		{"0 %", 0, "0"},
+		{"0.04 %", 0, "0"},
+		{"N/A", 0, "0"},
+		{"", 0, "0"},
+	}
+	for _, test := range tests {
+		got := parsePercentValue(test.value)
+		if got != test.want {
+			t.Errorf("parsePercentValue(%q) = %v, want %v", test.value, got, test.want)
+		}
+		if encoded, _ := json.Marshal(got); string(encoded) != test.json {
+			t.Errorf("parsePercentValue(%q) is encoded as %s, want %s", test.value, encoded, test.json)
+		}
+	}
+}
+
+func TestUtilizationRounding(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "no_processes.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := strings.Replace(string(fixture), "<gpu_util>0 %</gpu_util>", "<gpu_util>85.33333 %</gpu_util>", 1)
+	path := filepath.Join(t.TempDir(), "fractional.xml")
+	if err := os.WriteFile(path, []byte(xml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useMockNvidiaSmi(t, path)
+
+	gpus, err := getGPUInfoFromNvidiaSmi(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gpus[0].Utilization; got != 85.3 {
+		t.Errorf("utilization = %v, want 85.3", got)
+	}
+}
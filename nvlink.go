@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// nvlinkGPUPattern matches the heading of a GPU in the output of
+	// nvidia-smi nvlink, e.g. "GPU 0: NVIDIA A100-SXM4-80GB (UUID: ...)"
+	nvlinkGPUPattern = regexp.MustCompile(`^GPU (\d+):`)
+	// nvlinkDataPattern matches a data counter of nvidia-smi nvlink -gt d,
+	// e.g. "Link 0: Data Tx: 1024 KiB"
+	nvlinkDataPattern = regexp.MustCompile(`^Link (\d+): Data (Tx|Rx): (\d+ KiB)`)
+	// nvlinkRemotePattern matches the remote device of nvidia-smi nvlink -R,
+	// e.g. "Link 0: Remote Device 00000000:0F:00.0: Link 2"
+	nvlinkRemotePattern = regexp.MustCompile(`^Link (\d+):.*?([0-9A-Fa-f]+:[0-9A-Fa-f]{2}:[0-9A-Fa-f]{2}\.[0-9A-Fa-f])`)
+)
+
+// addNVLinkCounters adds the data counters and remote devices of the
+// NVLinks to the output of nvidia-smi -q, which only has their state and
+// errors. nvidia-smi nvlink is only run on GPUs with NVLinks.
+func addNVLinkCounters(ctx context.Context, smiOutput *SMIOutput) {
+	hasLinks := false
+	for _, gpu := range smiOutput.GPUs {
+		hasLinks = hasLinks || len(gpu.NVLink.Links) > 0
+	}
+	if !hasLinks {
+		return
+	}
+
+	if output, err := runNvidiaSmiCommand(ctx, "nvlink", "-gt", "d"); err != nil {
+		log.Printf("Failed to get NVLink data counters: %v", err)
+	} else {
+		parseNVLinkOutput(smiOutput, output, nvlinkDataPattern, func(link *NVLinkLink, match []string) {
+			if match[2] == "Tx" {
+				link.TxData = match[3]
+			} else {
+				link.RxData = match[3]
+			}
+		})
+	}
+	if output, err := runNvidiaSmiCommand(ctx, "nvlink", "-R"); err != nil {
+		log.Printf("Failed to get NVLink remote devices: %v", err)
+	} else {
+		parseNVLinkOutput(smiOutput, output, nvlinkRemotePattern, func(link *NVLinkLink, match []string) {
+			link.RemotePCIBusID = match[2]
+		})
+	}
+}
+
+// parseNVLinkOutput calls set with each link of the output of nvidia-smi
+// nvlink that has a line matching pattern. The first submatch of pattern
+// must be the link's ID.
+func parseNVLinkOutput(smiOutput *SMIOutput, output []byte, pattern *regexp.Regexp, set func(*NVLinkLink, []string)) {
+	var gpu *GPU
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if match := nvlinkGPUPattern.FindStringSubmatch(line); match != nil {
+			gpu = nil
+			if index, err := strconv.Atoi(match[1]); err == nil && index < len(smiOutput.GPUs) {
+				gpu = &smiOutput.GPUs[index]
+			}
+			continue
+		}
+		match := pattern.FindStringSubmatch(line)
+		if gpu == nil || match == nil {
+			continue
+		}
+		for i := range gpu.NVLink.Links {
+			if gpu.NVLink.Links[i].ID == match[1] {
+				set(&gpu.NVLink.Links[i], match)
+			}
+		}
+	}
+}
+
+// parseDataValue parses an amount of data like "1024 KiB" into bytes, or 0
+// if it is not reported
+func parseDataValue(value string) uint64 {
+	kib, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " KiB"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kib * 1024
+}
+
+// setNVLinkThroughput computes the throughput of each NVLink from the
+// growth of its data counters since the previous sample of the node
+func setNVLinkThroughput(prev, cur *NodeInfo) {
+	if prev == nil || !cur.Timestamp.After(prev.Timestamp) {
+		return
+	}
+	seconds := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	rate := func(prev, cur uint64) uint64 {
+		// Counters start over when the driver is reloaded
+		if cur < prev {
+			return 0
+		}
+		return uint64(float64(cur-prev) / seconds)
+	}
+	for i := range cur.GPUs {
+		gpu := &cur.GPUs[i]
+		prevGPU, found := findGPU(prev.GPUs, gpu.ID)
+		if !found {
+			continue
+		}
+		for j := range gpu.NVLinks {
+			link := &gpu.NVLinks[j]
+			for _, prevLink := range prevGPU.NVLinks {
+				if prevLink.Index == link.Index {
+					link.TxThroughput = rate(prevLink.TxBytes, link.TxBytes)
+					link.RxThroughput = rate(prevLink.RxBytes, link.RxBytes)
+				}
+			}
+		}
+	}
+}
+
+// sameBusID reports whether two PCI bus IDs refer to the same device. NVML
+// and nvidia-smi report the domain with 8 digits, older tools with 4.
+func sameBusID(a, b string) bool {
+	trimDomain := func(id string) string {
+		id = strings.ToLower(strings.TrimSpace(id))
+		domain, rest, found := strings.Cut(id, ":")
+		if !found {
+			return id
+		}
+		return strings.TrimLeft(domain, "0") + ":" + rest
+	}
+	return trimDomain(a) == trimDomain(b)
+}
+
+// NVLinkTopology is the NVLink connectivity of the GPUs of a node, as
+// returned by /api/nodes/{name}/topology
+type NVLinkTopology struct {
+	Node string   `json:"node"`
+	GPUs []string `json:"gpus"` // GPU IDs in the order of the matrix rows and columns
+
+	// Active NVLinks between each pair of GPUs
+	Matrix [][]int `json:"matrix"`
+	// Active NVLinks of each GPU to other devices, such as NVSwitches that
+	// connect it to the other GPUs linked to them, or to unknown devices
+	OtherLinks []int `json:"other_links"`
+}
+
+// nvlinkTopology builds the NVLink topology of the given GPUs from the
+// remote devices of their active links
+func nvlinkTopology(node string, gpus []GPUInfo) NVLinkTopology {
+	topology := NVLinkTopology{
+		Node:       node,
+		GPUs:       make([]string, len(gpus)),
+		Matrix:     make([][]int, len(gpus)),
+		OtherLinks: make([]int, len(gpus)),
+	}
+	for i, gpu := range gpus {
+		topology.GPUs[i] = gpu.ID
+		topology.Matrix[i] = make([]int, len(gpus))
+		for _, link := range gpu.NVLinks {
+			if link.State != "active" {
+				continue
+			}
+			peer := -1
+			for j := range gpus {
+				if link.RemotePCIBusID != "" && sameBusID(link.RemotePCIBusID, gpus[j].ID) {
+					peer = j
+					break
+				}
+			}
+			if peer >= 0 {
+				topology.Matrix[i][peer]++
+			} else {
+				topology.OtherLinks[i]++
+			}
+		}
+	}
+	return topology
+}
+
+func (a *Aggregator) nodeTopologyHandler(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+	node, exists := a.node(nodeName)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	node.mutex.RLock()
+	var gpus []GPUInfo
+	if node.Data != nil {
+		gpus = node.Data.GPUs
+	}
+	topology := nvlinkTopology(nodeName, gpus)
+	node.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topology)
+}
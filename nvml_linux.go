@@ -0,0 +1,421 @@
+//go:build linux && cgo
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// nvmlClockReasons maps the clock event reason bits to the names of the
+// nvidia-smi XML elements
+var nvmlClockReasons = []struct {
+	bit  uint64
+	name string
+}{
+	{nvml.ClocksEventReasonGpuIdle, "gpu_idle"},
+	{nvml.ClocksEventReasonApplicationsClocksSetting, "applications_clocks_setting"},
+	{nvml.ClocksEventReasonSwPowerCap, "sw_power_cap"},
+	{nvml.ClocksThrottleReasonHwSlowdown, "hw_slowdown"},
+	{nvml.ClocksEventReasonSyncBoost, "sync_boost"},
+	{nvml.ClocksEventReasonSwThermalSlowdown, "sw_thermal_slowdown"},
+	{nvml.ClocksThrottleReasonHwThermalSlowdown, "hw_thermal_slowdown"},
+	{nvml.ClocksThrottleReasonHwPowerBrakeSlowdown, "hw_power_brake_slowdown"},
+	{nvml.ClocksEventReasonDisplayClockSetting, "display_clock_setting"},
+}
+
+var nvmlComputeModes = map[nvml.ComputeMode]string{
+	nvml.COMPUTEMODE_DEFAULT:           "Default",
+	nvml.COMPUTEMODE_EXCLUSIVE_THREAD:  "Exclusive_Thread",
+	nvml.COMPUTEMODE_PROHIBITED:        "Prohibited",
+	nvml.COMPUTEMODE_EXCLUSIVE_PROCESS: "Exclusive_Process",
+}
+
+// initNVML loads the NVML library. It stays loaded for the lifetime of the
+// process.
+func initNVML() error {
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to initialize NVML: %v", nvml.ErrorString(ret))
+	}
+	return nil
+}
+
+// collectNVML queries NVML for what nvidia-smi -q -x reports, formatted the
+// same way so that the output is handled like that of nvidia-smi. NVML calls
+// cannot be canceled, so a call that hangs (e.g. on a GPU that fell off the
+// bus) is abandoned after the nvidia-smi timeout.
+func collectNVML(ctx context.Context) (*SMIOutput, error) {
+	timeout := serverConfig.SMITimeout
+	if timeout <= 0 {
+		timeout = defaultSMITimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		output *SMIOutput
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := queryNVML()
+		done <- result{output, err}
+	}()
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("NVML timed out after %v", timeout)
+		}
+		return nil, fmt.Errorf("NVML canceled: %v", ctx.Err())
+	}
+}
+
+// queryNVML collects the SMIOutput of all GPUs. GPUs that cannot be
+// accessed are left out, as nvidia-smi does.
+func queryNVML() (*SMIOutput, error) {
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get GPU count: %v", nvml.ErrorString(ret))
+	}
+	smiOutput := &SMIOutput{AttachedGPUs: count, GPUs: make([]GPU, 0, count)}
+	if version, ret := nvml.SystemGetDriverVersion(); ret == nvml.SUCCESS {
+		smiOutput.DriverVersion = version
+	}
+	if version, ret := nvml.SystemGetCudaDriverVersion(); ret == nvml.SUCCESS {
+		smiOutput.CUDAVersion = fmt.Sprintf("%d.%d", version/1000, version%1000/10)
+	}
+
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			continue
+		}
+		gpu, err := queryNVMLDevice(device)
+		if err != nil {
+			continue
+		}
+		smiOutput.GPUs = append(smiOutput.GPUs, gpu)
+	}
+	return smiOutput, nil
+}
+
+// queryNVMLDevice collects the info of a single GPU. Values the GPU does not
+// support are reported as "N/A".
+func queryNVMLDevice(device nvml.Device) (GPU, error) {
+	pciInfo, ret := device.GetPciInfo()
+	if ret != nvml.SUCCESS {
+		return GPU{}, fmt.Errorf("failed to get PCI info: %v", nvml.ErrorString(ret))
+	}
+	gpu := GPU{ID: nvmlString(pciInfo.BusId[:])}
+	gpu.ProductName, _ = device.GetName()
+	gpu.UUID, _ = device.GetUUID()
+	gpu.Serial = nvmlValue(device.GetSerial())
+	gpu.VBIOS = nvmlValue(device.GetVbiosVersion())
+
+	if mode, ret := device.GetComputeMode(); ret == nvml.SUCCESS {
+		gpu.ComputeMode = nvmlComputeModes[mode]
+	}
+	if mode, ret := device.GetAccountingMode(); ret == nvml.SUCCESS {
+		gpu.AccountingMode = nvmlEnableState(mode)
+	}
+	if current, pending, ret := device.GetEccMode(); ret == nvml.SUCCESS {
+		gpu.ECCMode = ECCMode{Current: nvmlEnableState(current), Pending: nvmlEnableState(pending)}
+	} else {
+		gpu.ECCMode = ECCMode{Current: "N/A", Pending: "N/A"}
+	}
+	gpu.ECCErrors = ECCErrors{Volatile: nvmlECCCounts(device, nvml.VOLATILE_ECC), Aggregate: nvmlECCCounts(device, nvml.AGGREGATE_ECC)}
+	gpu.RetiredPages = RetiredPages{
+		SingleBit:         nvmlRetiredPages(device, nvml.PAGE_RETIREMENT_CAUSE_MULTIPLE_SINGLE_BIT_ECC_ERRORS),
+		DoubleBit:         nvmlRetiredPages(device, nvml.PAGE_RETIREMENT_CAUSE_DOUBLE_BIT_ECC_ERROR),
+		PendingRetirement: "N/A",
+	}
+	if pending, ret := device.GetRetiredPagesPendingStatus(); ret == nvml.SUCCESS {
+		gpu.RetiredPages.PendingRetirement = "No"
+		if pending == nvml.FEATURE_ENABLED {
+			gpu.RetiredPages.PendingRetirement = "Yes"
+		}
+	}
+
+	gpu.PCI = PCI{
+		MaxLinkGen:       nvmlValue(device.GetMaxPcieLinkGeneration()),
+		CurrentLinkGen:   nvmlValue(device.GetCurrPcieLinkGeneration()),
+		MaxLinkWidth:     nvmlLinkWidth(device.GetMaxPcieLinkWidth()),
+		CurrentLinkWidth: nvmlLinkWidth(device.GetCurrPcieLinkWidth()),
+		TxUtil:           nvmlThroughput(device.GetPcieThroughput(nvml.PCIE_UTIL_TX_BYTES)),
+		RxUtil:           nvmlThroughput(device.GetPcieThroughput(nvml.PCIE_UTIL_RX_BYTES)),
+	}
+
+	if memory, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+		gpu.FBMemory = Memory{Total: nvmlMiB(memory.Total), Used: nvmlMiB(memory.Used), Free: nvmlMiB(memory.Free)}
+	}
+	if memory, ret := device.GetBAR1MemoryInfo(); ret == nvml.SUCCESS {
+		gpu.BAR1Memory = Memory{Total: nvmlMiB(memory.Bar1Total), Used: nvmlMiB(memory.Bar1Used), Free: nvmlMiB(memory.Bar1Free)}
+	}
+	if utilization, ret := device.GetUtilizationRates(); ret == nvml.SUCCESS {
+		gpu.Utilization = Util{GPU: fmt.Sprintf("%d %%", utilization.Gpu), MemUtil: fmt.Sprintf("%d %%", utilization.Memory)}
+	}
+	gpu.Utilization.EncoderUtil = nvmlCodecUtil(device.GetEncoderUtilization())
+	gpu.Utilization.DecoderUtil = nvmlCodecUtil(device.GetDecoderUtilization())
+	gpu.Temperature.GPUTemp = "N/A"
+	if temperature, ret := device.GetTemperature(nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+		gpu.Temperature.GPUTemp = fmt.Sprintf("%d C", temperature)
+	}
+	gpu.FanSpeed = "N/A"
+	if speed, ret := device.GetFanSpeed(); ret == nvml.SUCCESS {
+		gpu.FanSpeed = fmt.Sprintf("%d %%", speed)
+	}
+
+	gpu.Power = Power{PowerDraw: "N/A", PowerLimit: "N/A"}
+	if usage, ret := device.GetPowerUsage(); ret == nvml.SUCCESS {
+		gpu.Power.PowerDraw = nvmlWatts(usage)
+	}
+	if limit, ret := device.GetEnforcedPowerLimit(); ret == nvml.SUCCESS {
+		gpu.Power.PowerLimit = nvmlWatts(limit)
+	}
+
+	gpu.Clocks = nvmlClocks(device.GetClockInfo)
+	gpu.MaxClocks = nvmlClocks(device.GetMaxClockInfo)
+	if reasons, ret := device.GetCurrentClocksEventReasons(); ret == nvml.SUCCESS {
+		for _, reason := range nvmlClockReasons {
+			value := "Not Active"
+			if reasons&reason.bit != 0 {
+				value = "Active"
+			}
+			gpu.ClockReasons.Reasons = append(gpu.ClockReasons.Reasons, ClockReason{
+				XMLName: xml.Name{Local: clockReasonPrefixes[0] + reason.name},
+				Value:   value,
+			})
+		}
+	}
+
+	gpu.Processes = nvmlProcesses(device)
+	gpu.NVLink = nvmlLinks(device)
+	gpu.MIGDevices = nvmlMIGDevices(device)
+	return gpu, nil
+}
+
+// nvmlMIGDevices returns the MIG devices of a GPU in MIG mode, none
+// otherwise. Their profile is the suffix of their name, e.g. "1g.10gb" of
+// "NVIDIA A100-SXM4-80GB MIG 1g.10gb".
+func nvmlMIGDevices(device nvml.Device) MIGDevices {
+	var devices MIGDevices
+	if current, _, ret := device.GetMigMode(); ret != nvml.SUCCESS || current != nvml.DEVICE_MIG_ENABLE {
+		return devices
+	}
+	count, ret := device.GetMaxMigDeviceCount()
+	if ret != nvml.SUCCESS {
+		return devices
+	}
+	for i := 0; i < count; i++ {
+		mig, ret := device.GetMigDeviceHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			// Indices of MIG devices that do not exist are skipped
+			continue
+		}
+		migDevice := MIGDevice{
+			Index:             strconv.Itoa(i),
+			GPUInstanceID:     nvmlValue(mig.GetGpuInstanceId()),
+			ComputeInstanceID: nvmlValue(mig.GetComputeInstanceId()),
+		}
+		if attributes, ret := mig.GetAttributes(); ret == nvml.SUCCESS {
+			migDevice.MultiprocessorCount = strconv.FormatUint(uint64(attributes.MultiprocessorCount), 10)
+		}
+		if memory, ret := mig.GetMemoryInfo(); ret == nvml.SUCCESS {
+			migDevice.FBMemory = Memory{Total: nvmlMiB(memory.Total), Used: nvmlMiB(memory.Used), Free: nvmlMiB(memory.Free)}
+		}
+		migDevice.UUID, _ = mig.GetUUID()
+		if name, ret := mig.GetName(); ret == nvml.SUCCESS {
+			if _, profile, found := strings.Cut(name, " MIG "); found {
+				migDevice.Profile = profile
+			}
+		}
+		devices.Devices = append(devices.Devices, migDevice)
+	}
+	return devices
+}
+
+// nvmlProcesses returns the compute and graphics processes of a GPU. A
+// process that does both is listed once, as nvidia-smi does.
+func nvmlProcesses(device nvml.Device) Processes {
+	var processes Processes
+	seen := make(map[uint32]bool)
+	add := func(infos []nvml.ProcessInfo, ret nvml.Return, processType string) {
+		if ret != nvml.SUCCESS {
+			return
+		}
+		for _, info := range infos {
+			if seen[info.Pid] {
+				continue
+			}
+			seen[info.Pid] = true
+			name, ret := nvml.SystemGetProcessName(int(info.Pid))
+			if ret != nvml.SUCCESS {
+				name = "N/A"
+			}
+			process := Process{
+				PID:               strconv.FormatUint(uint64(info.Pid), 10),
+				ProcessName:       name,
+				UsedMemory:        nvmlMiB(info.UsedGpuMemory),
+				Type:              processType,
+				GPUInstanceID:     "N/A",
+				ComputeInstanceID: "N/A",
+			}
+			// Processes outside of MIG report all bits set
+			if info.GpuInstanceId != math.MaxUint32 {
+				process.GPUInstanceID = strconv.FormatUint(uint64(info.GpuInstanceId), 10)
+				process.ComputeInstanceID = strconv.FormatUint(uint64(info.ComputeInstanceId), 10)
+			}
+			processes.ProcessInfo = append(processes.ProcessInfo, process)
+		}
+	}
+	infos, ret := device.GetComputeRunningProcesses()
+	add(infos, ret, "C")
+	infos, ret = device.GetGraphicsRunningProcesses()
+	add(infos, ret, "G")
+	return processes
+}
+
+// nvmlLinks returns the NVLinks of a GPU, none if it has no NVLink
+func nvmlLinks(device nvml.Device) NVLink {
+	var nvlink NVLink
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		state, ret := device.GetNvLinkState(link)
+		if ret != nvml.SUCCESS {
+			// Links are numbered consecutively, so the rest are absent too
+			break
+		}
+		counter := func(counter nvml.NvLinkErrorCounter) string {
+			return nvmlValue(device.GetNvLinkErrorCounter(link, counter))
+		}
+		linkState := "Inactive"
+		if state == nvml.FEATURE_ENABLED {
+			linkState = "Active"
+		}
+		remote := ""
+		if pciInfo, ret := device.GetNvLinkRemotePciInfo(link); ret == nvml.SUCCESS {
+			remote = nvmlString(pciInfo.BusId[:])
+		}
+		nvlink.Links = append(nvlink.Links, NVLinkLink{
+			ID:             strconv.Itoa(link),
+			State:          linkState,
+			CRCFlitErrors:  counter(nvml.NVLINK_ERROR_DL_CRC_FLIT),
+			CRCDataErrors:  counter(nvml.NVLINK_ERROR_DL_CRC_DATA),
+			ReplayErrors:   counter(nvml.NVLINK_ERROR_DL_REPLAY),
+			RecoveryErrors: counter(nvml.NVLINK_ERROR_DL_RECOVERY),
+			TxData:         nvmlLinkData(device, link, nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX),
+			RxData:         nvmlLinkData(device, link, nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX),
+			RemotePCIBusID: remote,
+		})
+	}
+	return nvlink
+}
+
+// nvmlLinkData returns a data counter of an NVLink in KiB like nvidia-smi
+// nvlink -gt d, or "N/A" if it is not supported
+func nvmlLinkData(device nvml.Device, link int, field uint32) string {
+	values := []nvml.FieldValue{{FieldId: field, ScopeId: uint32(link)}}
+	if device.GetFieldValues(values) != nvml.SUCCESS || nvml.Return(values[0].NvmlReturn) != nvml.SUCCESS {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d KiB", binary.NativeEndian.Uint64(values[0].Value[:]))
+}
+
+// nvmlECCCounts returns the ECC errors of a GPU as totals, as older
+// drivers' nvidia-smi reports them
+func nvmlECCCounts(device nvml.Device, counter nvml.EccCounterType) ECCCounts {
+	return ECCCounts{
+		SingleBitTotal: nvmlValue(device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_CORRECTED, counter)),
+		DoubleBitTotal: nvmlValue(device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, counter)),
+	}
+}
+
+// nvmlRetiredPages returns the number of pages retired for cause
+func nvmlRetiredPages(device nvml.Device, cause nvml.PageRetirementCause) string {
+	pages, ret := device.GetRetiredPages(cause)
+	if ret != nvml.SUCCESS {
+		return "N/A"
+	}
+	return strconv.Itoa(len(pages))
+}
+
+// nvmlClocks returns the graphics, SM and memory clocks from get
+func nvmlClocks(get func(nvml.ClockType) (uint32, nvml.Return)) Clocks {
+	clock := func(clockType nvml.ClockType) string {
+		value, ret := get(clockType)
+		if ret != nvml.SUCCESS {
+			return "N/A"
+		}
+		return fmt.Sprintf("%d MHz", value)
+	}
+	return Clocks{
+		GraphicsClock: clock(nvml.CLOCK_GRAPHICS),
+		SMClock:       clock(nvml.CLOCK_SM),
+		MemClock:      clock(nvml.CLOCK_MEM),
+	}
+}
+
+// nvmlValue formats a value, or "N/A" if it could not be queried
+func nvmlValue[T any](value T, ret nvml.Return) string {
+	if ret != nvml.SUCCESS {
+		return "N/A"
+	}
+	return fmt.Sprint(value)
+}
+
+func nvmlLinkWidth(width int, ret nvml.Return) string {
+	if ret != nvml.SUCCESS {
+		return "N/A"
+	}
+	return fmt.Sprintf("%dx", width)
+}
+
+func nvmlEnableState(state nvml.EnableState) string {
+	if state == nvml.FEATURE_ENABLED {
+		return "Enabled"
+	}
+	return "Disabled"
+}
+
+// nvmlCodecUtil formats the utilization of the encoder or decoder over its
+// sampling period, or "N/A" if it is not supported
+func nvmlCodecUtil(utilization, samplingPeriodUs uint32, ret nvml.Return) string {
+	if ret != nvml.SUCCESS {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d %%", utilization)
+}
+
+// nvmlMiB formats bytes in MiB, as nvidia-smi reports memory
+func nvmlMiB(bytes uint64) string {
+	return fmt.Sprintf("%d MiB", bytes/(1024*1024))
+}
+
+// nvmlThroughput formats a PCIe throughput in KB/s like nvidia-smi
+func nvmlThroughput(kb uint32, ret nvml.Return) string {
+	if ret != nvml.SUCCESS {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d KB/s", kb)
+}
+
+// nvmlWatts formats milliwatts in watts
+func nvmlWatts(milliwatts uint32) string {
+	return fmt.Sprintf("%.2f W", float64(milliwatts)/1000)
+}
+
+// nvmlString converts a NUL-terminated C string
+func nvmlString(b []uint8) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,17 @@
+//go:build !linux || !cgo
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// initNVML fails since the NVML bindings need cgo on Linux
+func initNVML() error {
+	return errors.New("NVML is not supported on this platform")
+}
+
+func collectNVML(ctx context.Context) (*SMIOutput, error) {
+	return nil, errors.New("NVML is not supported on this platform")
+}
@@ -0,0 +1,62 @@
+package main
+
+import "log"
+
+// defaultPowerLimitTolerance is the difference between a GPU's power limit
+// and the expected one that is still considered a match, covering rounding
+// in the reported limits
+const defaultPowerLimitTolerance = 1000 // milliwatts
+
+// expectedPowerLimit returns the power limit the GPUs of a node should run
+// at, zero if it is not checked
+func (a *Aggregator) expectedPowerLimit(node NodeConfig) uint64 {
+	if node.ExpectedPowerLimitMilliwatts > 0 {
+		return node.ExpectedPowerLimitMilliwatts
+	}
+	return a.config.Aggregator.ExpectedPowerLimitMilliwatts
+}
+
+// checkPowerLimits flags the GPUs of a node whose power limit drifted from
+// the expected one, and logs and audits when a GPU starts or stops drifting.
+// Must be called with the node's lock held.
+func (a *Aggregator) checkPowerLimits(status *NodeStatus, gpus []GPUInfo) {
+	expected := a.expectedPowerLimit(status.NodeConfig)
+	if expected == 0 {
+		status.powerLimitDrift = nil
+		return
+	}
+	tolerance := a.config.Aggregator.PowerLimitToleranceMilliwatts
+	if tolerance == 0 {
+		tolerance = defaultPowerLimitTolerance
+	}
+
+	drifting := make(map[string]bool, len(gpus))
+	for i := range gpus {
+		gpu := &gpus[i]
+		// GPUs that do not report a power limit cannot be checked
+		if gpu.PowerLimit == 0 {
+			continue
+		}
+		gpu.PowerLimitDrift = gpu.PowerLimit+tolerance < expected || gpu.PowerLimit > expected+tolerance
+		if gpu.PowerLimitDrift {
+			drifting[gpu.ID] = true
+		}
+
+		if gpu.PowerLimitDrift != status.powerLimitDrift[gpu.ID] {
+			if gpu.PowerLimitDrift {
+				log.Printf("Warning: node %s GPU %s: power limit %.1fW differs from the expected %.1fW",
+					status.Name, gpu.ID, float64(gpu.PowerLimit)/1000, float64(expected)/1000)
+			} else {
+				log.Printf("Node %s GPU %s: power limit back at the expected %.1fW", status.Name, gpu.ID, float64(expected)/1000)
+			}
+			a.audit.record("power_limit_drift", PowerLimitDriftEvent{
+				Node:                 status.Name,
+				GPU:                  gpu.ID,
+				ExpectedMilliwatts:   expected,
+				PowerLimitMilliwatts: gpu.PowerLimit,
+				Drift:                gpu.PowerLimitDrift,
+			})
+		}
+	}
+	status.powerLimitDrift = drifting
+}
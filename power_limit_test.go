@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestCheckPowerLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		expected   uint64 // aggregator-wide
+		nodeLimit  uint64 // expected limit of the node, overriding it
+		tolerance  uint64
+		powerLimit uint64
+		drift      bool
+	}{
+		{"exact match", 300000, 0, 0, 300000, false},
+		{"rounded up", 300000, 0, 0, 300800, false},
+		{"at the lower tolerance", 300000, 0, 0, 299000, false},
+		{"below the tolerance", 300000, 0, 0, 298999, true},
+		{"above the tolerance", 300000, 0, 0, 301001, true},
+		{"default limit of another GPU", 300000, 0, 0, 400000, true},
+		{"wider tolerance", 300000, 0, 5000, 304000, false},
+		{"outside the wider tolerance", 300000, 0, 5000, 306000, true},
+		{"node override matches", 300000, 250000, 0, 250000, false},
+		{"node override drifts", 300000, 250000, 0, 300000, true},
+		{"no power limit reported", 300000, 0, 0, 0, false},
+		{"not checked", 0, 0, 0, 400000, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestAggregator(t)
+			a.config.Aggregator.ExpectedPowerLimitMilliwatts = test.expected
+			a.config.Aggregator.PowerLimitToleranceMilliwatts = test.tolerance
+			status := &NodeStatus{NodeConfig: NodeConfig{Name: "a", ExpectedPowerLimitMilliwatts: test.nodeLimit}}
+			gpus := []GPUInfo{{ID: "00000000:01:00.0", PowerLimit: test.powerLimit}}
+
+			a.checkPowerLimits(status, gpus)
+			if gpus[0].PowerLimitDrift != test.drift {
+				t.Errorf("drift = %v, want %v", gpus[0].PowerLimitDrift, test.drift)
+			}
+			if status.powerLimitDrift[gpus[0].ID] != test.drift {
+				t.Errorf("recorded drift = %v, want %v", status.powerLimitDrift[gpus[0].ID], test.drift)
+			}
+		})
+	}
+}
+
+func TestPowerLimitDriftResolves(t *testing.T) {
+	a := newTestAggregator(t)
+	a.config.Aggregator.ExpectedPowerLimitMilliwatts = 300000
+	status := &NodeStatus{NodeConfig: NodeConfig{Name: "a"}}
+
+	polls := []struct {
+		powerLimit uint64
+		drift      bool
+	}{
+		{400000, true},
+		{400000, true},
+		// Fixed with nvidia-smi -pl
+		{300000, false},
+	}
+	for i, poll := range polls {
+		gpus := []GPUInfo{{ID: "00000000:01:00.0", PowerLimit: poll.powerLimit}}
+		a.checkPowerLimits(status, gpus)
+		if gpus[0].PowerLimitDrift != poll.drift {
+			t.Errorf("poll %d: drift = %v, want %v", i, gpus[0].PowerLimitDrift, poll.drift)
+		}
+		if value, _ := gpuAlertValue("power_limit_drift", gpus[0]); (value == 1) != poll.drift {
+			t.Errorf("poll %d: power_limit_drift alert value = %v, want %v", i, value, poll.drift)
+		}
+	}
+	if len(status.powerLimitDrift) != 0 {
+		t.Errorf("drifting GPUs = %v, want none", status.powerLimitDrift)
+	}
+}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// containerCacheTTL is how long the name and image of a container are reused
+// before the container runtime is asked again
+const containerCacheTTL = 5 * time.Minute
+
+// containerIDPattern matches the 64 hex digit container IDs that Docker,
+// containerd, CRI-O and Podman put in cgroup paths, e.g. "/docker/<id>" or
+// "/system.slice/docker-<id>.scope"
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerInfo is the name and image of a container, as reported by the
+// container runtime
+type containerInfo struct {
+	Name    string
+	Image   string
+	fetched time.Time
+}
+
+var (
+	containerCache      = make(map[string]containerInfo) // by container ID
+	containerCacheMutex sync.Mutex
+	containerClient     = sync.OnceValue(newContainerClient)
+)
+
+// processContainer returns the ID, name and image of the container a process
+// runs in. The ID is empty for processes outside of containers; the name and
+// image are empty if the runtime cannot be asked, e.g. for containerd, which
+// has no Docker-compatible API.
+func processContainer(ctx context.Context, pid uint32) (id, name, image string) {
+	id = processContainerID(pid)
+	if id == "" {
+		return "", "", ""
+	}
+	info := lookupContainer(ctx, id)
+	return id, info.Name, info.Image
+}
+
+// processContainerID returns the ID of the container a process runs in, from
+// the last container ID in its cgroup paths
+func processContainerID(pid uint32) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	ids := containerIDPattern.FindAllString(string(data), -1)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[len(ids)-1]
+}
+
+// newContainerClient returns a client of the Docker-compatible API at the
+// configured socket, or nil if none is configured
+func newContainerClient() *http.Client {
+	socket := serverConfig.ContainerSocket
+	if socket == "" {
+		return nil
+	}
+	return &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// lookupContainer returns the name and image of a container, cached for
+// containerCacheTTL. Failed lookups are cached too, so that a missing socket
+// does not slow down every request.
+func lookupContainer(ctx context.Context, id string) containerInfo {
+	containerCacheMutex.Lock()
+	info, cached := containerCache[id]
+	containerCacheMutex.Unlock()
+	if cached && time.Since(info.fetched) < containerCacheTTL {
+		return info
+	}
+
+	info = containerInfo{fetched: time.Now()}
+	if client := containerClient(); client != nil {
+		if inspected, err := inspectContainer(ctx, client, id); err == nil {
+			info.Name, info.Image = inspected.Name, inspected.Image
+		}
+	}
+
+	containerCacheMutex.Lock()
+	defer containerCacheMutex.Unlock()
+	// Drop containers that have not been seen for a while
+	for cachedID, cachedInfo := range containerCache {
+		if time.Since(cachedInfo.fetched) >= containerCacheTTL {
+			delete(containerCache, cachedID)
+		}
+	}
+	containerCache[id] = info
+	return info
+}
+
+// inspectContainer asks the container runtime for a container's name and
+// image
+func inspectContainer(ctx context.Context, client *http.Client, id string) (containerInfo, error) {
+	// The host is ignored by the socket's dialer
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://container-runtime/containers/"+id+"/json", nil)
+	if err != nil {
+		return containerInfo{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return containerInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return containerInfo{}, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	var inspected struct {
+		Name   string `json:"Name"`
+		Config struct {
+			Image string `json:"Image"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspected); err != nil {
+		return containerInfo{}, err
+	}
+	return containerInfo{Name: strings.TrimPrefix(inspected.Name, "/"), Image: inspected.Config.Image}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "context"
+
+// processContainer is not supported on this platform
+func processContainer(ctx context.Context, pid uint32) (id, name, image string) {
+	return "", "", ""
+}
@@ -0,0 +1,110 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slurmJobCacheTTL is how long the user of a Slurm job is reused before
+// scontrol is run again
+const slurmJobCacheTTL = 10 * time.Minute
+
+// Slurm's cgroup paths, "/slurm/uid_<uid>/job_<id>/step_<step>" with cgroup
+// v1 and "/system.slice/slurmstepd.scope/job_<id>/step_<step>" with v2
+var (
+	slurmJobPattern = regexp.MustCompile(`/job_(\d+)(?:/|$)`)
+	slurmUIDPattern = regexp.MustCompile(`/uid_(\d+)/`)
+	scontrolUser    = regexp.MustCompile(`(?:^|\s)UserId=([^(\s]+)`)
+)
+
+type slurmJob struct {
+	user    string
+	fetched time.Time
+}
+
+var (
+	slurmJobs      = make(map[string]slurmJob) // by job ID
+	slurmJobsMutex sync.Mutex
+)
+
+// processSlurmJob returns the ID of the Slurm job a process belongs to and
+// the user who submitted the job, or "" if the process is not part of a job
+// or Slurm mapping is disabled
+func processSlurmJob(ctx context.Context, pid uint32) (jobID, jobUser string) {
+	if !serverConfig.Slurm {
+		return "", ""
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		match := slurmJobPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		jobID = match[1]
+		// cgroup v1 paths carry the job's user; v2 paths need scontrol
+		fallback := ""
+		if uid := slurmUIDPattern.FindStringSubmatch(line); uid != nil {
+			fallback = userName(uid[1])
+		}
+		return jobID, lookupSlurmJobUser(ctx, jobID, fallback)
+	}
+	return "", ""
+}
+
+// lookupSlurmJobUser returns the user of a Slurm job from scontrol, cached
+// for slurmJobCacheTTL, or fallback if scontrol does not know the job
+func lookupSlurmJobUser(ctx context.Context, jobID, fallback string) string {
+	slurmJobsMutex.Lock()
+	job, cached := slurmJobs[jobID]
+	slurmJobsMutex.Unlock()
+	if cached && time.Since(job.fetched) < slurmJobCacheTTL {
+		if job.user == "" {
+			return fallback
+		}
+		return job.user
+	}
+
+	job = slurmJob{fetched: time.Now()}
+	if output, err := runScontrol(ctx, "show", "job", "--oneliner", jobID); err == nil {
+		if match := scontrolUser.FindSubmatch(output); match != nil {
+			job.user = string(match[1])
+		}
+	}
+
+	slurmJobsMutex.Lock()
+	// Drop jobs that have not been seen for a while
+	for id, cachedJob := range slurmJobs {
+		if time.Since(cachedJob.fetched) >= slurmJobCacheTTL {
+			delete(slurmJobs, id)
+		}
+	}
+	slurmJobs[jobID] = job
+	slurmJobsMutex.Unlock()
+
+	if job.user == "" {
+		return fallback
+	}
+	return job.user
+}
+
+// runScontrol runs scontrol, giving up after a few seconds if the Slurm
+// controller does not answer
+func runScontrol(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "scontrol", args...)
+	killProcessGroupOnCancel(cmd)
+	cmd.WaitDelay = time.Second
+	return cmd.Output()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "context"
+
+// processSlurmJob is not supported on this platform
+func processSlurmJob(ctx context.Context, pid uint32) (jobID, jobUser string) {
+	return "", ""
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+)
+
+// userNames caches the names of user IDs; looking them up may query NSS
+// (e.g. LDAP) and the set of users running GPU jobs is small
+var userNames sync.Map // uid string -> name
+
+// processUser returns the name of the user a process runs as, its user ID
+// if the ID has no name, or "" if the process is not visible, e.g. from a
+// container without the host's PID namespace
+func processUser(pid uint32) string {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// "Uid:" is followed by the real, effective, saved and filesystem IDs
+		fields, found := strings.CutPrefix(scanner.Text(), "Uid:")
+		if !found {
+			continue
+		}
+		ids := strings.Fields(fields)
+		if len(ids) == 0 {
+			return ""
+		}
+		return userName(ids[0])
+	}
+	return ""
+}
+
+// userName returns the name of a user ID, or the ID itself if it has none
+func userName(uid string) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
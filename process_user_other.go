@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// processUser is not supported on this platform
+func processUser(pid uint32) string {
+	return ""
+}
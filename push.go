@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Modes of a node: the aggregator either polls it or waits for it to push
+// its data
+const (
+	NodeModePoll = "poll"
+	NodeModePush = "push"
+)
+
+// defaultPushTimeout is how long a push node may go without pushing before
+// it is marked offline, when push_timeout_seconds is not set
+const defaultPushTimeout = 30 * time.Second
+
+// maxPushSize limits the body of push requests
+const maxPushSize = 8 << 20
+
+// validateNodeMode checks the mode of a node; empty means polling
+func validateNodeMode(node NodeConfig) error {
+	switch node.Mode {
+	case "", NodeModePoll, NodeModePush:
+		return nil
+	}
+	return fmt.Errorf("node %s: invalid mode %q, must be %q or %q", node.Name, node.Mode, NodeModePoll, NodeModePush)
+}
+
+// pushTimeout returns how long a push node may go without pushing
+func (a *Aggregator) pushTimeout() time.Duration {
+	if a.config.Aggregator.PushTimeoutSeconds <= 0 {
+		return defaultPushTimeout
+	}
+	return time.Duration(a.config.Aggregator.PushTimeoutSeconds) * time.Second
+}
+
+// checkPushedNode marks a push node offline once it has not pushed for the
+// push timeout. Nodes that never pushed are given the timeout from the
+// aggregator's start.
+func (a *Aggregator) checkPushedNode(node NodeConfig) {
+	status, exists := a.node(node.Name)
+	if !exists {
+		return
+	}
+	status.mutex.RLock()
+	last, current := status.LastSuccess, status.Status
+	status.mutex.RUnlock()
+
+	if last.IsZero() {
+		last = a.startTime
+	}
+	if current == "offline" || time.Since(last) < a.pushTimeout() {
+		return
+	}
+	a.updateNodeError(node.Name, "offline", ErrTimeout, fmt.Sprintf("No data pushed for %v", a.pushTimeout()))
+}
+
+// requirePushAuth checks the token of a push request, which may be the
+// node's push_token or the admin token. Pushes are refused unless one of
+// them is configured.
+func (a *Aggregator) requirePushAuth(w http.ResponseWriter, r *http.Request, node NodeConfig) bool {
+	configured, ok := bearerTokenMatches(r, node.PushToken, a.config.Aggregator.AdminToken)
+	if !configured {
+		writeJSONError(w, http.StatusForbidden, "Forbidden: no push_token or admin_token configured")
+		return false
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// nodePushHandler accepts the NodeInfo of a push node, as JSON or
+// MessagePack, and records it as if the node had been polled
+func (a *Aggregator) nodePushHandler(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	status, exists := a.node(r.PathValue("name"))
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	status.mutex.RLock()
+	node := status.NodeConfig
+	status.mutex.RUnlock()
+
+	if !a.requirePushAuth(w, r, node) {
+		return
+	}
+	if node.Mode != NodeModePush {
+		writeJSONError(w, http.StatusConflict, "Node is polled, not in push mode")
+		return
+	}
+
+	body := io.LimitReader(r.Body, maxPushSize)
+	var nodeInfo *NodeInfo
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), msgpackContentType) {
+		nodeInfo, err = decodeNodeInfoMsgpack(body)
+	} else {
+		nodeInfo, err = decodeNodeInfo(body)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	a.recordNodeInfo(node.Name, nodeInfo, received, received)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runPusher pushes this node's GPU info to an aggregator's push endpoint on
+// the given interval, for nodes the aggregator cannot reach
+func runPusher(url, token string, interval time.Duration) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		if err := pushNodeInfo(client, url, token); err != nil {
+			log.Printf("Failed to push GPU info to %s: %v", url, err)
+		}
+	}
+}
+
+func pushNodeInfo(client *http.Client, url, token string) error {
+	nodeInfo, err := getNodeInfoFromNvidiaSmi(context.Background(), true)
+	if err != nil {
+		return err
+	}
+	if serverConfig.WithSystemMetrics {
+		nodeInfo.System = getSystemInfo()
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(encodeMsgpack(nodeInfo)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", msgpackContentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP error: %d: %s", resp.StatusCode, errorMessage(body))
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxNodeRequestSize limits the body of node registration requests
+const maxNodeRequestSize = 1 << 20
+
+// requireAdmin checks the admin token of a request that changes the
+// aggregator's state. Such requests are refused unless admin_token is set.
+func (a *Aggregator) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := a.config.Aggregator.AdminToken
+	if token == "" {
+		writeJSONError(w, http.StatusForbidden, "Forbidden: no admin_token configured")
+		return false
+	}
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// validateNodeConfig checks a node before it is added
+func validateNodeConfig(node NodeConfig) error {
+	if node.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(node.Name, "/?#") {
+		return fmt.Errorf("node %s: name must not contain '/', '?' or '#'", node.Name)
+	}
+	// Push nodes are never contacted, so their address is optional
+	if node.Host == "" && node.Mode != NodeModePush {
+		return fmt.Errorf("node %s: host is required", node.Name)
+	}
+	if (node.Port <= 0 && node.Mode != NodeModePush) || node.Port < 0 || node.Port > 65535 {
+		return fmt.Errorf("node %s: invalid port %d", node.Name, node.Port)
+	}
+	if node.PollTimeoutSeconds < 0 || node.PollIntervalSeconds < 0 {
+		return fmt.Errorf("node %s: poll_timeout_seconds and poll_interval_seconds must not be negative", node.Name)
+	}
+	if _, err := filterGPUsByActivity(nil, node.DefaultFilter); err != nil {
+		return fmt.Errorf("node %s: %v", node.Name, err)
+	}
+	if err := validateNodeMode(node); err != nil {
+		return err
+	}
+	if err := validateNodeTransport(node); err != nil {
+		return err
+	}
+	return validateNodeTLS(node)
+}
+
+// decodeNodeConfigs decodes a single NodeConfig or an array of them
+func decodeNodeConfigs(data []byte) ([]NodeConfig, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var nodes []NodeConfig
+		err := json.Unmarshal(data, &nodes)
+		return nodes, err
+	}
+	var node NodeConfig
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	return []NodeConfig{node}, nil
+}
+
+// addNodesHandler registers one or more nodes at runtime. Either all nodes
+// are added or, if any is invalid or already exists, none.
+func (a *Aggregator) addNodesHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNodeRequestSize))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read request: %v", err))
+		return
+	}
+	nodes, err := decodeNodeConfigs(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if len(nodes) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: no nodes given")
+		return
+	}
+	for _, node := range nodes {
+		if err := validateNodeConfig(node); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid node: %v", err))
+			return
+		}
+	}
+
+	a.mutex.Lock()
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if _, exists := a.nodes[node.Name]; exists || seen[node.Name] {
+			a.mutex.Unlock()
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("Node %s already exists", node.Name))
+			return
+		}
+		seen[node.Name] = true
+	}
+	// Copy so that snapshots of the previous config are not modified
+	a.config.Nodes = append(append([]NodeConfig(nil), a.config.Nodes...), nodes...)
+	a.rebuildNodeList()
+	persistErr := a.persistNodes()
+	a.mutex.Unlock()
+
+	for _, node := range nodes {
+		log.Printf("Node %s added (%s:%d) by %s", node.Name, node.Host, node.Port, r.RemoteAddr)
+		node.PushToken = ""
+		node.AuthToken = ""
+		a.audit.record("node_added", NodeChangeEvent{Node: node.Name, Config: &node, RemoteAddr: r.RemoteAddr})
+	}
+	if persistErr != nil {
+		log.Printf("Failed to persist nodes to %s: %v", a.configFile, persistErr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// removeNodeHandler removes a node that was configured statically, added at
+// runtime or registered itself. Nodes from the external nodes source are
+// managed there.
+func (a *Aggregator) removeNodeHandler(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	a.mutex.Lock()
+	index := -1
+	for i, node := range a.config.Nodes {
+		if node.Name == nodeName {
+			index = i
+			break
+		}
+	}
+	if index < 0 && a.unregisterNode(nodeName) {
+		a.mutex.Unlock()
+		a.invalidateMetadata(nodeName)
+		a.history.removeNode(nodeName)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if index < 0 {
+		_, exists := a.nodes[nodeName]
+		a.mutex.Unlock()
+		if exists {
+			writeJSONError(w, http.StatusConflict, "Node is managed by the external nodes source")
+		} else {
+			writeJSONError(w, http.StatusNotFound, "Node not found")
+		}
+		return
+	}
+	nodes := make([]NodeConfig, 0, len(a.config.Nodes)-1)
+	nodes = append(nodes, a.config.Nodes[:index]...)
+	a.config.Nodes = append(nodes, a.config.Nodes[index+1:]...)
+	a.rebuildNodeList()
+	persistErr := a.persistNodes()
+	a.mutex.Unlock()
+
+	a.invalidateMetadata(nodeName)
+	a.history.removeNode(nodeName)
+
+	if persistErr != nil {
+		log.Printf("Failed to persist nodes to %s: %v", a.configFile, persistErr)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// persistNodes writes the static node list back to the config file if
+// -persist is set. Other settings in the file are kept as they are. Must be
+// called with the lock held.
+func (a *Aggregator) persistNodes() error {
+	if !a.persist {
+		return nil
+	}
+
+	return rewriteConfigFile(a.configFile, "nodes", a.config.Nodes)
+}
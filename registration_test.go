@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newAdminAggregator creates an aggregator with the admin token "admin" that
+// persists its nodes to configFile if it is set
+func newAdminAggregator(t *testing.T, configFile string, nodes ...NodeConfig) *Aggregator {
+	t.Helper()
+	config := &AggregatorConfig{Nodes: nodes}
+	config.Aggregator.AdminToken = "admin"
+	if err := prepareAggregatorConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return newAggregator(config, configFile, configFile != "")
+}
+
+// serveNodes sends a request to the node management endpoints
+func serveNodes(a *Aggregator, method, target, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	if method == "DELETE" {
+		r.SetPathValue("name", strings.TrimPrefix(target, "/api/nodes/"))
+		a.removeNodeHandler(recorder, r)
+	} else {
+		a.nodesHandler(recorder, r)
+	}
+	return recorder
+}
+
+func TestAddAndRemoveNodes(t *testing.T) {
+	a := newAdminAggregator(t, "", testNode("static"))
+
+	steps := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		code   int
+		nodes  []string // node list afterwards
+	}{
+		{"add without token", "POST", "/api/nodes", "", `{"name": "a", "host": "10.0.0.1", "port": 8080}`, http.StatusUnauthorized, []string{"static"}},
+		{"add with wrong token", "POST", "/api/nodes", "other", `{"name": "a", "host": "10.0.0.1", "port": 8080}`, http.StatusUnauthorized, []string{"static"}},
+		{"add one", "POST", "/api/nodes", "admin", `{"name": "a", "host": "10.0.0.1", "port": 8080}`, http.StatusCreated, []string{"static", "a"}},
+		{"add several", "POST", "/api/nodes", "admin", `[{"name": "b", "host": "10.0.0.2", "port": 8080}, {"name": "c", "host": "10.0.0.3", "port": 8080}]`, http.StatusCreated, []string{"static", "a", "b", "c"}},
+		{"duplicate", "POST", "/api/nodes", "admin", `{"name": "a", "host": "10.0.0.9", "port": 8080}`, http.StatusConflict, []string{"static", "a", "b", "c"}},
+		{"duplicate of a static node", "POST", "/api/nodes", "admin", `{"name": "static", "host": "10.0.0.9", "port": 8080}`, http.StatusConflict, []string{"static", "a", "b", "c"}},
+		{"duplicate in the request", "POST", "/api/nodes", "admin", `[{"name": "d", "host": "10.0.0.4", "port": 8080}, {"name": "d", "host": "10.0.0.5", "port": 8080}]`, http.StatusConflict, []string{"static", "a", "b", "c"}},
+		{"invalid node", "POST", "/api/nodes", "admin", `{"name": "d", "port": 8080}`, http.StatusBadRequest, []string{"static", "a", "b", "c"}},
+		{"invalid body", "POST", "/api/nodes", "admin", `{"name": `, http.StatusBadRequest, []string{"static", "a", "b", "c"}},
+		{"delete without token", "DELETE", "/api/nodes/a", "", "", http.StatusUnauthorized, []string{"static", "a", "b", "c"}},
+		{"delete", "DELETE", "/api/nodes/a", "admin", "", http.StatusNoContent, []string{"static", "b", "c"}},
+		{"delete again", "DELETE", "/api/nodes/a", "admin", "", http.StatusNotFound, []string{"static", "b", "c"}},
+		{"delete a static node", "DELETE", "/api/nodes/static", "admin", "", http.StatusNoContent, []string{"b", "c"}},
+		{"add after delete", "POST", "/api/nodes", "admin", `{"name": "a", "host": "10.0.0.1", "port": 8080}`, http.StatusCreated, []string{"b", "c", "a"}},
+	}
+	for _, step := range steps {
+		recorder := serveNodes(a, step.method, step.target, step.token, step.body)
+		if recorder.Code != step.code {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, recorder.Code, step.code, recorder.Body)
+		}
+		var names []string
+		for _, node := range a.nodeConfigs() {
+			names = append(names, node.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(step.nodes, ",") {
+			t.Fatalf("%s: nodes = %v, want %v", step.name, names, step.nodes)
+		}
+	}
+
+	// Added nodes are not polled yet
+	status, _ := a.node("b")
+	if status.Status != "unknown" {
+		t.Errorf("status of an added node = %q, want unknown", status.Status)
+	}
+}
+
+func TestAddNodesWithoutAdminToken(t *testing.T) {
+	a := newTestAggregator(t)
+	recorder := serveNodes(a, "POST", "/api/nodes", "admin", `{"name": "a", "host": "10.0.0.1", "port": 8080}`)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", recorder.Code)
+	}
+	if len(a.nodeConfigs()) != 0 {
+		t.Error("node was added")
+	}
+}
+
+func TestPersistNodes(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"aggregator": {"port": 9090}, "nodes": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := newAdminAggregator(t, configFile)
+
+	// readNodes returns the names of the nodes in the config file, and checks
+	// that the other settings are kept
+	readNodes := func() []string {
+		t.Helper()
+		var config AggregatorConfig
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Aggregator.Port != 9090 {
+			t.Errorf("port = %d, want 9090", config.Aggregator.Port)
+		}
+		var names []string
+		for _, node := range config.Nodes {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+
+	serveNodes(a, "POST", "/api/nodes", "admin", `[{"name": "a", "host": "10.0.0.1", "port": 8080}, {"name": "b", "host": "10.0.0.2", "port": 8080}]`)
+	if names := readNodes(); strings.Join(names, ",") != "a,b" {
+		t.Errorf("persisted nodes = %v, want [a b]", names)
+	}
+	serveNodes(a, "DELETE", "/api/nodes/a", "admin", "")
+	if names := readNodes(); strings.Join(names, ",") != "b" {
+		t.Errorf("persisted nodes = %v, want [b]", names)
+	}
+}
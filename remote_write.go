@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// RemoteWriteConfig configures pushing samples to a Prometheus remote_write
+// endpoint after each poll cycle
+type RemoteWriteConfig struct {
+	//doc: URL of the remote_write endpoint; remote write is disabled when empty
+	URL string `json:"url"`
+	//doc: Bearer token sent to the endpoint
+	BearerToken string `json:"bearer_token"`
+	//doc: Basic authentication credentials, as an alternative to bearer_token
+	BasicAuth struct {
+		//doc: User name
+		Username string `json:"username"`
+		//doc: Password
+		Password string `json:"password"`
+	} `json:"basic_auth"`
+	//doc: Seconds between pushes of the buffered samples (default 10)
+	FlushIntervalSeconds int `json:"flush_interval_seconds"`
+	//doc: Samples kept while the endpoint fails before the oldest are dropped (default 100000)
+	MaxBufferedSamples int `json:"max_buffered_samples"`
+}
+
+// RemoteWriteStats represents the counters of the remote_write exporter
+type RemoteWriteStats struct {
+	PushedSamples   uint64 `json:"pushed_samples"`
+	FailedPushes    uint64 `json:"failed_pushes"`
+	DroppedSamples  uint64 `json:"dropped_samples"`
+	BufferedSamples int    `json:"buffered_samples"`
+}
+
+// remoteWriteSeries is a single sample of a labelled time series
+type remoteWriteSeries struct {
+	labels    []metricLabel // sorted by name, including __name__
+	value     float64
+	timestamp int64 // milliseconds
+}
+
+// remoteWriter buffers samples between flushes. When the endpoint is down,
+// samples stay buffered up to a limit, beyond which the oldest are dropped.
+type remoteWriter struct {
+	config RemoteWriteConfig
+	client *http.Client
+
+	mutex          sync.Mutex
+	buffer         []remoteWriteSeries
+	lastTimestamps map[string]time.Time // last enqueued sample time per node
+	stats          RemoteWriteStats
+}
+
+func newRemoteWriter(config RemoteWriteConfig, client *http.Client) *remoteWriter {
+	if config.MaxBufferedSamples <= 0 {
+		config.MaxBufferedSamples = 100000
+	}
+	if config.FlushIntervalSeconds <= 0 {
+		config.FlushIntervalSeconds = 10
+	}
+	return &remoteWriter{
+		config:         config,
+		client:         client,
+		lastTimestamps: make(map[string]time.Time),
+	}
+}
+
+// enqueue buffers the samples of a poll cycle. Samples are timestamped with
+// the time the aggregator received them, which unlike node timestamps is not
+// affected by node clock skew. Nodes whose data did not change since the last
+// cycle are skipped.
+func (rw *remoteWriter) enqueue(nodes []NodeStatus) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	timestamps := make(map[string]time.Time, len(nodes))
+	for _, node := range nodes {
+		timestamp := node.LastSuccess
+		if timestamp.IsZero() || !timestamp.After(rw.lastTimestamps[node.Name]) {
+			continue
+		}
+		timestamps[node.Name] = timestamp
+		rw.lastTimestamps[node.Name] = timestamp
+	}
+
+	for _, family := range gpuMetricFamilies(nodes) {
+		for _, sample := range family.Samples {
+			labels := []metricLabel{{"__name__", family.sampleName(sample)}}
+			var timestamp time.Time
+			for _, label := range sample.Labels {
+				if label.Name == "node" {
+					timestamp = timestamps[label.Value]
+				}
+				// Prometheus treats empty labels as absent
+				if label.Value != "" {
+					labels = append(labels, label)
+				}
+			}
+			if timestamp.IsZero() {
+				continue
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+			rw.buffer = append(rw.buffer, remoteWriteSeries{
+				labels:    labels,
+				value:     sample.Value,
+				timestamp: timestamp.UnixMilli(),
+			})
+		}
+	}
+
+	rw.trimBuffer()
+}
+
+// trimBuffer drops the oldest samples beyond the buffer limit. Must be
+// called with the lock held.
+func (rw *remoteWriter) trimBuffer() {
+	if excess := len(rw.buffer) - rw.config.MaxBufferedSamples; excess > 0 {
+		rw.buffer = append([]remoteWriteSeries(nil), rw.buffer[excess:]...)
+		rw.stats.DroppedSamples += uint64(excess)
+	}
+}
+
+// run flushes the buffer on the configured interval
+func (rw *remoteWriter) run() {
+	ticker := time.NewTicker(time.Duration(rw.config.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := rw.flush(); err != nil {
+			log.Printf("Failed to push samples to remote_write endpoint: %v", err)
+		}
+	}
+}
+
+// flush pushes all buffered samples in one request. On failure they are put
+// back in the buffer for the next attempt.
+func (rw *remoteWriter) flush() error {
+	rw.mutex.Lock()
+	batch := rw.buffer
+	rw.buffer = nil
+	rw.mutex.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := rw.send(batch)
+
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	if err != nil {
+		rw.stats.FailedPushes++
+		rw.buffer = append(batch, rw.buffer...)
+		rw.trimBuffer()
+		return err
+	}
+	rw.stats.PushedSamples += uint64(len(batch))
+	return nil
+}
+
+func (rw *remoteWriter) send(batch []remoteWriteSeries) error {
+	body := snappy.Encode(nil, encodeWriteRequest(batch))
+
+	req, err := http.NewRequest("POST", rw.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if rw.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rw.config.BearerToken)
+	} else if rw.config.BasicAuth.Username != "" {
+		req.SetBasicAuth(rw.config.BasicAuth.Username, rw.config.BasicAuth.Password)
+	}
+
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP error: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// counters returns the current statistics
+func (rw *remoteWriter) counters() RemoteWriteStats {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	stats := rw.stats
+	stats.BufferedSamples = len(rw.buffer)
+	return stats
+}
+
+// encodeWriteRequest encodes samples as a prometheus.WriteRequest message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(batch []remoteWriteSeries) []byte {
+	var request []byte
+	for _, series := range batch {
+		var ts []byte
+		for _, label := range series.labels {
+			var l []byte
+			l = appendProtoBytes(l, 1, []byte(label.Name))
+			l = appendProtoBytes(l, 2, []byte(label.Value))
+			ts = appendProtoBytes(ts, 1, l)
+		}
+
+		var sample []byte
+		sample = binary.AppendUvarint(sample, 1<<3|1) // field 1, fixed64
+		sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(series.value))
+		sample = binary.AppendUvarint(sample, 2<<3|0) // field 2, varint
+		sample = binary.AppendUvarint(sample, uint64(series.timestamp))
+		ts = appendProtoBytes(ts, 2, sample)
+
+		request = appendProtoBytes(request, 1, ts)
+	}
+	return request
+}
+
+// appendProtoBytes appends a length-delimited protobuf field
+func appendProtoBytes(buf []byte, field int, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// smiReplaySource serves recorded nvidia-smi output instead of running
+// nvidia-smi when -replay-file is set
+var smiReplaySource *smiReplay
+
+// smiReplay serves the output of nvidia-smi -q -x recorded in a file or a
+// directory of files. Files of a directory are served in alphabetical order,
+// one per read, starting over after the last, to simulate GPU state changing
+// over time.
+type smiReplay struct {
+	mutex sync.Mutex
+	files []string
+	next  int
+}
+
+// newSMIReplay lists the files to replay from a file or directory
+func newSMIReplay(path string) (*smiReplay, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &smiReplay{files: []string{path}}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	replay := &smiReplay{}
+	// ReadDir returns the entries sorted by name
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			replay.files = append(replay.files, filepath.Join(path, entry.Name()))
+		}
+	}
+	if len(replay.files) == 0 {
+		return nil, fmt.Errorf("no files in %s", path)
+	}
+	return replay, nil
+}
+
+// open opens the next file to replay
+func (r *smiReplay) open() (io.ReadCloser, error) {
+	r.mutex.Lock()
+	file := r.files[r.next]
+	r.next = (r.next + 1) % len(r.files)
+	r.mutex.Unlock()
+
+	return os.Open(file)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxReservationDuration caps how long a GPU can be reserved at once
+const maxReservationDuration = 7 * 24 * time.Hour
+
+// ReservationInfo is an advisory reservation of a GPU for exclusive use. It
+// is not enforced; schedulers and users are expected to honor it.
+type ReservationInfo struct {
+	ID      string    `json:"id"`
+	Node    string    `json:"node"`
+	GPUID   string    `json:"gpu_id"` // PCI bus ID of the GPU
+	Owner   string    `json:"owner"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// reservationRequest is the body of POST /api/reservations. The GPU may be
+// given by index, UUID or bus ID.
+type reservationRequest struct {
+	Node            string `json:"node"`
+	GPUID           string `json:"gpu_id"`
+	Owner           string `json:"owner"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+// reservationStore keeps the GPU reservations in memory, at most one per
+// GPU. Expired reservations are dropped when the store is accessed.
+type reservationStore struct {
+	mutex        sync.Mutex
+	reservations map[string]*ReservationInfo // by node and GPU ID
+}
+
+func newReservationStore() *reservationStore {
+	return &reservationStore{reservations: make(map[string]*ReservationInfo)}
+}
+
+// newReservationID returns a random reservation ID, so that IDs cannot be
+// guessed from each other
+func newReservationID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func reservationKey(node, gpuID string) string {
+	return node + "/" + gpuID
+}
+
+// expire drops the expired reservations. Must be called with the lock held.
+func (s *reservationStore) expire(now time.Time) {
+	for key, reservation := range s.reservations {
+		if !now.Before(reservation.Expires) {
+			delete(s.reservations, key)
+		}
+	}
+}
+
+// reserve reserves a GPU under the given ID. A GPU reserved by someone else cannot be reserved
+// again until the reservation expires or is released; the holder may extend
+// it.
+func (s *reservationStore) reserve(id, node, gpuID, owner string, duration time.Duration) (*ReservationInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	s.expire(now)
+	key := reservationKey(node, gpuID)
+	if existing, exists := s.reservations[key]; exists && existing.Owner != owner {
+		return nil, fmt.Errorf("GPU %s of node %s is reserved by %s until %s",
+			gpuID, node, existing.Owner, existing.Expires.Format(time.RFC3339))
+	}
+
+	reservation := &ReservationInfo{
+		ID:      id,
+		Node:    node,
+		GPUID:   gpuID,
+		Owner:   owner,
+		Created: now,
+		Expires: now.Add(duration),
+	}
+	s.reservations[key] = reservation
+	return reservation, nil
+}
+
+// release removes a reservation by ID and returns it
+func (s *reservationStore) release(id string) (*ReservationInfo, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, reservation := range s.reservations {
+		if reservation.ID == id {
+			delete(s.reservations, key)
+			return reservation, true
+		}
+	}
+	return nil, false
+}
+
+// list returns the active reservations ordered by node and GPU
+func (s *reservationStore) list() []ReservationInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(time.Now())
+	reservations := make([]ReservationInfo, 0, len(s.reservations))
+	for _, reservation := range s.reservations {
+		reservations = append(reservations, *reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if reservations[i].Node != reservations[j].Node {
+			return reservations[i].Node < reservations[j].Node
+		}
+		return reservations[i].GPUID < reservations[j].GPUID
+	})
+	return reservations
+}
+
+// annotate sets the reservation of each of a node's GPUs
+func (s *reservationStore) annotate(node string, gpus []GPUInfo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(time.Now())
+	for i := range gpus {
+		gpus[i].Reservation = s.reservations[reservationKey(node, gpus[i].ID)]
+	}
+}
+
+// foreignProcesses returns the processes on a reserved GPU that run as other
+// users than the reservation's owner. Processes whose user the node could not
+// resolve are not counted.
+func foreignProcesses(gpu GPUInfo) []ProcessInfo {
+	if gpu.Reservation == nil {
+		return nil
+	}
+	var foreign []ProcessInfo
+	for _, proc := range gpu.Processes {
+		if proc.User != "" && proc.User != gpu.Reservation.Owner {
+			foreign = append(foreign, proc)
+		}
+	}
+	return foreign
+}
+
+// annotateNode updates the reservations shown in a node's current data,
+// which are otherwise only updated by the next poll
+func (a *Aggregator) annotateNode(nodeName string) {
+	status, exists := a.node(nodeName)
+	if !exists {
+		return
+	}
+	status.mutex.Lock()
+	if status.Data != nil {
+		a.reservations.annotate(nodeName, status.Data.GPUs)
+	}
+	status.mutex.Unlock()
+}
+
+// reservationsHandler lists the active reservations
+func (a *Aggregator) reservationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.reservations.list())
+}
+
+// createReservationHandler reserves a GPU for its owner. Reservations are
+// made and released with the admin token.
+func (a *Aggregator) createReservationHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAdmin(w, r) {
+		return
+	}
+	var request reservationRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNodeRequestSize)).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if request.Node == "" || request.GPUID == "" || request.Owner == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: node, gpu_id and owner are required")
+		return
+	}
+	duration := time.Duration(request.DurationMinutes) * time.Minute
+	if duration <= 0 || duration > maxReservationDuration {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid duration_minutes: must be between 1 and %d", int(maxReservationDuration.Minutes())))
+		return
+	}
+
+	status, exists := a.node(request.Node)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	var gpu GPUInfo
+	found := false
+	status.mutex.RLock()
+	if status.Data != nil {
+		gpu, found = findGPU(status.Data.GPUs, request.GPUID)
+	}
+	status.mutex.RUnlock()
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "GPU not found")
+		return
+	}
+
+	id, err := newReservationID()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create reservation ID: %v", err))
+		return
+	}
+	reservation, err := a.reservations.reserve(id, request.Node, gpu.ID, request.Owner, duration)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	a.annotateNode(request.Node)
+	log.Printf("GPU %s of node %s reserved by %s until %s", gpu.ID, request.Node, request.Owner, reservation.Expires.Format(time.RFC3339))
+	a.audit.record("gpu_reserved", reservation)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// releaseReservationHandler releases a reservation before it expires
+func (a *Aggregator) releaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAdmin(w, r) {
+		return
+	}
+	reservation, found := a.reservations.release(r.PathValue("id"))
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+	a.annotateNode(reservation.Node)
+	log.Printf("Reservation of GPU %s of node %s by %s released", reservation.GPUID, reservation.Node, reservation.Owner)
+	a.audit.record("gpu_released", reservation)
+	w.WriteHeader(http.StatusNoContent)
+}
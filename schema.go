@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nodeInfoSchemaVersion is the version of the NodeInfo payload served by
+// /gpu-info. Bump it when a field is renamed or changes type. Agents that
+// predate versioning report no version, which decodes as 0.
+const nodeInfoSchemaVersion = 1
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeNodeInfo decodes a /gpu-info payload from an agent of any version.
+// The payload is first decoded as generic JSON and coerced field by field
+// towards the current NodeInfo, so that numbers sent as strings (and vice
+// versa) are converted, values that cannot be converted are left as zero,
+// and missing or unknown fields are ignored.
+func decodeNodeInfo(r io.Reader) (*NodeInfo, error) {
+	var generic interface{}
+	if err := json.NewDecoder(r).Decode(&generic); err != nil {
+		return nil, err
+	}
+	return coerceNodeInfo(generic)
+}
+
+// decodeNodeInfoMsgpack decodes a MessagePack /gpu-info payload with the same
+// leniency as decodeNodeInfo
+func decodeNodeInfoMsgpack(r io.Reader) (*NodeInfo, error) {
+	generic, err := decodeMsgpack(r)
+	if err != nil {
+		return nil, err
+	}
+	return coerceNodeInfo(generic)
+}
+
+// coerceNodeInfo converts a generic decoded payload to a NodeInfo
+func coerceNodeInfo(generic interface{}) (*NodeInfo, error) {
+	coerced, ok := coerceJSON(generic, reflect.TypeOf(NodeInfo{}))
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	data, err := json.Marshal(coerced)
+	if err != nil {
+		return nil, err
+	}
+	var nodeInfo NodeInfo
+	if err := json.Unmarshal(data, &nodeInfo); err != nil {
+		return nil, err
+	}
+	return &nodeInfo, nil
+}
+
+// coerceJSON converts a generic JSON value to the shape json.Unmarshal
+// expects for type t. It reports false if the value cannot be converted, in
+// which case the caller drops it.
+func coerceJSON(value interface{}, t reflect.Type) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	if t == timeType {
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case float64:
+			// Unix seconds
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano), true
+		}
+		return nil, false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return coerceJSON(value, t.Elem())
+
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		fields := jsonFields(t)
+		for key, child := range obj {
+			fieldType, known := fields[key]
+			if !known {
+				continue
+			}
+			if coerced, ok := coerceJSON(child, fieldType); ok {
+				obj[key] = coerced
+			} else {
+				delete(obj, key)
+			}
+		}
+		return obj, true
+
+	case reflect.Slice:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		result := make([]interface{}, 0, len(arr))
+		for _, child := range arr {
+			if coerced, ok := coerceJSON(child, t.Elem()); ok {
+				result = append(result, coerced)
+			}
+		}
+		return result, true
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case float64:
+			return formatFloat(v), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+		return nil, false
+
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		case float64:
+			return v != 0, true
+		}
+		return nil, false
+
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			// Accept unit suffixes such as "85 %" or "250.00 W"
+			fields := strings.Fields(v)
+			if len(fields) == 0 {
+				return nil, false
+			}
+			parsed, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, false
+			}
+			number = parsed
+		default:
+			return nil, false
+		}
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return number, true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if number < 0 {
+				return nil, false
+			}
+		}
+		return math.Round(number), true
+	}
+
+	return value, true
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types,
+// including the fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embeddedName, embeddedType := range jsonFields(field.Type) {
+				fields[embeddedName] = embeddedType
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
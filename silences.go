@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSilenceDuration caps how long alerts can be silenced at once
+const maxSilenceDuration = 30 * 24 * time.Hour
+
+// Silence suppresses the notifications of a node's alerts, or of a single
+// alert, until it expires. Alerts are still evaluated and listed.
+type Silence struct {
+	ID      string    `json:"id"`
+	Node    string    `json:"node,omitempty"`
+	AlertID string    `json:"alert_id,omitempty"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// silenceRequest is the body of POST /api/admin/silences and
+// POST /api/alerts/{id}/silence
+type silenceRequest struct {
+	Node            string `json:"node"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Reason          string `json:"reason"`
+}
+
+// silenceStore keeps the active silences, saved to a file if one is
+// configured. Expired silences are dropped when the store is accessed.
+type silenceStore struct {
+	mutex    sync.Mutex
+	file     string
+	silences []Silence
+	nextID   uint64
+}
+
+// newSilenceStore loads the silences saved to file, if any
+func newSilenceStore(file string) (*silenceStore, error) {
+	s := &silenceStore{file: file}
+	if file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.silences); err != nil {
+		return nil, fmt.Errorf("failed to parse silences file: %v", err)
+	}
+	for _, silence := range s.silences {
+		if id, err := strconv.ParseUint(silence.ID, 10, 64); err == nil && id > s.nextID {
+			s.nextID = id
+		}
+	}
+	return s, nil
+}
+
+// expire drops the expired silences. Must be called with the lock held.
+func (s *silenceStore) expire(now time.Time) {
+	s.silences = slices.DeleteFunc(s.silences, func(silence Silence) bool {
+		return !now.Before(silence.Expires)
+	})
+}
+
+// save writes the silences to the file, if configured. Must be called with
+// the lock held.
+func (s *silenceStore) save() error {
+	if s.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.silences, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".silences-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// add creates a silence of a node or of an alert
+func (s *silenceStore) add(node, alertID, reason string, duration time.Duration) (Silence, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	s.expire(now)
+	s.nextID++
+	silence := Silence{
+		ID:      strconv.FormatUint(s.nextID, 10),
+		Node:    node,
+		AlertID: alertID,
+		Reason:  reason,
+		Created: now,
+		Expires: now.Add(duration),
+	}
+	s.silences = append(s.silences, silence)
+	return silence, s.save()
+}
+
+// remove deletes a silence by ID and returns it
+func (s *silenceStore) remove(id string) (Silence, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(time.Now())
+	for i, silence := range s.silences {
+		if silence.ID == id {
+			s.silences = slices.Delete(s.silences, i, i+1)
+			return silence, true, s.save()
+		}
+	}
+	return Silence{}, false, nil
+}
+
+// list returns the active silences, oldest first
+func (s *silenceStore) list() []Silence {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(time.Now())
+	return append([]Silence{}, s.silences...)
+}
+
+// matches reports whether an active silence applies to an alert
+func (s *silenceStore) matches(alert Alert, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, silence := range s.silences {
+		if now.Before(silence.Expires) && (silence.AlertID == alert.ID || silence.Node == alert.Node) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlackoutWindow is a recurring time range, e.g. Tuesdays 06:00-08:00, in
+// which alert notifications are suppressed
+type BlackoutWindow struct {
+	//doc: Days of the week the window starts on; every day when empty
+	//doc:enum mon,tue,wed,thu,fri,sat,sun
+	Days []string `json:"days"`
+	//doc: Start time of day, e.g. 06:00
+	Start string `json:"start"`
+	//doc: End time of day, e.g. 08:00; a window whose end is before its start ends on the next day
+	End string `json:"end"`
+	//doc: IANA time zone of the start and end times, e.g. Europe/Berlin (default "UTC")
+	Timezone string `json:"timezone"`
+	//doc: Only suppress the alerts of nodes with any of these tags
+	Tags []string `json:"tags"`
+	//doc: Only suppress the alerts of these nodes; the window applies to all nodes when both nodes and tags are empty
+	Nodes []string `json:"nodes"`
+}
+
+// blackoutWindow is a parsed BlackoutWindow
+type blackoutWindow struct {
+	days       map[time.Weekday]bool // nil for every day
+	start, end int                   // minutes since midnight
+	location   *time.Location
+	tags       []string
+	nodes      []string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseBlackoutWindow(window BlackoutWindow) (blackoutWindow, error) {
+	parsed := blackoutWindow{tags: window.Tags, nodes: window.Nodes, location: time.UTC}
+	for _, day := range window.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return parsed, fmt.Errorf("invalid day %q", day)
+		}
+		if parsed.days == nil {
+			parsed.days = make(map[time.Weekday]bool)
+		}
+		parsed.days[weekday] = true
+	}
+	var err error
+	if parsed.start, err = parseTimeOfDay(window.Start); err != nil {
+		return parsed, fmt.Errorf("invalid start: %v", err)
+	}
+	if parsed.end, err = parseTimeOfDay(window.End); err != nil {
+		return parsed, fmt.Errorf("invalid end: %v", err)
+	}
+	if window.Timezone != "" {
+		if parsed.location, err = time.LoadLocation(window.Timezone); err != nil {
+			return parsed, fmt.Errorf("invalid timezone: %v", err)
+		}
+	}
+	return parsed, nil
+}
+
+// parseTimeOfDay parses a time like "06:00" into minutes since midnight
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like 06:00", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// active reports whether the window covers the given time
+func (w blackoutWindow) active(now time.Time) bool {
+	local := now.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+	if w.start <= w.end {
+		return w.onDay(day) && minute >= w.start && minute < w.end
+	}
+	// The window spans midnight; the part after midnight belongs to the
+	// window that started the day before
+	if minute >= w.start {
+		return w.onDay(day)
+	}
+	return minute < w.end && w.onDay((day+6)%7)
+}
+
+func (w blackoutWindow) onDay(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
+
+// global reports whether the window applies to all nodes
+func (w blackoutWindow) global() bool {
+	return len(w.tags) == 0 && len(w.nodes) == 0
+}
+
+// appliesTo reports whether the window suppresses the alerts of a node
+func (w blackoutWindow) appliesTo(node NodeConfig) bool {
+	return w.global() || slices.Contains(w.nodes, node.Name) || slices.ContainsFunc(w.tags, func(tag string) bool {
+		return slices.Contains(node.Tags, tag)
+	})
+}
+
+// decodeSilenceRequest decodes and checks the body of a silence request
+func decodeSilenceRequest(w http.ResponseWriter, r *http.Request) (silenceRequest, time.Duration, bool) {
+	var request silenceRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNodeRequestSize)).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return request, 0, false
+	}
+	duration := time.Duration(request.DurationMinutes) * time.Minute
+	if duration <= 0 || duration > maxSilenceDuration {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid duration_minutes: must be between 1 and %d", int(maxSilenceDuration.Minutes())))
+		return request, 0, false
+	}
+	return request, duration, true
+}
+
+// writeSilence responds with a newly created silence
+func writeSilence(w http.ResponseWriter, silence Silence, err error) {
+	if err != nil {
+		log.Printf("Failed to save silences: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(silence)
+}
+
+// requireAlerting responds with 404 if alerting is disabled
+func (a *Aggregator) requireAlerting(w http.ResponseWriter) bool {
+	if a.alerts == nil {
+		writeJSONError(w, http.StatusNotFound, "Alerting is not enabled")
+		return false
+	}
+	return true
+}
+
+// silencesHandler lists the active silences
+func (a *Aggregator) silencesHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) || !a.requireAdmin(w, r) {
+		return
+	}
+	silences := a.alerts.silences.list()
+	sort.Slice(silences, func(i, j int) bool { return silences[i].Created.Before(silences[j].Created) })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(silences)
+}
+
+// createSilenceHandler silences the alerts of a node, e.g. during
+// maintenance
+func (a *Aggregator) createSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) || !a.requireAdmin(w, r) {
+		return
+	}
+	request, duration, ok := decodeSilenceRequest(w, r)
+	if !ok {
+		return
+	}
+	if request.Node == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: node is required")
+		return
+	}
+	if _, exists := a.node(request.Node); !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	silence, err := a.alerts.silences.add(request.Node, "", request.Reason, duration)
+	log.Printf("Alerts of node %s silenced until %s: %s", request.Node, silence.Expires.Format(time.RFC3339), request.Reason)
+	a.audit.record("silence_created", silence)
+	writeSilence(w, silence, err)
+}
+
+// silenceAlertHandler acknowledges an alert so that it stops notifying
+// while it is still listed
+func (a *Aggregator) silenceAlertHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) || !a.requireAdmin(w, r) {
+		return
+	}
+	request, duration, ok := decodeSilenceRequest(w, r)
+	if !ok {
+		return
+	}
+	alert, found := a.alerts.find(r.PathValue("id"))
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Alert not found")
+		return
+	}
+	silence, err := a.alerts.silences.add("", alert.ID, request.Reason, duration)
+	log.Printf("Alert %s for %s silenced until %s: %s", alert.Rule, alertSubject(alert), silence.Expires.Format(time.RFC3339), request.Reason)
+	a.audit.record("silence_created", silence)
+	writeSilence(w, silence, err)
+}
+
+// deleteSilenceHandler ends a silence before it expires
+func (a *Aggregator) deleteSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAlerting(w) || !a.requireAdmin(w, r) {
+		return
+	}
+	silence, found, err := a.alerts.silences.remove(r.PathValue("id"))
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Silence not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save silences: %v", err)
+	}
+	log.Printf("Silence %s ended", silence.ID)
+	a.audit.record("silence_deleted", silence)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultSMICacheTTL is how long nvidia-smi output is reused when
+// cache_ttl_ms is not set
+const defaultSMICacheTTL = 500 * time.Millisecond
+
+// smiCache shares the output of nvidia-smi between requests that arrive
+// within its TTL, e.g. an aggregator and a local script polling at the same
+// time. Only one request runs nvidia-smi at a time; the others wait for its
+// result instead of starting their own.
+type smiCache struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	ttl   time.Duration
+
+	data      *SMIOutput
+	fetchedAt time.Time
+	fetching  bool
+
+	// Error of the last fetch, returned to the requests that waited for it
+	err        error
+	generation uint64
+}
+
+func newSMICache(ttl time.Duration) *smiCache {
+	c := &smiCache{ttl: ttl}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+// smiOutputCache caches runNvidiaSmi; set by initServer
+var smiOutputCache *smiCache
+
+// smiCacheTTL returns the TTL configured by cache_ttl_ms. Zero selects the
+// default and a negative value disables caching.
+func smiCacheTTL(config ServerConfig) time.Duration {
+	if config.CacheTTLMs == 0 {
+		return defaultSMICacheTTL
+	}
+	return time.Duration(config.CacheTTLMs) * time.Millisecond
+}
+
+// get returns the cached output if it is fresh, and otherwise calls fetch
+// or waits for the fetch already running. The output must not be modified.
+func (c *smiCache) get(ctx context.Context, fetch func(context.Context) (*SMIOutput, error)) (*SMIOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.fetching {
+		generation := c.generation
+		c.cond.Wait()
+		if c.generation != generation && c.err != nil {
+			return nil, c.err
+		}
+	}
+	if c.data != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.data, nil
+	}
+
+	c.fetching = true
+	c.mutex.Unlock()
+	data, err := fetch(ctx)
+	c.mutex.Lock()
+
+	c.fetching = false
+	c.generation++
+	c.err = err
+	// A fetch that failed because its own request went away says nothing
+	// about nvidia-smi; the waiting requests fetch again instead
+	if err != nil && ctx.Err() != nil {
+		c.err = nil
+	}
+	if err == nil {
+		c.data, c.fetchedAt = data, time.Now()
+	}
+	c.cond.Broadcast()
+	return data, err
+}
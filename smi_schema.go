@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// warnPowerSchemaOnce limits the warning about unrecognized power readings
+// to one per process
+var warnPowerSchemaOnce sync.Once
+
+// normalizeSMIOutput maps the fields of the XML schemas emitted by different
+// driver versions onto the ones the parser reads:
+//
+//   - drivers before 525 report power in <power_readings>, with the limit in
+//     <power_limit>; later ones use <gpu_power_readings> and
+//     <current_power_limit>
+//   - drivers from 535 may replace <power_draw> with <instant_power_draw>
+//     and <average_power_draw>
+//   - drivers before 535 report <clocks_throttle_reasons>, later ones
+//     <clocks_event_reasons>
+func normalizeSMIOutput(smiOutput *SMIOutput) {
+	for i := range smiOutput.GPUs {
+		gpu := &smiOutput.GPUs[i]
+		if gpu.Power.empty() {
+			gpu.Power = gpu.PowerFallback
+		}
+		if len(gpu.ClockReasons.Reasons) == 0 {
+			gpu.ClockReasons = gpu.ClockReasonsFallback
+		}
+
+		power := &gpu.Power
+		if power.PowerDraw == "" {
+			power.PowerDraw = firstNonEmpty(power.InstantPowerDraw, power.AveragePowerDraw)
+		}
+		if power.PowerLimit == "" {
+			power.PowerLimit = firstNonEmpty(power.EnforcedPowerLimit, power.LegacyPowerLimit)
+		}
+
+		if power.PowerDraw == "" {
+			warnPowerSchemaOnce.Do(func() {
+				log.Printf("Warning: no power reading found for GPU %s in the nvidia-smi output of driver %s; power will be reported as 0",
+					gpu.ID, smiOutput.DriverVersion)
+			})
+		}
+	}
+}
+
+// empty reports whether none of the power fields were present
+func (p Power) empty() bool {
+	return p == Power{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// defaultUtilizationEMAAlpha is the weight of the latest sample in the
+// utilization moving average when utilization_ema_alpha is not set
+const defaultUtilizationEMAAlpha = 0.3
+
+// validateEMAAlpha checks the utilization moving average factor; zero selects
+// the default
+func validateEMAAlpha(alpha float64) error {
+	if alpha < 0 || alpha > 1 {
+		return fmt.Errorf("utilization_ema_alpha must be between 0 and 1, got %v", alpha)
+	}
+	return nil
+}
+
+// ema returns the exponential moving average after observing value, given
+// the previous average
+func ema(previous, value, alpha float64) float64 {
+	return alpha*value + (1-alpha)*previous
+}
+
+// updateUtilizationEMA sets the moving average utilization of the node's
+// GPUs from their raw utilization and the averages of previous polls, keyed
+// by GPU ID. The first sample of a GPU starts its average. Must be called
+// with the node's lock held.
+func (s *NodeStatus) updateUtilizationEMA(gpus []GPUInfo, alpha float64) {
+	averages := make(map[string]float64, len(gpus))
+	for i := range gpus {
+		average := gpus[i].Utilization
+		if previous, exists := s.utilizationEMA[gpus[i].ID]; exists {
+			average = ema(previous, gpus[i].Utilization, alpha)
+		}
+		averages[gpus[i].ID] = average
+		gpus[i].UtilizationEMA = roundPercent(average)
+	}
+	// GPUs that disappeared start over when they come back
+	s.utilizationEMA = averages
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestUtilizationEMA(t *testing.T) {
+	tests := []struct {
+		name    string
+		alpha   float64
+		samples []float64
+		want    []float64 // average after each sample
+	}{
+		{"bursty", 0.5, []float64{0, 100, 0, 100, 100}, []float64{0, 50, 25, 62.5, 81.3}},
+		{"default alpha", defaultUtilizationEMAAlpha, []float64{100, 0, 0, 0}, []float64{100, 70, 49, 34.3}},
+		{"steady", 0.3, []float64{40, 40, 40}, []float64{40, 40, 40}},
+		{"no smoothing", 1, []float64{10, 90, 30}, []float64{10, 90, 30}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := &NodeStatus{}
+			for i, sample := range test.samples {
+				gpus := []GPUInfo{{ID: "00000000:01:00.0", Utilization: sample}}
+				status.updateUtilizationEMA(gpus, test.alpha)
+				if gpus[0].UtilizationEMA != test.want[i] {
+					t.Errorf("sample %d: average = %v, want %v", i, gpus[0].UtilizationEMA, test.want[i])
+				}
+				if gpus[0].Utilization != sample {
+					t.Errorf("sample %d: raw utilization changed to %v", i, gpus[0].Utilization)
+				}
+			}
+		})
+	}
+}
+
+func TestUtilizationEMAPerGPU(t *testing.T) {
+	status := &NodeStatus{}
+	status.updateUtilizationEMA([]GPUInfo{{ID: "a", Utilization: 100}, {ID: "b", Utilization: 0}}, 0.5)
+
+	// GPU b is missing from one poll, so its average starts over
+	gpus := []GPUInfo{{ID: "a", Utilization: 0}}
+	status.updateUtilizationEMA(gpus, 0.5)
+	if gpus[0].UtilizationEMA != 50 {
+		t.Errorf("average of a = %v, want 50", gpus[0].UtilizationEMA)
+	}
+	gpus = []GPUInfo{{ID: "b", Utilization: 80}, {ID: "a", Utilization: 0}}
+	status.updateUtilizationEMA(gpus, 0.5)
+	if gpus[0].UtilizationEMA != 80 {
+		t.Errorf("average of b = %v, want 80", gpus[0].UtilizationEMA)
+	}
+	if gpus[1].UtilizationEMA != 25 {
+		t.Errorf("average of a = %v, want 25", gpus[1].UtilizationEMA)
+	}
+}
+
+func TestValidateEMAAlpha(t *testing.T) {
+	for _, alpha := range []float64{0, 0.3, 1} {
+		if err := validateEMAAlpha(alpha); err != nil {
+			t.Errorf("alpha %v: %v", alpha, err)
+		}
+	}
+	for _, alpha := range []float64{-0.1, 1.5} {
+		if err := validateEMAAlpha(alpha); err == nil {
+			t.Errorf("alpha %v: no error", alpha)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the /api/events stream
+const (
+	defaultSnapshotInterval = 10 * time.Second
+	// sseKeepAliveInterval keeps proxies from closing quiet streams
+	sseKeepAliveInterval = 15 * time.Second
+	// sseBufferSize is the number of events queued for a client; clients that
+	// fall further behind are disconnected and reconnect
+	sseBufferSize = 256
+)
+
+// sseMessage is an event published to the /api/events streams
+type sseMessage struct {
+	event string
+	node  string
+	data  []byte
+}
+
+// eventHub fans events out to the connected /api/events streams
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan sseMessage]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan sseMessage]struct{})}
+}
+
+func (h *eventHub) subscribe() chan sseMessage {
+	ch := make(chan sseMessage, sseBufferSize)
+	h.mutex.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mutex.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan sseMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, subscribed := h.subscribers[ch]; subscribed {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event to every stream without blocking; streams whose
+// queue is full are closed. It may be called with node locks held.
+func (h *eventHub) publish(event, node string, payload any) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.subscribers) == 0 {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	message := sseMessage{event: event, node: node, data: data}
+	for ch := range h.subscribers {
+		select {
+		case ch <- message:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SnapshotEvent is sent by /api/events when a stream opens and then
+// periodically
+type SnapshotEvent struct {
+	Time  time.Time    `json:"time"`
+	Nodes []NodeStatus `json:"nodes"`
+}
+
+// eventStreamHandler streams server-sent events, for dashboards behind
+// proxies that do not pass WebSockets: "snapshot" with the status of all
+// nodes when the stream opens and every snapshot_interval seconds (0 for
+// only the first), "status" when a node's status changes and
+// "process_lifecycle" when a GPU process starts or exits.
+func (a *Aggregator) eventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	node := query.Get("node")
+	snapshotInterval := defaultSnapshotInterval
+	if value := query.Get("snapshot_interval"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid snapshot_interval parameter: must be a non-negative integer")
+			return
+		}
+		snapshotInterval = time.Duration(seconds) * time.Second
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	events := a.hub.subscribe()
+	defer a.hub.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	writeSnapshot := func() error {
+		nodes := a.snapshotNodes()
+		if node != "" {
+			filtered := nodes[:0]
+			for _, status := range nodes {
+				if status.Name == node {
+					filtered = append(filtered, status)
+				}
+			}
+			nodes = filtered
+		}
+		data, err := json.Marshal(SnapshotEvent{Time: time.Now().UTC(), Nodes: nodes})
+		if err != nil {
+			return err
+		}
+		return writeSSE(w, "snapshot", data)
+	}
+	if err := writeSnapshot(); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	var snapshots <-chan time.Time
+	if snapshotInterval > 0 {
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		snapshots = ticker.C
+	}
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case message, open := <-events:
+			if !open {
+				// Too slow; the client reconnects and gets a fresh snapshot
+				return
+			}
+			if node != "" && message.node != node {
+				continue
+			}
+			err = writeSSE(w, message.event, message.data)
+		case <-snapshots:
+			err = writeSnapshot()
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSE writes one server-sent event. Encoded JSON has no newlines, so
+// the data fits on one line.
+func writeSSE(w http.ResponseWriter, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// wantsEventStream reports whether a request asks for server-sent events
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
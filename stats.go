@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// pollStallThreshold is how long the poller may go without completing a
+// cycle before the aggregator reports not-ready
+const pollStallThreshold = 30 * time.Second
+
+// PollCycleStats represents the statistics of one poll cycle
+type PollCycleStats struct {
+	Start                time.Time `json:"start"`
+	End                  time.Time `json:"end"`
+	DurationMs           int64     `json:"duration_ms"`
+	NodesSucceeded       int       `json:"nodes_succeeded"`
+	NodesFailed          int       `json:"nodes_failed"`
+	SlowestNode          string    `json:"slowest_node"`
+	SlowestNodeLatencyMs int64     `json:"slowest_node_latency_ms"`
+}
+
+// recordNode accounts the outcome of polling a node in the cycle
+func (c *PollCycleStats) recordNode(nodeName string, duration time.Duration, online bool) {
+	if online {
+		c.NodesSucceeded++
+	} else {
+		c.NodesFailed++
+	}
+	if c.SlowestNode == "" || duration.Milliseconds() > c.SlowestNodeLatencyMs {
+		c.SlowestNode = nodeName
+		c.SlowestNodeLatencyMs = duration.Milliseconds()
+	}
+}
+
+// PollStatsResponse is returned by the /api/stats endpoint
+type PollStatsResponse struct {
+	PollIntervalMs    int64              `json:"poll_interval_ms"`
+	CyclesCompleted   uint64             `json:"cycles_completed"`
+	CurrentCycleStart *time.Time         `json:"current_cycle_start,omitempty"`
+	LastCycle         *PollCycleStats    `json:"last_cycle,omitempty"`
+	Nodes             []NodePollDuration `json:"nodes"`
+	RemoteWrite       *RemoteWriteStats  `json:"remote_write,omitempty"`
+}
+
+// NodePollDuration represents the last fetch of a node
+type NodePollDuration struct {
+	Name                string    `json:"name"`
+	Status              string    `json:"status"`
+	LastUpdate          time.Time `json:"last_update"`
+	LastFetchDurationMs int64     `json:"last_fetch_duration_ms"`
+}
+
+func (a *Aggregator) statsHandler(w http.ResponseWriter, r *http.Request) {
+	a.mutex.RLock()
+	stats := PollStatsResponse{
+		PollIntervalMs:  a.pollInterval().Milliseconds(),
+		CyclesCompleted: a.cyclesCompleted,
+		Nodes:           make([]NodePollDuration, 0, len(a.nodeList)),
+	}
+	if !a.currentCycle.IsZero() {
+		start := a.currentCycle
+		stats.CurrentCycleStart = &start
+	}
+	if a.cyclesCompleted > 0 {
+		lastCycle := a.lastCycle
+		stats.LastCycle = &lastCycle
+	}
+	for _, nodeConfig := range a.nodeList {
+		if node, exists := a.nodes[nodeConfig.Name]; exists {
+			node.mutex.RLock()
+			stats.Nodes = append(stats.Nodes, NodePollDuration{
+				Name:                node.Name,
+				Status:              node.Status,
+				LastUpdate:          node.LastUpdate,
+				LastFetchDurationMs: node.LastFetchDurationMs,
+			})
+			node.mutex.RUnlock()
+		}
+	}
+	a.mutex.RUnlock()
+
+	if a.remoteWriter != nil {
+		remoteWrite := a.remoteWriter.counters()
+		stats.RemoteWrite = &remoteWrite
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// pollLatencyWindow is the number of recent polls used for percentiles
+const pollLatencyWindow = 100
+
+// PollStats represents the polling performance of a single node
+type PollStats struct {
+	TotalPolls         uint64  `json:"total_polls"`
+	SuccessfulPolls    uint64  `json:"successful_polls"`
+	FailedPolls        uint64  `json:"failed_polls"`
+	AvgLatencyMs       float64 `json:"avg_latency_ms"`
+	P95LatencyMs       float64 `json:"p95_latency_ms"`
+	LastPollDurationMs int64   `json:"last_poll_duration_ms"`
+}
+
+// pollDurationBuckets are the upper bounds in seconds of the buckets of the
+// node_poll_duration_seconds histogram
+var pollDurationBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// pollStatsTracker accumulates the poll statistics of a node. It is guarded
+// by the node's lock.
+type pollStatsTracker struct {
+	stats          PollStats
+	totalLatencyMs float64
+	recent         []float64 // ring buffer of the latest latencies
+	next           int
+
+	// Cumulative counts of the polls per bucket of pollDurationBuckets and
+	// their total duration
+	durationCounts  [len(pollDurationBuckets)]uint64
+	durationSeconds float64
+}
+
+// record accounts one poll of the node
+func (t *pollStatsTracker) record(duration time.Duration, success bool) {
+	latencyMs := float64(duration.Microseconds()) / 1000
+
+	t.stats.TotalPolls++
+	if success {
+		t.stats.SuccessfulPolls++
+	} else {
+		t.stats.FailedPolls++
+	}
+	t.stats.LastPollDurationMs = duration.Milliseconds()
+	t.totalLatencyMs += latencyMs
+	t.stats.AvgLatencyMs = t.totalLatencyMs / float64(t.stats.TotalPolls)
+
+	for i, bound := range pollDurationBuckets {
+		if duration.Seconds() <= bound {
+			t.durationCounts[i]++
+		}
+	}
+	t.durationSeconds += duration.Seconds()
+
+	if len(t.recent) < pollLatencyWindow {
+		t.recent = append(t.recent, latencyMs)
+	} else {
+		t.recent[t.next] = latencyMs
+		t.next = (t.next + 1) % pollLatencyWindow
+	}
+}
+
+// snapshot returns the current statistics with the P95 latency computed
+// over the recent polls
+func (t *pollStatsTracker) snapshot() PollStats {
+	stats := t.stats
+	if len(t.recent) > 0 {
+		sorted := make([]float64, len(t.recent))
+		copy(sorted, t.recent)
+		sort.Float64s(sorted)
+		stats.P95LatencyMs = sorted[(len(sorted)*95+99)/100-1]
+	}
+	return stats
+}
+
+func (a *Aggregator) nodePollStatsHandler(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("name")
+	node, exists := a.node(nodeName)
+	var stats PollStats
+	if exists {
+		node.mutex.RLock()
+		stats = node.pollStats.snapshot()
+		node.mutex.RUnlock()
+	}
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
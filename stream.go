@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// gpusPlaceholder is where the GPUs are spliced into the encoded NodeInfo
+var gpusPlaceholder = []byte(`"gpus":[]`)
+
+// writeNodeInfoJSON streams nodeInfo as JSON, flushing after each GPU so that
+// clients start receiving large responses (e.g. thousands of processes)
+// right away instead of after the whole document is encoded. Since the
+// response is flushed before the handler returns, it is sent with chunked
+// transfer encoding. The output is the same as encoding nodeInfo at once.
+func writeNodeInfoJSON(w http.ResponseWriter, nodeInfo *NodeInfo) error {
+	gpus := nodeInfo.GPUs
+	header := *nodeInfo
+	header.GPUs = []GPUInfo{}
+	encoded, err := json.Marshal(&header)
+	if err != nil {
+		return err
+	}
+	i := bytes.Index(encoded, gpusPlaceholder)
+	prefix, suffix := encoded[:i+len(gpusPlaceholder)-1], encoded[i+len(gpusPlaceholder)-1:]
+
+	flusher, _ := w.(http.Flusher)
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	for i := range gpus {
+		gpu, err := json.Marshal(&gpus[i])
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			gpu = append([]byte{','}, gpu...)
+		}
+		if _, err := w.Write(gpu); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	_, err = w.Write(append(suffix, '\n'))
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWriteNodeInfoJSON(t *testing.T) {
+	for _, gpus := range []int{0, 1, 20} {
+		info := testNodeInfo()
+		info.GPUs = info.GPUs[:gpus]
+		recorder := httptest.NewRecorder()
+		if err := writeNodeInfoJSON(recorder, info); err != nil {
+			t.Fatal(err)
+		}
+
+		// Same as encoding the info at once
+		var want bytes.Buffer
+		if err := json.NewEncoder(&want).Encode(info); err != nil {
+			t.Fatal(err)
+		}
+		if recorder.Body.String() != want.String() {
+			t.Errorf("%d GPUs: streamed JSON differs:\n%s\n%s", gpus, recorder.Body, want.String())
+		}
+		if !recorder.Flushed && gpus > 0 {
+			t.Errorf("%d GPUs: response was not flushed", gpus)
+		}
+	}
+}
+
+// processesFixture writes a copy of normal_4gpu.xml in which the first GPU
+// runs count processes, and returns its path
+func processesFixture(t *testing.T, count int) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "normal_4gpu.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var processes strings.Builder
+	processes.WriteString("<processes>\n")
+	for i := range count {
+		fmt.Fprintf(&processes, "\t\t\t<process_info>\n"+
+			"\t\t\t\t<gpu_instance_id>N/A</gpu_instance_id>\n"+
+			"\t\t\t\t<compute_instance_id>N/A</compute_instance_id>\n"+
+			"\t\t\t\t<pid>%d</pid>\n"+
+			"\t\t\t\t<type>C</type>\n"+
+			"\t\t\t\t<process_name>worker-%d</process_name>\n"+
+			"\t\t\t\t<used_memory>512 MiB</used_memory>\n"+
+			"\t\t\t</process_info>\n", 30000+i, i)
+	}
+	processes.WriteString("\t\t</processes>")
+	first := regexp.MustCompile(`(?s)<processes>.*?</processes>`).FindIndex(data)
+	data = slices.Concat(data[:first[0]], []byte(processes.String()), data[first[1]:])
+
+	path := filepath.Join(t.TempDir(), "processes.xml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGPUInfoHandlerChunked(t *testing.T) {
+	useMockNvidiaSmi(t, processesFixture(t, 100))
+	server := httptest.NewServer(http.HandlerFunc(gpuInfoHandler))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/gpu-info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", response.StatusCode)
+	}
+	if len(response.TransferEncoding) != 1 || response.TransferEncoding[0] != "chunked" {
+		t.Errorf("Transfer-Encoding = %v, want chunked", response.TransferEncoding)
+	}
+	if response.ContentLength != -1 {
+		t.Errorf("Content-Length = %d, want none", response.ContentLength)
+	}
+
+	nodeInfo, err := decodeNodeInfo(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodeInfo.GPUs) != 4 {
+		t.Fatalf("got %d GPUs, want 4", len(nodeInfo.GPUs))
+	}
+	processes := nodeInfo.GPUs[0].Processes
+	if len(processes) != 100 {
+		t.Fatalf("got %d processes, want 100", len(processes))
+	}
+	if processes[99].PID != 30099 || processes[99].Name != "worker-99" {
+		t.Errorf("last process = %d %q", processes[99].PID, processes[99].Name)
+	}
+}
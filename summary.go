@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Defaults of what /api/summary counts as a free GPU
+const (
+	defaultFreeGPUUtilization = 5
+	defaultFreeGPUMemoryMiB   = 500
+)
+
+// ClusterSummary is returned by the /api/summary endpoint
+type ClusterSummary struct {
+	Nodes        int `json:"nodes"`
+	NodesOnline  int `json:"nodes_online"`
+	NodesOffline int `json:"nodes_offline"` // including nodes in error and never polled
+	GPUs         int `json:"gpus"`          // of online nodes
+	// GPUs below both free thresholds and not reserved
+	FreeGPUs     int    `json:"free_gpus"`
+	ReservedGPUs int    `json:"reserved_gpus"`
+	MemoryTotal  uint64 `json:"memory_total"` // bytes
+	MemoryUsed   uint64 `json:"memory_used"`  // bytes
+	// Mean and maximum of the GPUs' utilization in percent
+	MeanUtilization float64 `json:"mean_utilization"`
+	MaxUtilization  float64 `json:"max_utilization"`
+	PowerUsage      uint64  `json:"power_usage"` // milliwatts
+
+	// Host headroom of the online nodes that report system metrics
+	CPUs            int     `json:"cpus"`
+	IdleCPUs        float64 `json:"idle_cpus"`         // CPUs' worth of idle time
+	HostMemoryTotal uint64  `json:"host_memory_total"` // bytes
+	HostMemoryFree  uint64  `json:"host_memory_free"`  // bytes
+
+	// Thresholds the free GPUs were counted with
+	FreeUtilization float64 `json:"free_utilization"` // percent
+	FreeMemoryMiB   uint64  `json:"free_memory_mib"`
+}
+
+// summaryThreshold parses a free GPU threshold parameter, falling back to the
+// configured value and then to the default
+func summaryThreshold(r *http.Request, name string, configured, fallback float64) (float64, error) {
+	if value := r.URL.Query().Get(name); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			return 0, fmt.Errorf("must be a non-negative number")
+		}
+		return threshold, nil
+	}
+	if configured > 0 {
+		return configured, nil
+	}
+	return fallback, nil
+}
+
+// summaryHandler returns cluster-wide totals, for bots and status pages that
+// need a single cheap call rather than the full node list. A GPU is free if
+// its utilization is below free_utilization percent and less than
+// free_memory_mib MiB of its memory is used. The CPU and RAM headroom counts
+// the nodes that report system metrics.
+func (a *Aggregator) summaryHandler(w http.ResponseWriter, r *http.Request) {
+	freeUtilization, err := summaryThreshold(r, "free_utilization", a.config.Aggregator.FreeGPUUtilization, defaultFreeGPUUtilization)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid free_utilization parameter: %v", err))
+		return
+	}
+	freeMemoryMiB, err := summaryThreshold(r, "free_memory_mib", float64(a.config.Aggregator.FreeGPUMemoryMiB), defaultFreeGPUMemoryMiB)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid free_memory_mib parameter: %v", err))
+		return
+	}
+	freeMemory := uint64(freeMemoryMiB * 1024 * 1024)
+
+	summary := ClusterSummary{
+		FreeUtilization: freeUtilization,
+		FreeMemoryMiB:   uint64(freeMemoryMiB),
+	}
+	var utilizationSum float64
+	// Read the statuses in place rather than snapshotting every node's data
+	a.mutex.RLock()
+	for _, nodeConfig := range a.nodeList {
+		status, exists := a.nodes[nodeConfig.Name]
+		if !exists {
+			continue
+		}
+		summary.Nodes++
+		status.mutex.RLock()
+		if status.Status != "online" || status.Data == nil {
+			summary.NodesOffline++
+			status.mutex.RUnlock()
+			continue
+		}
+		summary.NodesOnline++
+		for _, gpu := range status.Data.GPUs {
+			summary.GPUs++
+			summary.MemoryTotal += gpu.MemoryTotal
+			summary.MemoryUsed += gpu.MemoryUsed
+			summary.PowerUsage += gpu.PowerUsage
+			utilizationSum += gpu.Utilization
+			summary.MaxUtilization = max(summary.MaxUtilization, gpu.Utilization)
+			switch {
+			case gpu.Reservation != nil:
+				summary.ReservedGPUs++
+			case gpu.Utilization < freeUtilization && gpu.MemoryUsed < freeMemory:
+				summary.FreeGPUs++
+			}
+		}
+		if system := status.Data.System; system != nil {
+			summary.CPUs += system.CPUCount
+			summary.IdleCPUs += float64(system.CPUCount) * (100 - system.CPUUtilization) / 100
+			summary.HostMemoryTotal += system.MemoryTotal
+			if system.MemoryTotal > system.MemoryUsed {
+				summary.HostMemoryFree += system.MemoryTotal - system.MemoryUsed
+			}
+		}
+		status.mutex.RUnlock()
+	}
+	a.mutex.RUnlock()
+
+	if summary.GPUs > 0 {
+		summary.MeanUtilization = roundPercent(utilizationSum / float64(summary.GPUs))
+	}
+	summary.IdleCPUs = roundPercent(summary.IdleCPUs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SystemInfo represents host-level metrics of a node
+type SystemInfo struct {
+	CPUCount       int     `json:"cpu_count"`
+	CPUUtilization float64 `json:"cpu_utilization"`
+	LoadAvg1       float64 `json:"load_avg_1"`
+	LoadAvg5       float64 `json:"load_avg_5"`
+	LoadAvg15      float64 `json:"load_avg_15"`
+	MemoryTotal    uint64  `json:"memory_total"`
+	MemoryUsed     uint64  `json:"memory_used"`
+	DiskTotal      uint64  `json:"disk_total"`
+	DiskUsed       uint64  `json:"disk_used"`
+}
+
+// cpuSample is a reading of the aggregate cpu line of /proc/stat
+type cpuSample struct {
+	idle  uint64
+	total uint64
+}
+
+var (
+	lastCPUSample   cpuSample
+	lastCPUSampleMu sync.Mutex
+)
+
+// getSystemInfo collects host metrics from /proc. Metrics that are not
+// available on this platform are left as zero; nil is returned if none are.
+func getSystemInfo() *SystemInfo {
+	info := &SystemInfo{CPUCount: runtime.NumCPU()}
+	available := false
+
+	if util, ok := cpuUtilization(); ok {
+		info.CPUUtilization = util
+		available = true
+	}
+
+	if data, err := os.ReadFile("/proc/loadavg"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) >= 3 {
+			info.LoadAvg1, _ = strconv.ParseFloat(fields[0], 64)
+			info.LoadAvg5, _ = strconv.ParseFloat(fields[1], 64)
+			info.LoadAvg15, _ = strconv.ParseFloat(fields[2], 64)
+			available = true
+		}
+	}
+
+	if total, used, ok := memoryUsage(); ok {
+		info.MemoryTotal = total
+		info.MemoryUsed = used
+		available = true
+	}
+
+	if total, used, ok := diskUsage("/"); ok {
+		info.DiskTotal = total
+		info.DiskUsed = used
+		available = true
+	}
+
+	if !available {
+		return nil
+	}
+	return info
+}
+
+// cpuUtilization returns the CPU utilization since the previous call. The
+// first call samples over a short interval instead.
+func cpuUtilization() (float64, bool) {
+	lastCPUSampleMu.Lock()
+	defer lastCPUSampleMu.Unlock()
+
+	prev := lastCPUSample
+	if prev.total == 0 {
+		sample, ok := readCPUSample()
+		if !ok {
+			return 0, false
+		}
+		prev = sample
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	curr, ok := readCPUSample()
+	if !ok {
+		return 0, false
+	}
+	lastCPUSample = curr
+
+	totalDelta := curr.total - prev.total
+	if totalDelta == 0 {
+		return 0, true
+	}
+	idleDelta := curr.idle - prev.idle
+	return roundPercent(float64(totalDelta-idleDelta) / float64(totalDelta) * 100), true
+}
+
+func readCPUSample() (cpuSample, bool) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuSample{}, false
+	}
+
+	// The first line holds the aggregate: cpu user nice system idle iowait ...
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuSample{}, false
+	}
+
+	var sample cpuSample
+	for i, field := range fields[1:] {
+		value, _ := strconv.ParseUint(field, 10, 64)
+		sample.total += value
+		// idle and iowait
+		if i == 3 || i == 4 {
+			sample.idle += value
+		}
+	}
+	return sample, true
+}
+
+// memoryUsage returns the total and used memory in bytes from /proc/meminfo
+func memoryUsage() (uint64, uint64, bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+
+	var total, available uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Lines look like "MemTotal:       32594848 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = value * 1024
+		case "MemAvailable:":
+			available = value * 1024
+		}
+	}
+
+	if total == 0 {
+		return 0, 0, false
+	}
+	return total, total - available, true
+}
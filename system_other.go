@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// diskUsage is not supported on this platform
+func diskUsage(path string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
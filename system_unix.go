@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// diskUsage returns the total and used bytes of the filesystem at path
+func diskUsage(path string) (uint64, uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, false
+	}
+
+	total := uint64(stat.Blocks) * uint64(stat.Bsize)
+	free := uint64(stat.Bfree) * uint64(stat.Bsize)
+	return total, total - free, true
+}
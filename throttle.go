@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// A GPU is considered throttled when it is busy while its SM clock is well
+// below the maximum
+const (
+	throttleMinPct         = 10 // SM clock below the maximum, in percent
+	throttleMinUtilization = 50 // percent
+)
+
+// Clocks represents the current or maximum clocks of a GPU in the
+// nvidia-smi XML output
+type Clocks struct {
+	GraphicsClock string `xml:"graphics_clock"`
+	SMClock       string `xml:"sm_clock"`
+	MemClock      string `xml:"mem_clock"`
+}
+
+// ClockReasons represents the reasons why a GPU's clocks are reduced, one
+// element per reason with the value "Active" or "Not Active"
+type ClockReasons struct {
+	Reasons []ClockReason `xml:",any"`
+}
+
+// ClockReason is a single element of ClockReasons
+type ClockReason struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// Prefixes of the clock reason elements, renamed from "throttle" to
+// "event" in driver 535
+var clockReasonPrefixes = []string{"clocks_event_reason_", "clocks_throttle_reason_"}
+
+// parseClockValue parses a clock like "1410 MHz"; "N/A" gives 0
+func parseClockValue(value string) uint32 {
+	clock, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " MHz"), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(clock)
+}
+
+// parseThroughputValue parses a PCIe throughput like "1200 KB/s" into bytes
+// per second, or 0 if it is not reported. nvidia-smi and NVML count KB as
+// 1024 bytes.
+func parseThroughputValue(value string) uint64 {
+	kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " KB/s"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
+
+// throttlingPct returns how far the SM clock is below its maximum, in
+// percent
+func throttlingPct(smClock, maxSMClock uint32) float64 {
+	if maxSMClock == 0 || smClock >= maxSMClock {
+		return 0
+	}
+	return roundPercent(float64(maxSMClock-smClock) / float64(maxSMClock) * 100)
+}
+
+// activeClockReasons returns the active reasons in NVML's naming, e.g.
+// ["HwThermalSlowdown", "SwPowerCap"]. An idle GPU is not throttled, so that
+// reason is left out.
+func activeClockReasons(reasons ClockReasons) []string {
+	active := []string{}
+	for _, reason := range reasons.Reasons {
+		if strings.TrimSpace(reason.Value) != "Active" {
+			continue
+		}
+		name := reason.XMLName.Local
+		for _, prefix := range clockReasonPrefixes {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		if name == "gpu_idle" {
+			continue
+		}
+		words := strings.Split(name, "_")
+		for i, word := range words {
+			if word != "" {
+				words[i] = strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+		active = append(active, strings.Join(words, ""))
+	}
+	return active
+}
+
+// setThrottling sets the throttling of a GPU from its clocks and the active
+// reasons reported by nvidia-smi. The GPU is throttled when it has active
+// reasons while it is busy and its clock is well below the maximum.
+func setThrottling(gpu *GPUInfo, reasons ClockReasons) {
+	gpu.ThrottlingPct = throttlingPct(gpu.SMClock, gpu.MaxSMClock)
+	gpu.ThrottleReasons = activeClockReasons(reasons)
+	if gpu.ThrottlingPct > throttleMinPct && gpu.Utilization > throttleMinUtilization {
+		gpu.ThrottleReason = strings.Join(gpu.ThrottleReasons, ",")
+	}
+	gpu.Throttled = gpu.ThrottleReason != ""
+}
+
+// checkThrottling logs and audits when a busy GPU starts or stops being
+// throttled. Must be called with the node's lock held.
+func (a *Aggregator) checkThrottling(status *NodeStatus, gpus []GPUInfo) {
+	throttled := make(map[string]bool, len(gpus))
+	for i := range gpus {
+		gpu := &gpus[i]
+		if gpu.ThrottleReason != "" {
+			throttled[gpu.ID] = true
+		}
+		if throttled[gpu.ID] == status.throttled[gpu.ID] {
+			continue
+		}
+		if throttled[gpu.ID] {
+			log.Printf("Warning: node %s GPU %s: throttled by %s, SM clock %d MHz is %.0f%% below the maximum of %d MHz at %.0f%% utilization",
+				status.Name, gpu.ID, gpu.ThrottleReason, gpu.SMClock, gpu.ThrottlingPct, gpu.MaxSMClock, gpu.Utilization)
+		} else {
+			log.Printf("Node %s GPU %s: no longer throttled", status.Name, gpu.ID)
+		}
+		a.audit.record("throttling", ThrottlingEvent{
+			Node:          status.Name,
+			GPU:           gpu.ID,
+			Reason:        gpu.ThrottleReason,
+			ThrottlingPct: gpu.ThrottlingPct,
+			Utilization:   gpu.Utilization,
+			Throttled:     throttled[gpu.ID],
+		})
+	}
+	status.throttled = throttled
+}
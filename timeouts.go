@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults of the aggregator's HTTP server timeouts, in seconds
+const (
+	defaultReadTimeout    = 10
+	defaultWriteTimeout   = 60
+	defaultIdleTimeout    = 120
+	defaultRequestTimeout = 30
+)
+
+// setServerTimeoutDefaults fills in the server timeouts that are not set
+func setServerTimeoutDefaults(config *AggregatorConfig) {
+	if config.Aggregator.ReadTimeoutSeconds <= 0 {
+		config.Aggregator.ReadTimeoutSeconds = defaultReadTimeout
+	}
+	if config.Aggregator.WriteTimeoutSeconds <= 0 {
+		config.Aggregator.WriteTimeoutSeconds = defaultWriteTimeout
+	}
+	if config.Aggregator.IdleTimeoutSeconds <= 0 {
+		config.Aggregator.IdleTimeoutSeconds = defaultIdleTimeout
+	}
+	if config.Aggregator.RequestTimeoutSeconds <= 0 {
+		config.Aggregator.RequestTimeoutSeconds = defaultRequestTimeout
+	}
+}
+
+// newAggregatorServer creates the HTTP server of the aggregator. The timeouts
+// keep slow or stalled clients from holding connections and goroutines
+// indefinitely.
+func newAggregatorServer(addr string, handler http.Handler, config *AggregatorConfig) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      timeoutMiddleware(handler, time.Duration(config.Aggregator.RequestTimeoutSeconds)*time.Second, config.Aggregator.BasePath),
+		ReadTimeout:  time.Duration(config.Aggregator.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout: time.Duration(config.Aggregator.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:  time.Duration(config.Aggregator.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+// isStreamingRequest reports whether a request asks for a long-lived response
+// stream (server-sent events or a WebSocket upgrade)
+func isStreamingRequest(r *http.Request) bool {
+	return wantsEventStream(r) ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// timeoutMiddleware limits regular requests to the given duration and answers
+// 503 when they take longer. Streaming requests are passed through with the
+// server's write deadline lifted for their connection, since they are meant
+// to stay open. The metric history and /metrics are passed through too,
+// keeping the write deadline: their responses can be large, and
+// http.TimeoutHandler buffers a response until it is complete.
+func timeoutMiddleware(next http.Handler, timeout time.Duration, basePath string) http.Handler {
+	limited := http.TimeoutHandler(next, timeout, "Request timed out")
+	unlimited := http.NewServeMux()
+	unlimited.Handle("GET "+basePath+"/api/nodes/{name}/history", next)
+	unlimited.Handle(basePath+"/metrics", next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStreamingRequest(r) {
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := unlimited.Handler(r); pattern != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// selfSignedCertValidity is how long an auto-generated certificate is valid
+const selfSignedCertValidity = 365 * 24 * time.Hour
+
+// defaultTLSDir returns the directory auto-generated certificates are stored in
+func defaultTLSDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".gpu-monitor"
+	}
+	return filepath.Join(home, ".gpu-monitor")
+}
+
+// ensureSelfSignedCert returns the paths of a self-signed certificate and its
+// key in dir, generating a new Ed25519 pair if they are missing or expired
+func ensureSelfSignedCert(dir string) (certFile, keyFile string, cert *x509.Certificate, err error) {
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		// Regenerate a day early so a restart never serves an expired certificate
+		if time.Now().Add(24 * time.Hour).Before(pair.Leaf.NotAfter) {
+			return certFile, keyFile, pair.Leaf, nil
+		}
+	}
+
+	cert, err = generateSelfSignedCert(certFile, keyFile)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return certFile, keyFile, cert, nil
+}
+
+// generateSelfSignedCert writes a new self-signed Ed25519 certificate for
+// this host and its key
+func generateSelfSignedCert(certFile, keyFile string) (*x509.Certificate, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	hostname := getHostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hostname, Organization: []string{"gpu-monitor"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{hostname, "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create certificate directory: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write certificate: %v", err)
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// certFingerprint returns the SHA-256 fingerprint of a certificate in the
+// colon-separated form printed by openssl
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// validateTLSFiles checks that a certificate and key are given together
+func validateTLSFiles(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("a TLS certificate and key must be given together")
+	}
+	return nil
+}
+
+// validateNodeTLS checks the TLS settings of a node
+func validateNodeTLS(node NodeConfig) error {
+	if !node.TLS && (node.TLSCAFile != "" || node.TLSFingerprint != "" || node.TLSInsecureSkipVerify) {
+		return fmt.Errorf("node %s: TLS settings require tls to be enabled", node.Name)
+	}
+	if node.TLSFingerprint != "" && len(normalizeFingerprint(node.TLSFingerprint)) != sha256.Size*2 {
+		return fmt.Errorf("node %s: invalid tls_fingerprint %q, must be a SHA-256 fingerprint", node.Name, node.TLSFingerprint)
+	}
+	_, err := nodeTLSConfig(node)
+	return err
+}
+
+// normalizeFingerprint returns a fingerprint in upper case without separators
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(fingerprint))
+}
+
+// nodeTLSConfig returns the TLS config of requests to a node, or nil if the
+// node is polled over plain HTTP
+func nodeTLSConfig(node NodeConfig) (*tls.Config, error) {
+	if !node.TLS {
+		return nil, nil
+	}
+	// The host is verified by name even when it is resolved with the custom
+	// DNS server
+	config := &tls.Config{
+		ServerName:         node.Host,
+		InsecureSkipVerify: node.TLSInsecureSkipVerify,
+	}
+	if node.TLSCAFile != "" {
+		data, err := os.ReadFile(node.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in TLS CA file %s", node.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+	if node.TLSFingerprint != "" {
+		// A pinned certificate is trusted by itself, which is what makes
+		// the self-signed certificates of -tls-auto usable
+		fingerprint := normalizeFingerprint(node.TLSFingerprint)
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("no certificate presented")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if got := normalizeFingerprint(certFingerprint(cert)); got != fingerprint {
+				return fmt.Errorf("certificate fingerprint %s does not match tls_fingerprint", certFingerprint(cert))
+			}
+			return nil
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// smiVersionCacheTTL is how long the nvidia-smi version info is cached
+const smiVersionCacheTTL = 5 * time.Minute
+
+// SMIVersion represents the output of nvidia-smi --version
+type SMIVersion struct {
+	NvidiaSmiVersion string `json:"nvidia_smi_version"`
+	DriverVersion    string `json:"driver_version"`
+	CUDAVersion      string `json:"cuda_version"`
+	Raw              string `json:"raw"`
+}
+
+var (
+	smiVersionCache     *SMIVersion
+	smiVersionFetchedAt time.Time
+	smiVersionMutex     sync.Mutex
+)
+
+// getSMIVersion runs nvidia-smi --version, caching the result
+func getSMIVersion(ctx context.Context) (*SMIVersion, error) {
+	smiVersionMutex.Lock()
+	defer smiVersionMutex.Unlock()
+
+	if smiVersionCache != nil && time.Since(smiVersionFetchedAt) < smiVersionCacheTTL {
+		return smiVersionCache, nil
+	}
+
+	output, err := runNvidiaSmiCommand(ctx, "--version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run nvidia-smi: %v", err)
+	}
+
+	version := parseSMIVersion(string(output))
+	smiVersionCache = version
+	smiVersionFetchedAt = time.Now()
+	return version, nil
+}
+
+// parseSMIVersion parses output like:
+//
+//	NVIDIA-SMI version  : 535.54.03
+//	DRIVER version      : 535.54.03
+//	CUDA Version        : 12.2
+func parseSMIVersion(output string) *SMIVersion {
+	version := &SMIVersion{Raw: output}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "nvidia-smi version":
+			version.NvidiaSmiVersion = value
+		case "driver version":
+			version.DriverVersion = value
+		case "cuda version":
+			version.CUDAVersion = value
+		}
+	}
+	return version
+}
+
+func smiVersionHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := getSMIVersion(r.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get nvidia-smi version: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
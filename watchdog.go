@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"runtime"
+	"time"
+)
+
+// watchdogInterval is how often the watchdog looks for stalled polls
+const watchdogInterval = 30 * time.Second
+
+// pollWatch tracks the running poll of a node for the watchdog. It is guarded
+// by the node's lock.
+type pollWatch struct {
+	heartbeat time.Time // updated before and after each poll
+	inFlight  bool
+	id        uint64             // identifies the running poll
+	cancel    context.CancelFunc // cancels the running poll
+	release   func()             // stops the poll cycle from waiting for it
+}
+
+// beginPoll records the start of a poll and returns its ID
+func (a *Aggregator) beginPoll(nodeName string, cancel context.CancelFunc, release func()) uint64 {
+	node, exists := a.node(nodeName)
+	if !exists {
+		return 0
+	}
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	node.watch.id++
+	node.watch.heartbeat = time.Now()
+	node.watch.inFlight = true
+	node.watch.cancel = cancel
+	node.watch.release = release
+	return node.watch.id
+}
+
+// endPoll records the end of a poll. It reports false if the watchdog gave
+// up on the poll in the meantime, in which case its outcome is discarded.
+func (a *Aggregator) endPoll(nodeName string, id uint64) bool {
+	node, exists := a.node(nodeName)
+	if !exists {
+		return false
+	}
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	if !node.watch.inFlight || node.watch.id != id {
+		return false
+	}
+	node.watch.heartbeat = time.Now()
+	node.watch.inFlight = false
+	node.watch.cancel = nil
+	node.watch.release = nil
+	return true
+}
+
+// watchdogLoop periodically restarts stalled polls
+func (a *Aggregator) watchdogLoop() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.restartStalledPolls()
+	}
+}
+
+// watchdogStallThreshold returns how long a node's poll may run before the
+// watchdog considers it stalled, never less than the node's request timeout
+func (a *Aggregator) watchdogStallThreshold(node NodeConfig) time.Duration {
+	return max(2*a.pollInterval(), a.pollTimeout(node)) + 10*time.Second
+}
+
+// restartStalledPolls cancels the polls that have been running for longer
+// than watchdogStallThreshold, e.g. because they are blocked somewhere the
+// request timeout does not reach, and releases the poll cycle from waiting
+// for them. The node is polled again by a new goroutine in the next cycle.
+func (a *Aggregator) restartStalledPolls() {
+	var stalled []string
+	for _, nodeConfig := range a.nodeConfigs() {
+		node, exists := a.node(nodeConfig.Name)
+		if !exists {
+			continue
+		}
+		node.mutex.Lock()
+		if node.watch.inFlight && time.Since(node.watch.heartbeat) > a.watchdogStallThreshold(nodeConfig) {
+			stalled = append(stalled, nodeConfig.Name)
+			log.Printf("Warning: poll of node %s stalled for %v, restarting it", nodeConfig.Name, time.Since(node.watch.heartbeat).Round(time.Second))
+			node.watch.cancel()
+			node.watch.release()
+			node.watch.inFlight = false
+			node.watchdogRestarts++
+		}
+		node.mutex.Unlock()
+	}
+
+	if len(stalled) > 0 {
+		log.Printf("Stacks of the stalled polls:\n%s", pollGoroutineStacks())
+	}
+}
+
+// pollGoroutineStacks returns the stack traces of the goroutines polling
+// nodes
+func pollGoroutineStacks() []byte {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var stacks [][]byte
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.Contains(stack, []byte(").updateNodeStatus(")) {
+			stacks = append(stacks, stack)
+		}
+	}
+	return bytes.Join(stacks, []byte("\n\n"))
+}